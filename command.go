@@ -18,11 +18,13 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"os/exec"
 	"strings"
-
-	"github.com/pkg/errors"
 )
 
 // Command represents a shell command.
@@ -30,32 +32,190 @@ type Command interface {
 	// Execute executes this command supplied with its arguments as method parameters.
 	// It returns the output as string on success, otherwise error.
 	Execute(args ...string) (string, error)
+	// ExecuteContext executes this command the same way as Execute does, the command is killed once the specified
+	// context is done, and the specified options are applied to this execution only.
+	// The returned error is an *ExecError once the command has been started.
+	ExecuteContext(ctx context.Context, opts ExecOptions, args ...string) (string, error)
+}
+
+// ExecOptions are the options of a single execution of a command.
+type ExecOptions struct {
+	// Env is the list of 'KEY=VALUE' pairs extending the environment of the command,
+	// a pair overrides an already defined variable with the same key.
+	Env []string
+	// Stdin is read as the standard input of the command if set.
+	Stdin io.Reader
+	// Stream receives the standard output and the standard error of the command while it is running if set,
+	// the output is still captured and returned.
+	Stream io.Writer
+}
+
+// ExecError is the error of a command that has been started but failed.
+type ExecError struct {
+	// CommandLine is the executed command along with its arguments.
+	CommandLine string
+	// ExitCode is the exit code of the command, or -1 if it has been killed or could not be started.
+	ExitCode int
+	// Stderr is the trimmed standard error of the command.
+	Stderr string
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the underlying error followed by the standard error of the command.
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Err.Error(), e.Stderr)
+}
+
+// Cause returns the underlying error.
+func (e *ExecError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying error.
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code of the command that has caused the specified error,
+// or -1 if the error is not caused by a command that has exited.
+func ExitCode(err error) int {
+	if e := execErrorOf(err); e != nil {
+		return e.ExitCode
+	}
+	return -1
 }
 
 type namedCommand struct {
-	name    string
-	workDir string
+	name     string
+	workDir  string
+	env      []string
+	combined bool
+	ctx      context.Context
+	stream   io.Writer
 }
 
 func (comm *namedCommand) Execute(args ...string) (string, error) {
-	c := exec.Command(comm.name, args...)
+	ctx := comm.ctx
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return comm.ExecuteContext(ctx, ExecOptions{Stream: comm.stream}, args...)
+}
+
+func (comm *namedCommand) ExecuteContext(ctx context.Context, opts ExecOptions, args ...string) (string, error) {
+	c := exec.CommandContext(ctx, comm.name, args...)
 	c.Dir = comm.workDir
+	c.Stdin = opts.Stdin
 
-	var out string
-	var err error
-	var stderr bytes.Buffer
-	c.Stderr = &stderr
+	if env := append(append([]string{}, comm.env...), opts.Env...); len(env) > 0 {
+		c.Env = append(os.Environ(), env...)
+	}
 
-	if output, e := c.Output(); e != nil {
-		out, err = strings.TrimSpace(string(output)),
-			errors.Wrap(errors.New(strings.TrimSpace(string(stderr.Bytes()))), e.Error())
-	} else {
-		out, err = strings.TrimSpace(string(output)), nil
+	var stdout, stderr bytes.Buffer
+	var outWriter, errWriter io.Writer = &stdout, &stderr
+
+	if comm.combined {
+		errWriter = outWriter
+	}
+
+	if opts.Stream != nil {
+		outWriter = io.MultiWriter(outWriter, opts.Stream)
+
+		if comm.combined {
+			// the same writer makes both outputs share a single pipe, preserving their order.
+			errWriter = outWriter
+		} else {
+			errWriter = io.MultiWriter(errWriter, opts.Stream)
+		}
+	}
+
+	c.Stdout, c.Stderr = outWriter, errWriter
+
+	e := c.Run()
+	out := strings.TrimSpace(stdout.String())
+
+	if e == nil {
+		return out, nil
+	}
+
+	err := &ExecError{
+		CommandLine: strings.Join(append([]string{comm.name}, args...), " "),
+		ExitCode:    -1,
+		Stderr:      strings.TrimSpace(stderr.String()),
+		Err:         e,
+	}
+
+	if comm.combined {
+		err.Stderr = out
+	}
+
+	if ctx.Err() != nil {
+		err.Err = ctx.Err()
+	} else if exitErr, ok := e.(*exec.ExitError); ok {
+		err.ExitCode = exitErr.ExitCode()
 	}
 
 	return out, err
 }
 
+// CommandSpec describes a command to be created by a Commander.
+type CommandSpec struct {
+	// Name is the name or the path of the executable.
+	Name string
+	// WorkDir is the working directory where the command is being executed.
+	WorkDir string
+	// Env is the list of 'KEY=VALUE' pairs extending the current process environment.
+	Env []string
+	// Combined includes the standard error in the output, for commands reporting their results on the standard error.
+	Combined bool
+	// Context cancels the command once it is done, no command is cancelled if not set.
+	Context context.Context
+	// Stream receives the output of the command while it is running if set.
+	Stream io.Writer
+	// Logger logs every execution of the command at the trace level if set.
+	Logger *slog.Logger
+}
+
+// Commander creates the commands executed by Git and GoTools, replacing it replaces the executed commands,
+// e.g. by a FakeCommander in tests.
+type Commander interface {
+	// Command returns the command described by the specified spec.
+	Command(spec CommandSpec) Command
+}
+
+// NewCommand return an instance of Command executing the command described by the specified spec.
+func NewCommand(spec CommandSpec) Command {
+	return &namedCommand{
+		name:     spec.Name,
+		workDir:  spec.WorkDir,
+		env:      spec.Env,
+		combined: spec.Combined,
+		ctx:      spec.Context,
+		stream:   spec.Stream,
+	}
+}
+
+// newCommand returns the command described by the specified spec created by the specified commander,
+// or a real one if the commander is nil, its executions are logged if the spec has a logger enabled at the trace level.
+func newCommand(commander Commander, spec CommandSpec) Command {
+	var c Command
+
+	if commander != nil {
+		c = commander.Command(spec)
+	} else {
+		c = NewCommand(spec)
+	}
+
+	if spec.Logger != nil && spec.Logger.Enabled(context.Background(), LevelTrace) {
+		return &loggedCommand{Command: c, logger: spec.Logger, spec: spec}
+	}
+
+	return c
+}
+
 // NewNamedCommand return an instance of Command.
 func NewNamedCommand(name, workDir string) Command {
 	return &namedCommand{name: name, workDir: workDir}
@@ -17,9 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -53,3 +58,86 @@ func TestNewNamedCommand(t *testing.T) {
 	assert.Equal(t, "NewCommand", c.(*namedCommand).name)
 	assert.Equal(t, "/home", c.(*namedCommand).workDir)
 }
+
+func TestNewCommand_Combined(t *testing.T) {
+	out, err := NewCommand(CommandSpec{Name: "sh", WorkDir: os.TempDir(), Combined: true}).Execute("-c", "echo out; echo err >&2")
+	assert.Nil(t, err)
+	assert.Equal(t, "out"+NewLine()+"err", out)
+
+	out, err = NewCommand(CommandSpec{Name: "sh", WorkDir: os.TempDir(), Combined: true}).Execute("-c", "echo failed >&2; exit 3")
+	assert.Equal(t, "failed", out)
+	assert.EqualError(t, err, "exit status 3: failed")
+}
+
+func TestCommandImpl_ExecuteContext_EnvAndStdin(t *testing.T) {
+	c := NewCommand(CommandSpec{Name: "sh", WorkDir: os.TempDir(), Env: []string{"REGO_A=a", "REGO_B=b"}})
+
+	out, err := c.ExecuteContext(context.Background(), ExecOptions{
+		Env:   []string{"REGO_B=overridden"},
+		Stdin: strings.NewReader("input"),
+	}, "-c", "echo $REGO_A $REGO_B; cat")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "a overridden"+NewLine()+"input", out)
+}
+
+func TestCommandImpl_ExecuteContext_Stream(t *testing.T) {
+	var stream bytes.Buffer
+
+	out, err := NewNamedCommand("sh", os.TempDir()).ExecuteContext(context.Background(),
+		ExecOptions{Stream: &stream}, "-c", "echo out; echo err >&2")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "out", out)
+	assert.Contains(t, stream.String(), "out"+NewLine())
+	assert.Contains(t, stream.String(), "err"+NewLine())
+}
+
+func TestCommandImpl_ExecuteContext_ExecError(t *testing.T) {
+	out, err := NewNamedCommand("sh", os.TempDir()).ExecuteContext(context.Background(), ExecOptions{},
+		"-c", "echo partial; echo failed >&2; exit 3")
+
+	assert.Equal(t, "partial", out)
+	assert.EqualError(t, err, "exit status 3: failed")
+	assert.Equal(t, 3, ExitCode(err))
+
+	if e, ok := err.(*ExecError); assert.True(t, ok) {
+		assert.Equal(t, "sh -c echo partial; echo failed >&2; exit 3", e.CommandLine)
+		assert.Equal(t, 3, e.ExitCode)
+		assert.Equal(t, "failed", e.Stderr)
+	}
+}
+
+func TestCommandImpl_ExecuteContext_NotFound(t *testing.T) {
+	_, err := NewNamedCommand("rego-missing-command", os.TempDir()).Execute()
+
+	assert.NotNil(t, err)
+	assert.Equal(t, -1, ExitCode(err))
+	assert.Equal(t, -1, ExitCode(errors.New("not a command error")))
+}
+
+func TestCommandImpl_ExecuteContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewNamedCommand("sleep", os.TempDir()).ExecuteContext(ctx, ExecOptions{}, "10")
+
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Equal(t, -1, ExitCode(err))
+
+	if e, ok := err.(*ExecError); assert.True(t, ok) {
+		assert.Equal(t, context.DeadlineExceeded, e.Err)
+	}
+}
+
+func TestCommandImpl_Execute_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := (&namedCommand{name: "sleep", workDir: os.TempDir(), ctx: ctx}).Execute("10")
+
+	if e, ok := err.(*ExecError); assert.True(t, ok) {
+		assert.Equal(t, context.Canceled, e.Err)
+	}
+}
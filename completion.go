@@ -0,0 +1,344 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	getopt "github.com/kesselborn/go-getopt"
+)
+
+// The kinds of the dynamically completed names, listed by the hidden '__complete' command.
+const (
+	branchNames = "branches"
+	tagNames    = "tags"
+	refNames    = "refs"
+)
+
+// The shells the completion scripts are generated for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completedCommands are the commands offered by the completion scripts, the hidden ones are left out.
+var completedCommands = []string{releaseCommand, lintCommitsCommand, doctorCommand, initCommand, completionCommand, manCommand}
+
+// optionValues maps the options accepting a known set of values to their values.
+var optionValues = map[string][]string{
+	"log-level":    {"error", "warn", "info", "debug", "trace"},
+	"log-format":   {textLogFormat, jsonLogFormat},
+	"error-format": {textErrorFormat, jsonErrorFormat},
+	"git-backend":  {execGitBackend, nativeGitBackend},
+}
+
+// optionNames maps the options whose values are names of the repository to the kind of the names.
+var optionNames = map[string]string{
+	"branch": branchNames,
+	"tag":    tagNames,
+	"ref":    refNames,
+	"since":  refNames,
+}
+
+// optionFiles is the set of the options whose values are files, or directories if true.
+var optionFiles = map[string]bool{
+	"config":          false,
+	"manifest":        false,
+	"allowed-signers": false,
+	"work-directory":  true,
+}
+
+// completedOption is an option as offered by the completion scripts.
+type completedOption struct {
+	long        string
+	short       string
+	description string
+	// takesValue is true if the option is followed by a value.
+	takesValue bool
+	// values are the fixed values of the option if any.
+	values []string
+	// names is the kind of the names of the repository the option value is completed with if any.
+	names string
+	// file is true if the option value is completed with the files, dir if with the directories.
+	file, dir bool
+}
+
+// completedOptions returns the options of the specified parser along with the help option.
+func completedOptions(parser getopt.Options) []completedOption {
+	var result []completedOption
+
+	for _, o := range parser.Definitions {
+		if o.Flags&getopt.IsArg > 0 || !o.HasLongOpt() {
+			continue
+		}
+
+		c := completedOption{
+			long:        o.LongOpt(),
+			short:       o.ShortOpt(),
+			description: summary(o.Description),
+			takesValue:  o.Flags&getopt.Flag == 0,
+			values:      optionValues[o.Key()],
+			names:       optionNames[o.Key()],
+		}
+
+		if dir, found := optionFiles[o.Key()]; found {
+			c.file, c.dir = !dir, dir
+		}
+
+		result = append(result, c)
+	}
+
+	return append(result, completedOption{long: "help", short: "h", description: "Prints the usage (-h) or the detailed help (--help)"})
+}
+
+// summary returns the first clause of the specified option description, which is short enough to be shown next to
+// the option name.
+func summary(description string) string {
+	for _, separator := range []string{", ", "; ", " (", ". "} {
+		if i := strings.Index(description, separator); i > 0 {
+			description = description[:i]
+		}
+	}
+	return strings.TrimSuffix(description, ".")
+}
+
+// completionScript returns the completion script of the shell specified by the arguments.
+func completionScript(parser getopt.Options, arguments []string) (string, error) {
+	if len(arguments) != 1 {
+		return "", fmt.Errorf("the '%v' command expects a shell, one of: %v", completionCommand, strings.Join(completionShells, ", "))
+	}
+
+	options := completedOptions(parser)
+
+	switch arguments[0] {
+	case "bash":
+		return bashCompletion(options), nil
+	case "zsh":
+		return zshCompletion(options), nil
+	case "fish":
+		return fishCompletion(options), nil
+	}
+
+	return "", fmt.Errorf("unknown shell '%v', one of %v is expected", arguments[0], strings.Join(completionShells, ", "))
+}
+
+// completeNames returns the names of the kind specified by the arguments, one per line, for the completion scripts.
+func completeNames(g *Git, arguments []string) (string, error) {
+	var names []string
+	var err error
+
+	if len(arguments) != 1 {
+		return "", fmt.Errorf("the '%v' command expects one of: %v, %v or %v", completeCommand, branchNames, tagNames, refNames)
+	}
+
+	switch arguments[0] {
+	case branchNames:
+		names, err = g.Branches(false)
+	case tagNames:
+		names, err = g.Tags()
+	case refNames:
+		var tags []string
+
+		if names, err = g.Branches(true); err == nil {
+			tags, err = g.Tags()
+			names = append(names, tags...)
+		}
+	default:
+		return "", fmt.Errorf("unknown names '%v', one of %v, %v or %v is expected", arguments[0], branchNames, tagNames, refNames)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, NewLine()), nil
+}
+
+func bashCompletion(options []completedOption) string {
+	var b strings.Builder
+	var all []string
+
+	b.WriteString(`# bash completion for rego, generated by 'rego completion bash'.
+_rego() {
+	local cur prev
+	cur="${COMP_WORDS[COMP_CWORD]}"
+	prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+	case "$prev" in
+`)
+
+	for _, o := range options {
+		names := "--" + o.long
+
+		if len(o.short) > 0 {
+			names = "-" + o.short + "|" + names
+			all = append(all, "-"+o.short)
+		}
+
+		all = append(all, "--"+o.long)
+
+		if !o.takesValue {
+			continue
+		}
+
+		var reply string
+
+		switch {
+		case len(o.values) > 0:
+			reply = fmt.Sprintf(`COMPREPLY=($(compgen -W "%v" -- "$cur"))`, strings.Join(o.values, " "))
+		case len(o.names) > 0:
+			reply = fmt.Sprintf(`COMPREPLY=($(compgen -W "$(rego %v %v 2>/dev/null)" -- "$cur"))`, completeCommand, o.names)
+		case o.dir:
+			reply = `COMPREPLY=($(compgen -d -- "$cur"))`
+		case o.file:
+			reply = `COMPREPLY=($(compgen -f -- "$cur"))`
+		default:
+			reply = `COMPREPLY=()`
+		}
+
+		fmt.Fprintf(&b, "\t%v)\n\t\t%v\n\t\treturn\n\t\t;;\n", names, reply)
+	}
+
+	fmt.Fprintf(&b, `	esac
+
+	if [[ "$cur" == -* ]]; then
+		COMPREPLY=($(compgen -W "%v" -- "$cur"))
+	elif [[ " ${COMP_WORDS[*]:1:COMP_CWORD-1} " == *" %v "* ]]; then
+		COMPREPLY=($(compgen -W "%v" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -W "%v" -- "$cur"))
+	fi
+}
+
+complete -F _rego rego
+`, strings.Join(all, " "), completionCommand, strings.Join(completionShells, " "), strings.Join(completedCommands, " "))
+
+	return b.String()
+}
+
+// zshQuote escapes the specified text to be used in a single quoted option specification of '_arguments'.
+func zshQuote(s string) string {
+	return strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`).Replace(s)
+}
+
+func zshCompletion(options []completedOption) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, `#compdef rego
+# zsh completion for rego, generated by 'rego completion zsh'.
+
+_rego_names() {
+	local -a names
+	names=(${(f)"$(rego %v $1 2>/dev/null)"})
+	_describe -t $1 $1 names
+}
+
+_rego() {
+	local state
+
+	_arguments -s \
+`, completeCommand)
+
+	for _, o := range options {
+		var action string
+
+		if o.takesValue {
+			switch {
+			case len(o.values) > 0:
+				action = fmt.Sprintf(":%v:(%v)", o.long, strings.Join(o.values, " "))
+			case len(o.names) > 0:
+				action = fmt.Sprintf(":%v:_rego_names %v", o.long, o.names)
+			case o.dir:
+				action = fmt.Sprintf(":%v:_files -/", o.long)
+			case o.file:
+				action = fmt.Sprintf(":%v:_files", o.long)
+			default:
+				action = fmt.Sprintf(":%v: ", o.long)
+			}
+		}
+
+		description := "[" + zshQuote(o.description) + "]" + action
+
+		if len(o.short) > 0 {
+			fmt.Fprintf(&b, "\t\t'(-%v --%v)'{-%v,--%v}'%v' \\\n", o.short, o.long, o.short, o.long, description)
+		} else {
+			fmt.Fprintf(&b, "\t\t'--%v%v' \\\n", o.long, description)
+		}
+	}
+
+	fmt.Fprintf(&b, `		'1:command:(%v)' \
+		'*::argument:->arguments'
+
+	if [[ $state == arguments && $words[1] == %v ]]; then
+		_values shell %v
+	fi
+}
+
+_rego "$@"
+`, strings.Join(completedCommands, " "), completionCommand, strings.Join(completionShells, " "))
+
+	return b.String()
+}
+
+// fishQuote escapes the specified text to be used in a single quoted fish string.
+func fishQuote(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s)
+}
+
+func fishCompletion(options []completedOption) string {
+	var b strings.Builder
+
+	b.WriteString(`# fish completion for rego, generated by 'rego completion fish'.
+complete -c rego -f
+`)
+
+	for _, c := range completedCommands {
+		fmt.Fprintf(&b, "complete -c rego -n __fish_use_subcommand -a %v\n", c)
+	}
+
+	fmt.Fprintf(&b, "complete -c rego -n '__fish_seen_subcommand_from %v' -a '%v'\n", completionCommand, strings.Join(completionShells, " "))
+
+	for _, o := range options {
+		line := "complete -c rego"
+
+		if len(o.short) > 0 {
+			line += " -s " + o.short
+		}
+
+		line += " -l " + o.long
+
+		if o.takesValue {
+			switch {
+			case len(o.values) > 0:
+				line += fmt.Sprintf(" -x -a '%v'", strings.Join(o.values, " "))
+			case len(o.names) > 0:
+				line += fmt.Sprintf(" -x -a '(rego %v %v 2>/dev/null)'", completeCommand, o.names)
+			case o.dir:
+				line += " -x -a '(__fish_complete_directories)'"
+			case o.file:
+				line += " -r -F"
+			default:
+				line += " -x"
+			}
+		}
+
+		fmt.Fprintf(&b, "%v -d '%v'\n", line, fishQuote(o.description))
+	}
+
+	return b.String()
+}
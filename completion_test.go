@@ -0,0 +1,140 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	assert.Equal(t, "The branch name", summary("The branch name, picked by default"))
+	assert.Equal(t, "Shows more verbose output", summary("Shows more verbose output (e.g. true)"))
+	assert.Equal(t, "Prints the version and exits", summary("Prints the version and exits"))
+}
+
+func TestCompletedOptions(t *testing.T) {
+	options := map[string]completedOption{}
+
+	for _, o := range completedOptions(optionsParser("/work")) {
+		options[o.long] = o
+	}
+
+	assert.Equal(t, completedOption{long: "branch", short: "b", description: options["branch"].description, takesValue: true, names: branchNames}, options["branch"])
+	assert.Equal(t, []string{"text", "json"}, options["log-format"].values)
+	assert.True(t, options["work-directory"].dir)
+	assert.True(t, options["config"].file)
+	assert.False(t, options["verbose"].takesValue)
+	assert.Contains(t, options, "help")
+	assert.NotContains(t, options, "command")
+}
+
+func TestCompletionScript(t *testing.T) {
+	parser := optionsParser("/work")
+
+	bash, err := completionScript(parser, []string{"bash"})
+
+	if assert.Nil(t, err) {
+		assert.Contains(t, bash, "\t-t|--tag)\n\t\tCOMPREPLY=($(compgen -W \"$(rego __complete tags 2>/dev/null)\" -- \"$cur\"))")
+		assert.Contains(t, bash, `COMPREPLY=($(compgen -W "error warn info debug trace" -- "$cur"))`)
+		assert.Contains(t, bash, "complete -F _rego rego")
+	}
+
+	zsh, err := completionScript(parser, []string{"zsh"})
+
+	if assert.Nil(t, err) {
+		assert.True(t, strings.HasPrefix(zsh, "#compdef rego\n"))
+		assert.Contains(t, zsh, "'(-R --ref)'{-R,--ref}'[Any revision expression understood by '\\''git rev-parse'\\''")
+		assert.Contains(t, zsh, "'--git-backend[The way the repository is read]:git-backend:(exec native)' \\\n")
+	}
+
+	fish, err := completionScript(parser, []string{"fish"})
+
+	if assert.Nil(t, err) {
+		assert.Contains(t, fish, "complete -c rego -s b -l branch -x -a '(rego __complete branches 2>/dev/null)'")
+		assert.Contains(t, fish, "complete -c rego -l manifest -r -F")
+		assert.Contains(t, fish, "complete -c rego -n __fish_use_subcommand -a lint-commits\n")
+	}
+
+	_, err = completionScript(parser, []string{"powershell"})
+	assert.EqualError(t, err, "unknown shell 'powershell', one of bash, zsh, fish is expected")
+
+	_, err = completionScript(parser, nil)
+	assert.NotNil(t, err)
+}
+
+func TestCompletionScript_Syntax(t *testing.T) {
+	for _, shell := range completionShells {
+		path, err := exec.LookPath(shell)
+
+		if err != nil {
+			continue
+		}
+
+		script, err := completionScript(optionsParser("/work"), []string{shell})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file := filepath.Join(t.TempDir(), "rego."+shell)
+
+		if err = ioutil.WriteFile(file, []byte(script), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := exec.Command(path, "-n", file).CombinedOutput()
+		assert.Nil(t, err, "%v: %s", shell, out)
+	}
+}
+
+func TestCompleteNames(t *testing.T) {
+	fake := NewFakeCommander(
+		CommandCall{Name: "git", Args: []string{"for-each-ref", "--format=%(refname)", "refs/heads"}, Output: "refs/heads/main\nrefs/heads/develop"},
+		CommandCall{Name: "git", Args: []string{"tag", "--list"}, Output: "v1.0\nv1.1"},
+		CommandCall{
+			Name:   "git",
+			Args:   []string{"for-each-ref", "--format=%(refname)", "refs/heads", "refs/remotes"},
+			Output: "refs/heads/main\nrefs/remotes/origin/HEAD\nrefs/remotes/origin/main",
+		},
+		CommandCall{Name: "git", Args: []string{"tag", "--list"}, Output: "v1.0"},
+	)
+
+	g := &Git{WorkDir: "/work", Commander: fake}
+
+	out, err := completeNames(g, []string{branchNames})
+	assert.Nil(t, err)
+	assert.Equal(t, "develop"+NewLine()+"main", out)
+
+	out, err = completeNames(g, []string{tagNames})
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.0"+NewLine()+"v1.1", out)
+
+	out, err = completeNames(g, []string{refNames})
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Join([]string{"main", "origin/main", "v1.0"}, NewLine()), out)
+
+	_, err = completeNames(g, []string{"remotes"})
+	assert.NotNil(t, err)
+
+	assert.Empty(t, fake.Remaining())
+}
@@ -17,11 +17,18 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	getopt "github.com/kesselborn/go-getopt"
+	"github.com/pkg/errors"
 )
 
 // GitCommit is the git commit hash string,
@@ -40,27 +47,123 @@ var ReleaseVersion string
 // gets passed from the command line using a binary release of this tool.
 var GoVersion string
 
+const (
+	releaseCommand     = "release"
+	lintCommitsCommand = "lint-commits"
+	completionCommand  = "completion"
+	manCommand         = "man"
+	doctorCommand      = "doctor"
+	initCommand        = "init"
+	completeCommand    = "__complete"
+	defaultConfigFile  = ".rego"
+	defaultMainPattern = "./..."
+	gitFlowBranch      = "develop"
+)
+
+// commands is the set of the commands accepted as the first argument.
+var commands = map[string]bool{
+	releaseCommand:     true,
+	lintCommitsCommand: true,
+	completionCommand:  true,
+	manCommand:         true,
+	doctorCommand:      true,
+	initCommand:        true,
+	completeCommand:    true,
+}
+
 type configurations struct {
+	Command         string
+	Arguments       []string
 	WorkDir         string
+	ConfigFile      string
 	Tag             string
+	Ref             string
+	Reference       Reference
 	Release         string
 	IgnoreTagPrefix string
 	Package         string
 	Commit          string
 	Branch          string
-	Verbose         bool
+	GitFlow         bool
+	Build           BuildOptions
+	Targets         []BuildTarget
+	Variants        []Variant
+	Toolchains      []Toolchain
+	MainPattern     string
+	Binaries        []Binary
+	AllowDirty      bool
+	Ignore          IgnoreRules
+	NoModuleScope   bool
+	Module          ModuleScope
+	TreeState       TreeState
+	Manifest        string
+	CheckRemote     bool
+	Remote          string
+	Offline         bool
+	Policy          Policy
+	PolicyOnly      bool
+	Signatures      SignatureRequirements
+	Signed          VerifiedSignatures
+	Commits         CommitRules
+	Since           string
+	Gates           []Gate
+	SkipGates       bool
+	Timeout         time.Duration
+	Wait            time.Duration
+	Context         context.Context
+	GitBackend      string
+	LogLevel        slog.Level
+	LogFormat       string
+	Quiet           bool
+	Logger          *slog.Logger
+	ErrorFormat     string
+	JSON            bool
+	VersionFile     string
 }
 
-func configure(conf *configurations) (string, error) {
+// fileConfigurations is the structure of the JSON configuration file.
+type fileConfigurations struct {
+	Build      BuildOptions          `json:"build"`
+	Targets    []BuildTarget         `json:"targets"`
+	Variants   []Variant             `json:"variants"`
+	Toolchain  string                `json:"toolchain"`
+	Toolchains []string              `json:"toolchains"`
+	Main       string                `json:"main"`
+	Binaries   []Binary              `json:"binaries"`
+	Ignore     []string              `json:"ignore"`
+	GitFlow    bool                  `json:"gitflow"`
+	Policy     Policy                `json:"policy"`
+	Signatures SignatureRequirements `json:"signatures"`
+	Commits    CommitRules           `json:"commits"`
+	Gates      []Gate                `json:"gates"`
+}
 
-	var e error
-	var workDirectory string
+// readConfigFile reads the JSON configuration file at the specified path,
+// a missing file is only an error if it is required.
+func readConfigFile(path string, required bool) (*fileConfigurations, error) {
+	var content []byte
+	var err error
 
-	if workDirectory, e = os.Getwd(); e != nil {
-		return "", e
+	fc := &fileConfigurations{}
+
+	if content, err = ioutil.ReadFile(path); err != nil {
+		if os.IsNotExist(err) && !required {
+			return fc, nil
+		}
+		return nil, err
+	}
+
+	if err = json.Unmarshal(content, fc); err != nil {
+		return nil, errors.Wrapf(err, "invalid configuration file '%v'", path)
 	}
 
-	parser := getopt.Options{
+	return fc, nil
+}
+
+// optionsParser returns the definitions of the options of rego, the working directory is the default one, they are
+// also the source of the shell completion scripts and the man page.
+func optionsParser(workDirectory string) getopt.Options {
+	return getopt.Options{
 		Description: "Builds and installs a binary release of a Golang source code while embedding its release information - through a group of exported public variables in the source - based on the current status of its Git repository, all the source files must be committed into the local repository before running this command or it will complain, this tool assumes that Golang (with a valid 'GOROOT' and 'GOPATH' environment variables) and Git source control are installed and fully working though shell.",
 		Definitions: []getopt.Option{
 			{
@@ -68,11 +171,21 @@ func configure(conf *configurations) (string, error) {
 				Description:      "The working directory that contains the project source files and its Git repository",
 				Flags:            getopt.Optional | getopt.ExampleIsDefault,
 				DefaultValue:     workDirectory,
+			}, {
+				OptionDefinition: "config|C|REGO_CONFIG",
+				Description:      "The JSON configuration file that holds the build options, the cross-build targets and the other settings that are not available as options, relative paths are resolved against the '--work-directory', if not specified the file '" + defaultConfigFile + "' is used when it exists in the '--work-directory', options passed in the command line or the environment take precedence over the ones in this file",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
 			}, {
 				OptionDefinition: "branch|b|REGO_BRANCH",
-				Description:      "The branch name of where the binary release source is going to be taken from, the command automatically picks the most recent commit hash in the specified branch, the commit hash string is passed to the binary release while building through the public variable 'GitCommit'",
+				Description:      "The branch name of where the binary release source is going to be taken from, the command automatically picks the most recent commit hash in the specified branch, the commit hash string is passed to the binary release while building through the public variable 'GitCommit', if not specified the default branch is detected through 'origin/HEAD', the 'init.defaultBranch' configuration, the currently checked out branch, then 'main' and 'master', or '" + gitFlowBranch + "' if '--gitflow' is specified",
 				Flags:            getopt.Optional | getopt.ExampleIsDefault,
-				DefaultValue:     "develop",
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "gitflow|F|REGO_GITFLOW",
+				Description:      "Follows the GitFlow branching model, where releases are taken by default from the '" + gitFlowBranch + "' branch",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
 			}, {
 				OptionDefinition: "commit|c|REGO_COMMIT",
 				Description:      "The commit hash string of where the binary release source is going to be taken from, specifying this option causes the '--branch' option to be ignored since this option is more specific, the commit hash string is passed to the binary release while building through the public variable 'GitCommit'",
@@ -83,6 +196,11 @@ func configure(conf *configurations) (string, error) {
 				Description:      "The tag name of where the binary release source is going to be taken from, causes the '--branch' and '--commit' options to be ignored since this option is more specific, the commit hash string is passed to the binary release while building through the public variable 'GitCommit'",
 				Flags:            getopt.Optional | getopt.ExampleIsDefault,
 				DefaultValue:     "",
+			}, {
+				OptionDefinition: "ref|R|REGO_REF",
+				Description:      "Any revision expression understood by 'git rev-parse' (e.g. a short commit hash, 'HEAD~3', 'origin/main' or 'v1.2^{}') of where the binary release source is going to be taken from, causes the '--branch', '--commit' and '--tag' options to be ignored since this option is more specific, if it refers to a tag the release version is calculated the same way as the '--tag' option, the reference name and kind are passed to the binary release while building through the public variables 'GitRef' and 'GitRefKind'",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
 			}, {
 				OptionDefinition: "release|r|REGO_RELEASE",
 				Description:      "The string that is meant to represent the final binary release version, if the '--tag' option is specified this option is automatically calculated with consideration of '--ignore-tag-prefix' option if specified to represent the tag name, the value of this option is passed to the binary release while building through the public variable 'ReleaseVersion'",
@@ -99,9 +217,164 @@ func configure(conf *configurations) (string, error) {
 				Description:      "If the '--tag' option is specified, this option trims the specified prefix off the tag name while calculating the release version string",
 				Flags:            getopt.Optional | getopt.ExampleIsDefault,
 				DefaultValue:     "",
+			}, {
+				OptionDefinition: "tags|T|REGO_TAGS",
+				Description:      "The comma separated list of build tags passed to the compiler through '-tags'",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     []string{},
+			}, {
+				OptionDefinition: "ldflags||REGO_LDFLAGS",
+				Description:      "The extra linker flags (e.g. '-s -w') merged with the '-X' flags that inject the release information, since values starting with '-' are not accepted in the command line use the environment variable or the configuration file to pass them",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "gcflags||REGO_GCFLAGS",
+				Description:      "The flags passed to the compiler through '-gcflags'",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "asmflags||REGO_ASMFLAGS",
+				Description:      "The flags passed to the assembler through '-asmflags'",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "race||REGO_RACE",
+				Description:      "Enables the data race detection through '-race'",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
+			}, {
+				OptionDefinition: "buildmode||REGO_BUILDMODE",
+				Description:      "The build mode passed through '-buildmode' (e.g. 'pie')",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "goflags||REGO_GOFLAGS",
+				Description:      "The value of the 'GOFLAGS' environment variable used while building (e.g. '-trimpath -mod=readonly')",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "release-info||REGO_RELEASE_INFO",
+				Description:      "The way the release information is embedded into the binaries, either '" + ldflagsReleaseInfo + "' (the default) which passes it through the '-X' linker flags, or '" + sourceReleaseInfo + "' which generates a '" + releaseInfoFile + "' file setting the variables into their packages while building, for the build systems dropping or overriding the linker flags, the file is removed once built",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "variant|V|REGO_VARIANT",
+				Description:      "The comma separated list of the names of the variants to build out of the ones defined in the configuration file, all of them are built if not specified",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     []string{},
+			}, {
+				OptionDefinition: "go|G|REGO_GO",
+				Description:      "The comma separated list of Go toolchains used in every build step, each is either the path of a go binary or a Go version (e.g. 'go1.22.3') selected through 'GOTOOLCHAIN', the same release is built once with every toolchain, the 'go' found in the 'PATH' is used if not specified",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     []string{},
+			}, {
+				OptionDefinition: "main|m|REGO_MAIN",
+				Description:      "The package pattern matching the main packages to build (e.g. './cmd/...'), each is built with the same release information into a binary named after its directory unless overridden in the configuration file, defaults to '" + defaultMainPattern + "'",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "allow-dirty||REGO_ALLOW_DIRTY",
+				Description:      "Allows building the working tree with its uncommitted/untracked files on top of the checked out commit, which causes the '--branch' option to be ignored, the suffix '+dirty' is appended to the release version and 'dirty' is passed to the binary release through the public variable 'GitTreeState', this option can not be combined with the '--tag' option",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
+			}, {
+				OptionDefinition: "no-module-scope||REGO_NO_MODULE_SCOPE",
+				Description:      "By default if the Go module of the '--work-directory' lives in a subdirectory of its Git repository (e.g. in a monorepo), only the changes under the module directory and its locally replaced dependencies are checked for uncommitted files, and the '--tag' option is first looked up prefixed with the module path (e.g. 'services/api/v1.3.0'), this option considers the whole repository instead",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
+			}, {
+				OptionDefinition: "check-remote||REGO_CHECK_REMOTE",
+				Description:      "Verifies that the release is taken from pushed commits before building, which is that the target commit is found in the remote-tracking branch of the same name (or in any branch of the remote if not releasing a branch), that the local branch is not behind its upstream branch when it tracks that remote, and that the tag exists in the remote repository pointing to the same commit",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
+			}, {
+				OptionDefinition: "remote||REGO_REMOTE",
+				Description:      "The name of the remote repository verified by '--check-remote'",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     defaultRemote,
+			}, {
+				OptionDefinition: "offline||REGO_OFFLINE",
+				Description:      "Skips fetching the remote repository verified by '--check-remote' and relies on the existing remote-tracking branches instead, the tag is not verified then",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
+			}, {
+				OptionDefinition: "verify-signatures||REGO_VERIFY_SIGNATURES",
+				Description:      "The comma separated list of what must carry a valid GPG or SSH signature before building, 'commit' for the target commit and 'tag' for the released tag, the signer identities are recorded in the manifest and passed to the binary release while building through the public variables 'GitCommitSigner' and 'GitTagSigner'",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     []string{},
+			}, {
+				OptionDefinition: "allowed-signers||REGO_ALLOWED_SIGNERS",
+				Description:      "The SSH allowed signers file used to verify SSH signatures, relative paths are resolved against the '--work-directory', the 'gpg.ssh.allowedSignersFile' git configuration is used if not specified",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "since||REGO_SINCE",
+				Description:      "The revision after which the commits are checked against the commit rules of the configuration file, defaults to the most recent tag before the target commit",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "policy-only||REGO_POLICY_ONLY",
+				Description:      "Only validates the release against the policy rules of the configuration file without building it, e.g. in CI gates",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
+			}, {
+				OptionDefinition: "skip-gates||REGO_SKIP_GATES",
+				Description:      "Skips the quality gates of the configuration file that run before building",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
+			}, {
+				OptionDefinition: "timeout||REGO_TIMEOUT",
+				Description:      "The maximum duration of the whole run (e.g. '90s' or '10m'), once exceeded the running git and Go commands are killed and the run fails, there is no time limit if not specified",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "wait||REGO_WAIT",
+				Description:      "The maximum duration to wait for another rego run on the same repository to release its lock (e.g. '30s' or '5m'), rego fails right away if the repository is locked and this is not specified",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "git-backend||REGO_GIT_BACKEND",
+				Description:      "The way the repository is read, either 'exec' to run the git binary or 'native' to read the '.git' directory directly where git is not installed, in which case the target commit must already be checked out",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     execGitBackend,
+			}, {
+				OptionDefinition: "manifest||REGO_MANIFEST",
+				Description:      "The path of the file to write the JSON release manifest to, which describes the release information, the working tree state and the built binaries, no manifest is written if not specified",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "version-file||REGO_VERSION_FILE",
+				Description:      "The name of the Go file the '" + initCommand + "' command declares the release variables in, within the '--package' package or every main package",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     defaultVersionFile,
 			}, {
 				OptionDefinition: "verbose",
-				Description:      "Shows more verbose output",
+				Description:      "Shows more verbose output, which is the same as '--log-level debug'",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
+			}, {
+				OptionDefinition: "log-level||REGO_LOG_LEVEL",
+				Description:      "The minimum level of the logged messages, one of 'error', 'warn', 'info', 'debug' or 'trace' which also logs every command run, 'info' is used if not specified",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     "",
+			}, {
+				OptionDefinition: "log-format||REGO_LOG_FORMAT",
+				Description:      "The format of the logged messages, either 'text' or 'json' which writes a JSON object per line",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     textLogFormat,
+			}, {
+				OptionDefinition: "quiet|q|REGO_QUIET",
+				Description:      "Only prints the final result or error",
+				Flags:            getopt.Flag,
+				DefaultValue:     false,
+			}, {
+				OptionDefinition: "error-format||REGO_ERROR_FORMAT",
+				Description:      "The format of the final error, either 'text' or 'json' which writes an object holding its exit code, category, message and details to the standard error",
+				Flags:            getopt.Optional | getopt.ExampleIsDefault,
+				DefaultValue:     textErrorFormat,
+			}, {
+				OptionDefinition: "json||REGO_JSON",
+				Description:      "Prints the report of the '" + doctorCommand + "' command as a JSON document",
 				Flags:            getopt.Flag,
 				DefaultValue:     false,
 			}, {
@@ -109,14 +382,32 @@ func configure(conf *configurations) (string, error) {
 				Description:      "Prints the version and exits",
 				Flags:            getopt.Flag,
 				DefaultValue:     false,
+			}, {
+				OptionDefinition: "command",
+				Description:      "The command to run, either '" + releaseCommand + "' (the default) which validates and builds the release, '" + lintCommitsCommand + "' which only checks the messages of the commits since the previous tag against the commit rules of the configuration file, '" + doctorCommand + "' which diagnoses the environment, '" + initCommand + "' which declares the release variables in the project and writes a starter configuration file, '" + completionCommand + " bash|zsh|fish' which prints the shell completion script of the specified shell, or '" + manCommand + "' which prints the man page",
+				Flags:            getopt.IsArg | getopt.Optional,
+				DefaultValue:     "",
 			},
 		},
 	}
+}
+
+func configure(conf *configurations) (string, error) {
+
+	var e error
+	var workDirectory string
+
+	if workDirectory, e = os.Getwd(); e != nil {
+		return "", e
+	}
+
+	parser := optionsParser(workDirectory)
 
 	var err *getopt.GetOptError
 	var options map[string]getopt.OptionValue
+	var arguments []string
 
-	if options, _, _, err = parser.ParseCommandLine(); err != nil {
+	if options, arguments, _, err = parser.ParseCommandLine(); err != nil {
 		return "", fmt.Errorf("failed with error code: %v, %v", err.ErrorCode, err.Error())
 	} else if help, wantsHelp := options["help"]; wantsHelp && help.String == "usage" {
 		return parser.Usage(), nil
@@ -130,14 +421,355 @@ func configure(conf *configurations) (string, error) {
 			GoVersion), nil
 	}
 
-	conf.Verbose = options["verbose"].Bool
+	if conf.ErrorFormat = strings.TrimSpace(options["error-format"].String); !errorFormats[conf.ErrorFormat] {
+		conf.ErrorFormat = textErrorFormat
+		return "", fmt.Errorf("unknown error format '%v', either '%v' or '%v' is expected", options["error-format"].String, textErrorFormat, jsonErrorFormat)
+	}
+
+	conf.Command = releaseCommand
+
+	if len(arguments) > 0 {
+		conf.Command, conf.Arguments = arguments[0], arguments[1:]
+	}
+
+	if !commands[conf.Command] {
+		return "", fmt.Errorf("unknown command '%v'", conf.Command)
+	}
+
+	switch conf.Command {
+	case completionCommand:
+		return completionScript(parser, conf.Arguments)
+	case manCommand:
+		return manPage(parser), nil
+	case completeCommand:
+		return completeNames(&Git{WorkDir: strings.TrimSpace(options["work-directory"].String)}, conf.Arguments)
+	}
+
+	if e = configureLogging(conf, options); e != nil {
+		return "", e
+	}
+
 	conf.WorkDir = strings.TrimSpace(options["work-directory"].String)
 	conf.Package = strings.TrimSpace(options["package"].String)
 	conf.Branch = strings.TrimSpace(options["branch"].String)
 	conf.Commit = strings.TrimSpace(options["commit"].String)
 	conf.Tag = strings.TrimSpace(options["tag"].String)
+	conf.Ref = strings.TrimSpace(options["ref"].String)
 	conf.IgnoreTagPrefix = strings.TrimSpace(options["ignore-tag-prefix"].String)
 	conf.Release = strings.TrimSpace(options["release"].String)
+	conf.AllowDirty = options["allow-dirty"].Bool
+	conf.NoModuleScope = options["no-module-scope"].Bool
+	conf.Manifest = strings.TrimSpace(options["manifest"].String)
+	conf.CheckRemote = options["check-remote"].Bool
+	conf.Remote = strings.TrimSpace(options["remote"].String)
+	conf.Offline = options["offline"].Bool
+	conf.PolicyOnly = options["policy-only"].Bool
+	conf.Since = strings.TrimSpace(options["since"].String)
+	conf.SkipGates = options["skip-gates"].Bool
+	conf.JSON = options["json"].Bool
+	conf.VersionFile = strings.TrimSpace(options["version-file"].String)
+
+	if timeout := strings.TrimSpace(options["timeout"].String); len(timeout) > 0 {
+		if conf.Timeout, e = time.ParseDuration(timeout); e != nil || conf.Timeout <= 0 {
+			return "", fmt.Errorf("invalid timeout '%v', a positive duration is expected (e.g. '90s' or '10m')", timeout)
+		}
+	}
+
+	if wait := strings.TrimSpace(options["wait"].String); len(wait) > 0 {
+		if conf.Wait, e = time.ParseDuration(wait); e != nil || conf.Wait <= 0 {
+			return "", fmt.Errorf("invalid wait '%v', a positive duration is expected (e.g. '30s' or '5m')", wait)
+		}
+	}
+
+	if conf.GitBackend = strings.TrimSpace(options["git-backend"].String); !gitBackends[conf.GitBackend] {
+		return "", fmt.Errorf("unknown git backend '%v', either '%v' or '%v' is expected", conf.GitBackend, execGitBackend, nativeGitBackend)
+	}
+
+	if conf.AllowDirty && len(conf.Tag) > 0 {
+		return "", fmt.Errorf("the '--allow-dirty' option can not be used while releasing the tag '%v'", conf.Tag)
+	}
+
+	// the configuration file is written by 'init' rather than read.
+	if conf.Command == initCommand {
+		conf.ConfigFile, _ = configFilePath(conf.WorkDir, options)
+
+		if conf.MainPattern = strings.TrimSpace(options["main"].String); len(conf.MainPattern) == 0 {
+			conf.MainPattern = defaultMainPattern
+		}
+
+		return "", nil
+	}
+
+	if e = readBuildConfigurations(conf, options); e != nil {
+		return "", e
+	} else if e = validateGitBackend(conf); e != nil {
+		return "", e
+	}
 
 	return "", nil
 }
+
+// validateGitBackend returns an error if the native git backend is selected along with any of the features that run
+// the git binary, which the native backend is meant to do without.
+func validateGitBackend(conf *configurations) error {
+	if conf.GitBackend != nativeGitBackend || (conf.Command != releaseCommand && conf.Command != lintCommitsCommand) {
+		return nil
+	}
+
+	var features []string
+
+	if conf.Command == lintCommitsCommand || conf.Commits.Enabled() {
+		features = append(features, "the commit rules")
+	}
+
+	if conf.AllowDirty {
+		features = append(features, "'--allow-dirty'")
+	}
+
+	if conf.CheckRemote {
+		features = append(features, "'--check-remote'")
+	}
+
+	if conf.Policy.Enabled() {
+		features = append(features, "the policy rules")
+	}
+
+	if conf.Signatures.Enabled() {
+		features = append(features, "the signature verification")
+	}
+
+	if !conf.SkipGates && !conf.PolicyOnly {
+		for _, gate := range conf.Gates {
+			if gate.Name == TidyGate || gate.Name == GenerateGate {
+				features = append(features, fmt.Sprintf("the '%v' gate", gate.Name))
+			}
+		}
+	}
+
+	if len(features) > 0 {
+		return fmt.Errorf("%v can not be used with the '%v' git backend since they run the git binary",
+			strings.Join(features, ", "), nativeGitBackend)
+	}
+
+	return nil
+}
+
+// configFilePath returns the path of the configuration file, either specified by '--config' or the default one in the
+// working directory, along with whether it has been specified.
+func configFilePath(workDir string, options map[string]getopt.OptionValue) (string, bool) {
+	file := strings.TrimSpace(options["config"].String)
+	specified := len(file) > 0
+
+	if !specified {
+		file = defaultConfigFile
+	}
+
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(workDir, file)
+	}
+
+	return file, specified
+}
+
+// configureLogging reads the logging options and creates the logger, the messages are logged to the standard error.
+func configureLogging(conf *configurations, options map[string]getopt.OptionValue) error {
+	level := strings.TrimSpace(options["log-level"].String)
+	conf.Quiet = options["quiet"].Bool
+	conf.LogLevel = slog.LevelInfo
+
+	if conf.Quiet && (len(level) > 0 || options["verbose"].Bool) {
+		return errors.New("the '--quiet' option can not be used along with '--verbose' or '--log-level'")
+	} else if conf.Quiet {
+		conf.LogLevel = slog.LevelError
+	} else if options["verbose"].Bool {
+		conf.LogLevel = slog.LevelDebug
+	}
+
+	if l, ok := logLevels[level]; ok {
+		conf.LogLevel = l
+	} else if len(level) > 0 {
+		return fmt.Errorf("unknown log level '%v', one of 'error', 'warn', 'info', 'debug' or 'trace' is expected", level)
+	}
+
+	if conf.LogFormat = strings.TrimSpace(options["log-format"].String); !logFormats[conf.LogFormat] {
+		return fmt.Errorf("unknown log format '%v', either '%v' or '%v' is expected", conf.LogFormat, textLogFormat, jsonLogFormat)
+	}
+
+	conf.Logger = NewLogger(os.Stderr, conf.LogFormat, conf.LogLevel, NewRedactor(os.Environ()))
+
+	return nil
+}
+
+// readBuildConfigurations reads the build options and the targets from the configuration file,
+// then overrides the build options with the ones explicitly set in the command line or the environment.
+func readBuildConfigurations(conf *configurations, options map[string]getopt.OptionValue) error {
+	var fc *fileConfigurations
+	var required bool
+	var err error
+
+	conf.ConfigFile, required = configFilePath(conf.WorkDir, options)
+
+	if fc, err = readConfigFile(conf.ConfigFile, required); err != nil {
+		return err
+	}
+
+	conf.Targets = fc.Targets
+
+	if conf.Variants, err = selectVariants(fc.Variants, options["variant"]); err != nil {
+		return err
+	}
+
+	toolchains := append([]string{fc.Toolchain}, fc.Toolchains...)
+
+	if options["go"].Set {
+		toolchains = options["go"].StrArray
+	}
+
+	conf.Binaries = fc.Binaries
+	conf.GitFlow = options["gitflow"].Bool || fc.GitFlow
+	conf.Policy = fc.Policy
+	conf.Commits = fc.Commits
+
+	if err = ValidateGates(fc.Gates); err != nil {
+		return err
+	}
+
+	conf.Gates = fc.Gates
+	conf.Signatures = fc.Signatures
+
+	if options["verify-signatures"].Set {
+		conf.Signatures.Commit, conf.Signatures.Tag = false, false
+
+		for _, name := range options["verify-signatures"].StrArray {
+			switch strings.TrimSpace(name) {
+			case "commit":
+				conf.Signatures.Commit = true
+			case "tag":
+				conf.Signatures.Tag = true
+			case "":
+			default:
+				return fmt.Errorf("invalid signature '%v', only 'commit' and 'tag' signatures can be verified", name)
+			}
+		}
+	}
+
+	if file := strings.TrimSpace(options["allowed-signers"].String); len(file) > 0 {
+		conf.Signatures.AllowedSignersFile = file
+	}
+
+	if file := conf.Signatures.AllowedSignersFile; len(file) > 0 && !filepath.IsAbs(file) {
+		conf.Signatures.AllowedSignersFile = filepath.Join(conf.WorkDir, file)
+	}
+
+	var patterns []string
+
+	if patterns, err = readIgnoreFile(conf.WorkDir); err != nil {
+		return err
+	} else if conf.Ignore, err = NewIgnoreRules(append(fc.Ignore, patterns...)...); err != nil {
+		return err
+	}
+	conf.MainPattern = strings.TrimSpace(options["main"].String)
+
+	if len(conf.MainPattern) == 0 {
+		conf.MainPattern = fc.Main
+	}
+
+	if len(conf.MainPattern) == 0 {
+		conf.MainPattern = defaultMainPattern
+	}
+
+	conf.Toolchains = nil
+
+	for _, spec := range toolchains {
+		if spec = strings.TrimSpace(spec); len(spec) > 0 {
+			t, err := ParseToolchain(spec)
+
+			if err != nil {
+				return err
+			}
+
+			conf.Toolchains = append(conf.Toolchains, t)
+		}
+	}
+
+	var cli BuildOptions
+
+	if options["tags"].Set {
+		for _, tag := range options["tags"].StrArray {
+			if tag = strings.TrimSpace(tag); len(tag) > 0 {
+				cli.Tags = append(cli.Tags, tag)
+			}
+		}
+	}
+
+	cli.LDFlags = strings.TrimSpace(options["ldflags"].String)
+	cli.GCFlags = strings.TrimSpace(options["gcflags"].String)
+	cli.ASMFlags = strings.TrimSpace(options["asmflags"].String)
+	if options["race"].Bool {
+		race := true
+		cli.Race = &race
+	}
+	cli.BuildMode = strings.TrimSpace(options["buildmode"].String)
+	cli.GoFlags = strings.TrimSpace(options["goflags"].String)
+	cli.ReleaseInfo = strings.TrimSpace(options["release-info"].String)
+
+	conf.Build = fc.Build.Merge(cli)
+
+	if err = ValidReleaseInfo(conf.Build.ReleaseInfo); err != nil {
+		return err
+	}
+
+	for _, t := range conf.Targets {
+		if err = ValidReleaseInfo(t.Build.ReleaseInfo); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range conf.Variants {
+		if err = ValidReleaseInfo(v.Build.ReleaseInfo); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// selectVariants returns the configured variants named in the specified option value in the configured order,
+// or all of them if the option is not set, it returns an error if a name is not configured.
+func selectVariants(variants []Variant, option getopt.OptionValue) ([]Variant, error) {
+	names := make(map[string]bool)
+
+	for _, v := range variants {
+		if len(v.Name) == 0 {
+			return nil, fmt.Errorf("a variant without a name is found in the configuration file")
+		} else if names[v.Name] {
+			return nil, fmt.Errorf("variant '%v' is defined more than once", v.Name)
+		}
+		names[v.Name] = true
+	}
+
+	if !option.Set {
+		return variants, nil
+	}
+
+	selected := make(map[string]bool)
+
+	for _, name := range option.StrArray {
+		if name = strings.TrimSpace(name); len(name) == 0 {
+			continue
+		} else if !names[name] {
+			return nil, fmt.Errorf("variant '%v' is not found in the configuration file", name)
+		}
+		selected[name] = true
+	}
+
+	var result []Variant
+
+	for _, v := range variants {
+		if selected[v.Name] {
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+}
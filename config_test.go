@@ -0,0 +1,127 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	getopt "github.com/kesselborn/go-getopt"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "test_rego_config_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, defaultConfigFile)
+
+	if err = ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestReadConfigFile_Success(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"build": {"tags": ["netgo"], "ldflags": "-s -w", "buildmode": "pie"},
+		"targets": [{"os": "linux", "arch": "amd64"}, {"os": "darwin", "arch": "arm64", "build": {"tags": ["cgo"]}}]
+	}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	fc, err := readConfigFile(path, true)
+
+	assert.Nil(t, err)
+	assert.Equal(t, BuildOptions{Tags: []string{"netgo"}, LDFlags: "-s -w", BuildMode: "pie"}, fc.Build)
+	assert.Equal(t, []BuildTarget{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "darwin", Arch: "arm64", Build: BuildOptions{Tags: []string{"cgo"}}},
+	}, fc.Targets)
+}
+
+func TestReadConfigFile_FailureInvalid(t *testing.T) {
+	path := writeConfigFile(t, `{"build": `)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	fc, err := readConfigFile(path, false)
+
+	assert.Nil(t, fc)
+	assert.NotNil(t, err)
+}
+
+func TestReadConfigFile_Missing(t *testing.T) {
+	fc, err := readConfigFile("/some-nonexistent-path/.rego", false)
+	assert.Nil(t, err)
+	assert.Equal(t, &fileConfigurations{}, fc)
+
+	fc, err = readConfigFile("/some-nonexistent-path/.rego", true)
+	assert.Nil(t, fc)
+	assert.NotNil(t, err)
+}
+
+func TestSelectVariants(t *testing.T) {
+	variants := []Variant{{Name: "release"}, {Name: "debug"}, {Name: "coverage"}}
+
+	selected, err := selectVariants(variants, getopt.OptionValue{})
+	assert.Nil(t, err)
+	assert.Equal(t, variants, selected)
+
+	selected, err = selectVariants(variants, getopt.OptionValue{Set: true, StrArray: []string{"coverage", " release"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []Variant{{Name: "release"}, {Name: "coverage"}}, selected)
+
+	_, err = selectVariants(variants, getopt.OptionValue{Set: true, StrArray: []string{"race"}})
+	assert.NotNil(t, err)
+
+	_, err = selectVariants([]Variant{{Name: "debug"}, {Name: "debug"}}, getopt.OptionValue{})
+	assert.NotNil(t, err)
+
+	_, err = selectVariants([]Variant{{}}, getopt.OptionValue{})
+	assert.NotNil(t, err)
+}
+
+func TestValidateGitBackend(t *testing.T) {
+	conf := &configurations{
+		Command:     releaseCommand,
+		GitBackend:  nativeGitBackend,
+		CheckRemote: true,
+		Policy:      Policy{TagPattern: `^v\d+$`},
+		Signatures:  SignatureRequirements{Commit: true},
+		Gates:       []Gate{{Name: VetGate}, {Name: TidyGate}, {Name: GenerateGate}},
+	}
+
+	assert.EqualError(t, validateGitBackend(conf), "'--check-remote', the policy rules, the signature verification, "+
+		"the 'tidy' gate, the 'generate' gate can not be used with the 'native' git backend since they run the git binary")
+
+	conf.GitBackend = execGitBackend
+	assert.Nil(t, validateGitBackend(conf))
+
+	conf = &configurations{Command: releaseCommand, GitBackend: nativeGitBackend, Gates: []Gate{{Name: VetGate}, {Name: TidyGate}}, SkipGates: true}
+	assert.Nil(t, validateGitBackend(conf))
+
+	conf.Command = lintCommitsCommand
+	assert.EqualError(t, validateGitBackend(conf), "the commit rules can not be used with the 'native' git backend since they run the git binary")
+
+	conf.Command = doctorCommand
+	assert.Nil(t, validateGitBackend(conf))
+}
@@ -51,10 +51,196 @@ Only as a prerequisite the developer has to define the following public variable
 	// gets passed from the command line using a binary release of this tool.
 	var GoVersion string
 
+Optionally, the following public variables are injected as well if declared:
+
+	// BuildVariant is the name of the variant this binary is built as, e.g. 'release' or 'debug'.
+	var BuildVariant string
+
+	// GitTreeState is either 'clean', 'ignored' if this binary is built with uncommitted files matching the ignore rules only,
+	// or 'dirty' if this binary is built with uncommitted changes.
+	var GitTreeState string
+
+	// GitRef is the name of the branch, tag, commit or revision expression this binary is built from.
+	var GitRef string
+
+	// GitRefKind is one of 'branch', 'remote-branch', 'tag', 'commit' or 'expression'.
+	var GitRefKind string
+
+	// GitCommitSigner and GitTagSigner are the identities of the verified signers of the commit and the tag.
+	var GitCommitSigner, GitTagSigner string
+
+The 'init' command declares the required variables instead, along with a 'VersionFlag' helper defining a '-version' flag that prints them, in a 'version.go' file (or the one named by '--version-file') of every main package, or of the '--package' package which is created within the module if needed. It also writes a starter configuration file whose policy follows git-flow if a 'develop' branch exists and whose tag pattern follows the style of the existing tags. Nothing is written if any of the files exists or any of the declarations is already found:
+
+	$ rego init
+	$ rego init --package example.com/project/internal/version --version-file release.go
+
 For detailed help type:
 
 	$ rego --help
 
+The shell completion scripts and the man page are generated from the same option definitions, the completion of the '--branch', '--tag', '--ref' and '--since' options lists the names of the repository of the current directory (or '$REGO_WORK_DIR'):
+
+	$ source <(rego completion bash)
+	$ rego completion zsh > "${fpath[1]}/_rego"
+	$ rego completion fish > ~/.config/fish/completions/rego.fish
+	$ rego man > /usr/local/share/man/man1/rego.1
+
+The 'doctor' command diagnoses the common setup problems before a release, i.e. git missing from the 'PATH', a go command not matching the Go root rego has been built with or the 'GOROOT' variable, neither 'GOBIN' nor 'GOPATH' set or the install directory missing from the 'PATH', the module mode disabled or no 'go.mod' file found, the release variables not declared in the target package, and a shallow clone or a repository without tags. Each check prints 'pass', 'warn' or 'fail' along with a hint to fix it, the command fails with the exit code of the first failed check, and '--json' prints the checks as a JSON document for automation:
+
+	$ rego doctor --json
+
+Configuration
+
+Besides the command line options and their environment variables, the build options can be kept in a JSON configuration file, which is read by default from '.rego' in the working directory if it exists, or from the file specified by '--config'. Options passed in the command line or the environment take precedence over the ones in the file, and each of the cross-build targets may override the common build options for itself only, e.g. '"race": false' disables the race detection enabled in the common options. The binaries of the targets other than the host are built with 'go build -o' into the '<os>_<arch>' directory of the install location, since 'go install' refuses to install them once 'GOBIN' is set:
+
+	{
+		"build": {
+			"tags": ["netgo", "osusergo"],
+			"ldflags": "-s -w",
+			"buildmode": "pie",
+			"goflags": "-trimpath"
+		},
+		"targets": [
+			{"os": "linux", "arch": "amd64", "build": {"race": true}},
+			{"os": "windows", "arch": "amd64", "build": {"buildmode": "exe"}}
+		]
+	}
+
+Some build systems drop or override the linker flags, so the '-X' flags injecting the release information would be lost. The 'releaseInfo' build option (or '--release-info source') generates instead a '0_rego_release_info.go' file into every package declaring any of the variables in the files of the build, which is only compiled with the 'rego_release_info' build tag rego adds. The file sets each declared variable right after it is initialized, whatever its declaration, so that the other package variables initialized from it (e.g. 'var Version = "v" + ReleaseVersion') see the value, and the build fails if a declared variable is not a string or the package has a file compiled before the generated one. The file is removed once the binary is built, whether the build succeeds or fails, a run fails rather than overwriting an existing one, and since it is untracked while building, the Go toolchain stamps the binary with 'vcs.modified=true' unless the 'goflags' build option is set to '-buildvcs=false':
+
+	{
+		"build": {"releaseInfo": "source"}
+	}
+
+Variants build different flavors of the same release in one run, each with its own build options, environment and binary name suffix, they all share the same commit, release version and build timestamp, and the variant name is injected through the optional public variable 'BuildVariant', use '--variant' to build only some of them:
+
+	{
+		"variants": [
+			{"name": "release", "build": {"ldflags": "-s -w"}, "env": ["CGO_ENABLED=0"]},
+			{"name": "debug", "build": {"gcflags": "all=-N -l"}, "suffix": "-debug"},
+			{"name": "coverage", "build": {"cover": true}, "suffix": "-cover"}
+		]
+	}
+
+The Go toolchain used for every step (checking the module, cleaning, building and reporting 'GoVersion') is the 'go' found in the 'PATH' unless specified by '--go' or the configuration file, either as the path of a go binary or a Go release selected through 'GOTOOLCHAIN' (e.g. 'go1.22.0' rather than the language version 'go1.22'), the toolchain version (its 'GOVERSION') must satisfy the 'go' and 'toolchain' directives of the 'go.mod' file of the module being built and of the 'go.work' file of its workspace if any, a development toolchain satisfies any of them, and if more than one toolchain is specified the same release is built with each of them, appending the toolchain version to the binary name:
+
+	{
+		"toolchains": ["go1.21.13", "go1.22.6", "/usr/local/go/bin/go"]
+	}
+
+Every main package matching the '--main' pattern (defaults to './...') is built with the same release information into a binary named after its directory, the binaries may be renamed and their injected variables may be mapped to the names declared in their sources, either within the '--package' package or fully qualified:
+
+	{
+		"main": "./cmd/...",
+		"binaries": [
+			{"package": "./cmd/api", "name": "api-server"},
+			{"package": "./cmd/worker", "variables": {"GitCommit": "example.com/project/internal/version.Commit"}}
+		]
+	}
+
+Quality gates configured in the configuration file run in order in the checked out working tree before compiling: 'vet' and 'test' run 'go vet' and 'go test' on the chosen packages with extra flags, 'tidy' fails if 'go mod tidy' changes any file, 'verify' runs 'go mod verify', 'gofmt' fails on unformatted files, and 'generate' fails if 'go generate' changes any file but the 'go.mod' and 'go.sum' files the go command may update by itself, the files changed by 'tidy' and 'generate' are reverted, except the files that were already uncommitted (in a dirty build) which make the gate fail if changed and are left as they are. Each gate is timed, its output is captured and reported on failure, and its result is recorded in the manifest, a failed gate stops the release unless it is advisory, and '--skip-gates' skips them all:
+
+	{
+		"gates": [
+			{"name": "vet"},
+			{"name": "test", "packages": ["./..."], "flags": ["-short", "-count=1"]},
+			{"name": "tidy"},
+			{"name": "verify"},
+			{"name": "gofmt", "advisory": true},
+			{"name": "generate"}
+		]
+	}
+
+The whole run may be limited by '--timeout' (e.g. '--timeout 10m'), once exceeded the running git and Go commands are killed and rego fails. Using '--verbose' also streams the output of the long-running commands, like fetching, building and the quality gates, while they run.
+
+A release holds a lock of the repository for its whole run, which is the file 'rego.lock' in the '.git' directory describing the process ID, the host and the start time of the run, so that two runs on the same working directory do not interleave their checkouts and builds. A run finding the repository locked fails right away naming the holder, unless '--wait' (e.g. '--wait 5m') is specified to wait for the lock to be released. The lock of a run that is not running anymore on the same host is considered stale and removed, while the runs of '--policy-only' and 'lint-commits' do not take the lock since they do not modify the working tree. The git commands failing because another process, like an IDE, holds the 'index.lock' file are retried a few times with an increasing delay.
+
+The progress is logged to the standard error, while the final result is printed on the standard output. '--log-level' picks the minimum level of the logged messages among 'error', 'warn', 'info' (the default), 'debug' (the same as '--verbose'), which also logs the output of the long-running commands and the duration of every step of the release, and 'trace', which also logs every git and Go command run along with its duration and exit code. '--log-format json' writes every message as a JSON object per line instead of text, e.g. to be collected in CI, and '--quiet' only prints the final result or error. The values of the environment variables whose names look sensitive (e.g. 'GITHUB_TOKEN' or 'NPM_PASSWORD'), the credentials of URLs and the tokens of well-known formats are masked in the logged messages.
+
+Where the git binary is not installed, e.g. in minimal build containers, '--git-backend native' reads the '.git' directory directly instead: the loose and packed references, the packfiles, the annotated tags and the index, which is enough to pick the release, verify the requested commit, describe it and detect uncommitted changes. The native backend can not check out, so the release commit must already be checked out, and it does not support submodules. The remote check, the signature verification, the commit rules, the policy rules, the dirty builds and the 'tidy' and 'generate' gates run the git binary, so they are rejected along with the native backend, while the Go toolchain still runs it to stamp the version control information unless the 'goflags' build option is set to '-buildvcs=false'. Repositories using the 'sha256' object format, the 'reftable' references storage or alternate object directories are not read and fail with an error.
+
+Release manifest and dirty builds
+
+Files that are allowed to be uncommitted (e.g. generated documentation) can be listed as glob patterns relative to the repository root, either one per line in a '.regoignore' file in the working directory or in the configuration file, the remaining uncommitted files are reported grouped by category (conflicted, submodule, renamed, staged, unstaged and untracked):
+
+	{
+		"ignore": ["docs/", "CHANGELOG.md", "testdata/golden/"]
+	}
+
+The ignored files are still built into the release, so they are listed in the manifest as 'ignoredFiles' and the tree state is reported as 'ignored' instead of 'clean', both in the manifest and in the injected 'GitTreeState' variable.
+
+In a monorepo where the Go module of the working directory lives in a subdirectory of the repository, only the changes under the module directory and its locally replaced dependencies (through 'replace' directives in 'go.mod', the ones outside the repository are left out) are checked, only the commits touching them are checked against the commit rules since the previous tag of the module, the '--tag' option is first looked up prefixed with the module path (e.g. '--tag v1.3.0' resolves 'services/api/v1.3.0') and the prefix is dropped from the release version, use '--no-module-scope' to consider the whole repository instead.
+
+Instead of '--branch', '--commit' or '--tag', the source may be picked by '--ref' which accepts any revision expression understood by 'git rev-parse', e.g. a short commit hash, 'HEAD~3', a remote branch like 'origin/main' or a peeled tag like 'v1.2^{}', the expression is resolved to a full commit hash and the kind and name of the reference it is based on are injected and recorded in the manifest.
+
+To make sure a release is never built from commits that were not pushed, '--check-remote' fetches the remote repository ('origin' unless specified by '--remote') and verifies that the target commit is found in the remote-tracking branch of the same name, or in any branch of the remote if not releasing a branch, that the local branch is not behind its upstream branch when it tracks that remote, and that the released tag exists in the remote repository pointing to the same commit. Use '--offline' to rely on the existing remote-tracking branches without fetching, in which case the tag is not verified.
+
+Using '--manifest' writes a JSON file describing the release information and the built binaries. Uncommitted changes make rego fail by default, unless '--allow-dirty' is specified which builds the working tree as is on top of the checked out commit, appends '+dirty' to the release version and records the modified files along with a hash of the changes in the manifest, it can not be used while releasing a tag.
+
+Git submodules are initialized and checked out at the commits recorded in the released commit before building, rego fails if any of them could not be brought in sync, and the path and commit of each submodule are recorded in the manifest. While building a dirty working tree the submodules are left as they are, only uninitialized or conflicted ones make rego fail.
+
+Release policy
+
+The configuration file may hold a policy the release must comply with, which is evaluated while validating the release and reports every violated rule by name: 'allowed-branches' restricts the branches a release may be taken from (only checked when releasing a branch, a tag, commit or revision expression is not checked against it), 'tag-pattern' requires the released tags (without their module path prefix) to match a regular expression, 'release-branches' requires the commit of a released tag that is not a pre-release to be found in one of the listed branches, 'prerelease-branches' requires a pre-release (a release version with a semantic versioning pre-release part) to be taken from one of the listed branches, and 'increasing-versions' requires a released tag version to be greater than the versions of all the other tags (only checked when releasing a tag). Combine 'tag-pattern' with 'release-branches' to restrict where the released tags come from. Branches are matched as glob patterns against both the local and the remote-tracking branches, and '--policy-only' validates the release against the policy without building it, e.g. in CI gates:
+
+	{
+		"policy": {
+			"allowedBranches": ["main", "release/*"],
+			"tagPattern": "^v\\d+\\.\\d+\\.\\d+(-[0-9A-Za-z.-]+)?$",
+			"releaseBranches": ["main"],
+			"prereleaseBranches": ["release/*"],
+			"increasingVersions": true
+		}
+	}
+
+For supply-chain security, '--verify-signatures commit,tag' (or the configuration file) requires the target commit and/or the released tag to carry a valid GPG or SSH signature, verified through 'git verify-commit' and 'git verify-tag' using the SSH allowed signers file specified by '--allowed-signers' if any, and optionally restricts the signers to a list of identities or key fingerprints, the release fails otherwise and the signers are recorded in the manifest:
+
+	{
+		"signatures": {
+			"commit": true,
+			"tag": true,
+			"allowedSignersFile": ".github/allowed_signers",
+			"signers": ["release@example.com", "4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A"]
+		}
+	}
+
+A GPG signature matches a listed key fingerprint (or long key ID) of its signing key or of its primary key, while it only matches a listed identity, either the whole user ID or its email address, if the key is fully or ultimately trusted in the keyring since anyone can create a key claiming any user ID. An SSH signature matches the principal it is verified for through the allowed signers file, which is where the SSH identities are bound to their keys, or its exact key fingerprint.
+
+The messages of the commits since the previous tag (or since '--since') up to the released commit may be required to carry a 'Signed-off-by' trailer (Developer Certificate of Origin), to follow the Conventional Commits specification with an optional list of allowed types, and to reference a ticket key matching a regular expression, every non-compliant commit is reported with its reasons. The same check can be run alone, e.g. in pull request pipelines, where the checked out commit is checked unless specified otherwise:
+
+	$ rego lint-commits --since origin/main
+
+	{
+		"commits": {
+			"signedOff": true,
+			"conventional": true,
+			"types": ["feat", "fix", "docs", "refactor", "test", "chore"],
+			"ticketPattern": "[A-Z]+-\\d+"
+		}
+	}
+
+Exit codes
+
+Every failure makes rego exit with the code of its category, which CI scripts may branch on, the codes are stable across releases:
+
+	2    usage         an invalid option, configuration file or combination of them
+	3    missing-tool  the git or Go executable is not found
+	4    git           a git command has failed or the git repository can not be read
+	5    dirty         uncommitted changes or submodules that are not in sync prevent the release
+	6    reference     the branch, tag, commit or reference is not found or can not be released
+	7    policy        a release policy rule is violated or a commit message is not compliant
+	8    verification  a signature is not valid or the release is not in sync with the remote
+	9    toolchain     a Go command has failed or the Go toolchain does not satisfy the module
+	10   gate          a required quality gate has failed
+	11   build         a binary could not be compiled
+	12   timeout       the '--timeout' duration is exceeded
+	13   locked        the repository is locked by another rego run
+	126  internal      any other failure
+
+Using '--error-format json' writes the final error to the standard error as a JSON object holding its exit code, category, message and details, e.g. the failed command line along with its exit code and standard error, or the uncommitted files:
+
+	{"code":6,"category":"reference","message":"tag 'v2.0' is not found"}
+
 Example
 
 Create a new Golang project named 'example-go', initialize a new git repository and add a 'main.go' file:
@@ -63,6 +249,7 @@ Create a new Golang project named 'example-go', initialize a new git repository
 	&& mkdir example-go \
 	&& cd example-go \
 	&& git init \
+	&& go mod init example-go \
 	&& touch main.go
 
 Edit the recently created file 'main.go' with your favorite editor and paste the following code then save the file:
@@ -89,8 +276,10 @@ Now in the command line type:
 
 Output:
 
-	Uncommitted/untracked files:
-	 ?? main.go
+	error: Uncommitted/untracked files:
+	 untracked:
+	  go.mod
+	  main.go
 
 Oops, looks like we missed something, it appears that we haven't committed all our files, let's commit them and try again:
 
@@ -99,17 +288,11 @@ Oops, looks like we missed something, it appears that we haven't committed all o
 	&& git commit -m 'Initial commit' \
 	&& rego -r 1.0
 
-Output:
-
-	branch 'develop' is not found
-
-It seems that it's trying by default to pull from a branch named 'develop', and that's because it tries to follow GitFlow branching model, and since releasing from 'develop' branch is what you will mostly be doing before merging to the master it will pull from it be default, but our tiny example project has only a 'master' branch so let's be more specific here:
-
-	$ rego -r 1.0 -b master -w $GOPATH/src/example-go
+And here is what we get back on the standard error, where the progress is logged as records followed by their attributes:
 
-And here is what we get back:
+	commit is checked out, don't forget to switch back to your working reference commit=099fcc922b4fb66f2d002db6a4ac63e050916edc
 
-	commit '03c7ac7ddd8563cf513a5925c85193c405d66c12' is checked out, don't forget to switch back to your working reference
+Since no branch is specified, rego detects the default branch of the repository, trying in order the branch 'origin/HEAD' points to, the 'init.defaultBranch' configuration, the currently checked out branch, then 'main' and 'master', which is 'master' in our tiny example project (use '--verbose' to see which rule picked it). Projects following the GitFlow branching model may use '--gitflow' (or '"gitflow": true' in the configuration file) to release from the 'develop' branch by default instead, or simply pick any branch using '--branch'.
 
 It seems that it picked the most recent commit in our selected branch and checked it out for releasing, it also notifies us not to forget to check out our previous branch back again so we don't continue working/committing to an unreferenced branch, so let's checkout the 'master' branch again:
 
@@ -122,9 +305,9 @@ Ok, now we're back on master, but what's happened with our 'example-go' binary t
 Output:
 
 	Release: 1.0
-	Commit: 134492f9327867b715fdc552993305179b7bc23f
-	Build Time: 2017-09-04T19:07:57Z
-	Built with: go version go1.9 linux/amd64
+	Commit: 099fcc922b4fb66f2d002db6a4ac63e050916edc
+	Build Time: 2026-10-19T09:40:58Z
+	Built with: go1.27.1
 
 Finally, we can see our code is built and embedding the correct release information, so now you can try to play more with the command options to see different results, e.g like a different release version (which defaults to SNAPSHOT if not specified) or try to tag your commit and pass the tag name as an option, so refer back to the help page for more information by typing:
 
@@ -0,0 +1,436 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// The statuses of the doctor checks.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// releaseVariables are the variables every release injects, which the target package is expected to declare.
+var releaseVariables = []string{"GitCommit", "BuildTimestamp", "ReleaseVersion", "GoVersion"}
+
+// Check is the result of a diagnostic of the environment rego runs in.
+type Check struct {
+	// Name is the name of the check, e.g. 'git'.
+	Name string `json:"name"`
+	// Status is either 'pass', 'warn' or 'fail'.
+	Status string `json:"status"`
+	// Message describes what has been found.
+	Message string `json:"message"`
+	// Hint describes how to fix a warning or a failure.
+	Hint string `json:"hint,omitempty"`
+	// category is the category of the failure of the check.
+	category ErrorCategory
+}
+
+// Doctor diagnoses the common setup problems of the environment rego runs in.
+type Doctor struct {
+	// Git runs the git commands in the working directory.
+	Git *Git
+	// GoTools runs the Go commands in the working directory.
+	GoTools *GoTools
+	// Package is the package expected to declare the release variables, 'main' for the main packages.
+	Package string
+	// MainPattern is the pattern matching the main packages, e.g. './...'.
+	MainPattern string
+	// Binaries are the configured binaries which may map the variables to other names.
+	Binaries []Binary
+	// Getenv returns the value of an environment variable, os.Getenv is used if not set.
+	Getenv func(key string) string
+	// GOROOT is the Go root rego has been built with, runtime.GOROOT() is used if not set.
+	GOROOT string
+}
+
+func (d *Doctor) getenv(key string) string {
+	if d.Getenv != nil {
+		return d.Getenv(key)
+	}
+	return os.Getenv(key)
+}
+
+// Run runs all the checks in order, the checks depending on a failed one are skipped.
+func (d *Doctor) Run() []Check {
+	var checks []Check
+
+	gitCheck := d.checkGit()
+	checks = append(checks, gitCheck)
+
+	if gitCheck.Status != checkFail {
+		repository := d.checkRepository()
+		checks = append(checks, repository)
+
+		if repository.Status != checkFail {
+			checks = append(checks, d.checkHistory())
+		}
+	}
+
+	goCheck, env := d.checkGo()
+	checks = append(checks, goCheck)
+
+	if goCheck.Status != checkFail {
+		checks = append(checks, d.checkGoRoot(env), d.checkInstallDir(env))
+
+		modules := d.checkModules(env)
+		checks = append(checks, modules)
+
+		if modules.Status != checkFail {
+			checks = append(checks, d.checkVariables())
+		}
+	}
+
+	return checks
+}
+
+func (d *Doctor) checkGit() Check {
+	version, err := d.Git.Version()
+
+	if category, _ := Categorize(err); category == MissingToolError {
+		return Check{Name: "git", Status: checkFail, Message: "git is not found in the PATH", category: MissingToolError,
+			Hint: "install git from https://git-scm.com, or use '--git-backend native' to only read the repository"}
+	} else if err != nil {
+		return Check{Name: "git", Status: checkFail, Message: err.Error(), category: GitError, Hint: "make sure git runs"}
+	}
+
+	return Check{Name: "git", Status: checkPass, Message: version}
+}
+
+func (d *Doctor) checkRepository() Check {
+	top, err := d.Git.TopLevel()
+
+	if err != nil {
+		return Check{Name: "repository", Status: checkFail, Message: fmt.Sprintf("'%v' is not in a git repository", d.Git.WorkDir),
+			category: GitError, Hint: "run rego in the working tree of the project or use '--work-directory'"}
+	}
+
+	return Check{Name: "repository", Status: checkPass, Message: fmt.Sprintf("repository at '%v'", top)}
+}
+
+func (d *Doctor) checkHistory() Check {
+	const hint = "run 'git fetch --unshallow --tags', or clone the whole history in CI, e.g. 'fetch-depth: 0' on GitHub Actions"
+
+	shallow, err := d.Git.IsShallow()
+
+	if err != nil {
+		return Check{Name: "history", Status: checkFail, Message: err.Error(), category: GitError}
+	} else if shallow {
+		return Check{Name: "history", Status: checkWarn, Message: "the repository is a shallow clone, the tags and the previous releases may be missing", Hint: hint}
+	}
+
+	tags, err := d.Git.Tags()
+
+	if err != nil {
+		return Check{Name: "history", Status: checkFail, Message: err.Error(), category: GitError}
+	} else if len(tags) == 0 {
+		return Check{Name: "history", Status: checkWarn, Message: "no tags are found", Hint: "run 'git fetch --tags' if the tags exist in the remote repository"}
+	}
+
+	return Check{Name: "history", Status: checkPass, Message: fmt.Sprintf("%v tags are found", len(tags))}
+}
+
+func (d *Doctor) checkGo() (Check, map[string]string) {
+	env, err := d.GoTools.GoEnv("GOVERSION", "GOROOT", "GOPATH", "GOBIN", "GO111MODULE", "GOMOD", "GOEXE")
+
+	if category, _ := Categorize(err); category == MissingToolError {
+		return Check{Name: "go", Status: checkFail, Message: fmt.Sprintf("'%v' is not found in the PATH", d.GoTools.Toolchain.command()),
+			category: MissingToolError, Hint: "install Go from https://go.dev/dl and add its 'bin' directory to the PATH"}, nil
+	} else if err != nil {
+		return Check{Name: "go", Status: checkFail, Message: err.Error(), category: ToolchainError, Hint: "make sure the go command runs"}, nil
+	}
+
+	return Check{Name: "go", Status: checkPass, Message: fmt.Sprintf("%v at '%v'", env["GOVERSION"], env["GOROOT"])}, env
+}
+
+func (d *Doctor) checkGoRoot(env map[string]string) Check {
+	built := d.GOROOT
+
+	if len(built) == 0 {
+		built = runtime.GOROOT()
+	}
+
+	if root := d.getenv("GOROOT"); len(root) > 0 && filepath.Clean(root) != filepath.Clean(env["GOROOT"]) {
+		return Check{Name: "goroot", Status: checkWarn,
+			Message: fmt.Sprintf("the 'GOROOT' variable '%v' does not match the root '%v' of the go command in the PATH", root, env["GOROOT"]),
+			Hint:    "unset 'GOROOT' and let the go command find its own root"}
+	}
+
+	// the root rego has been built with only tells something if it exists on this host.
+	if info, err := os.Stat(built); err == nil && info.IsDir() && filepath.Clean(built) != filepath.Clean(env["GOROOT"]) {
+		return Check{Name: "goroot", Status: checkWarn,
+			Message: fmt.Sprintf("the go command in the PATH is at '%v' while rego has been built with '%v'", env["GOROOT"], built),
+			Hint:    fmt.Sprintf("put '%v' first in the PATH if it is the intended Go installation, or use '--go'", filepath.Join(built, "bin"))}
+	}
+
+	return Check{Name: "goroot", Status: checkPass, Message: fmt.Sprintf("GOROOT is '%v'", env["GOROOT"])}
+}
+
+func (d *Doctor) checkInstallDir(env map[string]string) Check {
+	dir := env["GOBIN"]
+
+	if len(dir) == 0 {
+		dir = filepath.Join(filepath.SplitList(env["GOPATH"])[0], "bin")
+	}
+
+	if len(d.getenv("GOBIN")) == 0 && len(d.getenv("GOPATH")) == 0 {
+		return Check{Name: "install-dir", Status: checkWarn,
+			Message: fmt.Sprintf("neither 'GOBIN' nor 'GOPATH' is set, the binaries are installed into the default '%v'", dir),
+			Hint:    "set 'GOBIN' to choose where the binaries are installed"}
+	}
+
+	for _, p := range filepath.SplitList(d.getenv("PATH")) {
+		if len(p) > 0 && filepath.Clean(p) == filepath.Clean(dir) {
+			return Check{Name: "install-dir", Status: checkPass, Message: fmt.Sprintf("the binaries are installed into '%v'", dir)}
+		}
+	}
+
+	return Check{Name: "install-dir", Status: checkWarn,
+		Message: fmt.Sprintf("the binaries are installed into '%v' which is not in the PATH", dir),
+		Hint:    fmt.Sprintf("add '%v' to the PATH to run the installed binaries", dir)}
+}
+
+func (d *Doctor) checkModules(env map[string]string) Check {
+	if env["GO111MODULE"] == "off" {
+		return Check{Name: "modules", Status: checkFail, Message: "the module mode is disabled by 'GO111MODULE=off'",
+			category: ToolchainError, Hint: "unset 'GO111MODULE' or set it to 'on'"}
+	} else if mod := env["GOMOD"]; len(mod) == 0 || mod == os.DevNull {
+		return Check{Name: "modules", Status: checkFail, Message: fmt.Sprintf("no 'go.mod' file is found in '%v' or its parent directories", d.GoTools.WorkDir),
+			category: ToolchainError, Hint: "run 'go mod init <module path>' in the project root"}
+	}
+
+	return Check{Name: "modules", Status: checkPass, Message: fmt.Sprintf("module '%v'", env["GOMOD"])}
+}
+
+func (d *Doctor) checkVariables() Check {
+	// expected maps the directories of the packages to the names of the variables they are expected to declare.
+	expected := make(map[string][]string)
+	var dirs []string
+
+	if len(d.Package) == 0 || d.Package == "main" {
+		packages, err := d.GoTools.MainPackages(d.MainPattern)
+
+		if err != nil {
+			return Check{Name: "variables", Status: checkFail, Message: err.Error(), category: ToolchainError}
+		} else if len(packages) == 0 {
+			return Check{Name: "variables", Status: checkFail, Message: fmt.Sprintf("no main packages match '%v'", d.MainPattern),
+				category: UsageError, Hint: "use '--main' to match the main packages of the project"}
+		}
+
+		for _, p := range packages {
+			var mappings map[string]string
+
+			for _, b := range d.Binaries {
+				if b.Matches(d.GoTools.WorkDir, p) {
+					mappings = b.Variables
+				}
+			}
+
+			dirs = append(dirs, p.Dir)
+			expected[p.Dir] = expectedVariables(mappings)
+		}
+	} else {
+		dir, err := d.GoTools.PackageDir(d.Package)
+
+		if err != nil {
+			return Check{Name: "variables", Status: checkFail, Message: fmt.Sprintf("package '%v' is not found: %v", d.Package, err.Error()),
+				category: UsageError, Hint: "use '--package' with the import path of the package declaring the variables"}
+		}
+
+		dirs = append(dirs, dir)
+		expected[dir] = releaseVariables
+	}
+
+	var problems []string
+
+	for _, dir := range dirs {
+		declared, err := declaredVariables(dir)
+
+		if err != nil {
+			return Check{Name: "variables", Status: checkFail, Message: err.Error(), category: UsageError}
+		}
+
+		var missing []string
+
+		for _, v := range expected[dir] {
+			if !declared[v] {
+				missing = append(missing, v)
+			}
+		}
+
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("'%v' does not declare %v", dir, strings.Join(missing, ", ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return Check{Name: "variables", Status: checkFail, Message: strings.Join(problems, "; "), category: UsageError,
+			Hint: fmt.Sprintf("declare them in the package, e.g. 'var %v string', or map them in the 'binaries' of the configuration file", strings.Join(releaseVariables, ", "))}
+	}
+
+	return Check{Name: "variables", Status: checkPass, Message: fmt.Sprintf("the release variables are declared in %v packages", len(dirs))}
+}
+
+// expectedVariables returns the names of the release variables expected in a main package with the specified mappings,
+// the variables mapped to nothing or to other packages are left out.
+func expectedVariables(mappings map[string]string) []string {
+	var names []string
+
+	for _, v := range releaseVariables {
+		if mapped, found := mappings[v]; found {
+			if v = strings.TrimSpace(mapped); len(v) == 0 || strings.Contains(v, ".") {
+				continue
+			}
+		}
+
+		names = append(names, v)
+	}
+
+	return names
+}
+
+// declaredVariables returns the names of the package level variables declared in the Go files of the specified
+// directory, the test files are left out.
+func declaredVariables(dir string) (map[string]bool, error) {
+	_, declarations, err := packageDeclarations(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	declared := make(map[string]bool)
+
+	for name, kind := range declarations {
+		if kind == token.VAR {
+			declared[name] = true
+		}
+	}
+
+	return declared, nil
+}
+
+// packageDeclarations parses the Go files of the specified directory, the test files are left out, it returns the
+// package name along with the kinds of the package level declarations (i.e. token.VAR, token.CONST, token.TYPE or
+// token.FUNC) by their names, the methods are left out.
+func packageDeclarations(dir string) (string, map[string]token.Token, error) {
+	files, err := ioutil.ReadDir(dir)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	var name string
+
+	declarations := make(map[string]token.Token)
+	fset := token.NewFileSet()
+
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".go") || strings.HasSuffix(f.Name(), "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filepath.Join(dir, f.Name()), nil, parser.SkipObjectResolution)
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		name = file.Name.Name
+
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					declarations[d.Name.Name] = token.FUNC
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							declarations[n.Name] = d.Tok
+						}
+					case *ast.TypeSpec:
+						declarations[s.Name.Name] = token.TYPE
+					}
+				}
+			}
+		}
+	}
+
+	return name, declarations, nil
+}
+
+// DoctorReport returns the specified checks either as a JSON document or as text, along with an error of the category
+// of the first failed check if any.
+func DoctorReport(checks []Check, asJSON bool) (string, error) {
+	var report string
+	var failed []Check
+	var warnings int
+
+	for _, c := range checks {
+		switch c.Status {
+		case checkFail:
+			failed = append(failed, c)
+		case checkWarn:
+			warnings++
+		}
+	}
+
+	if asJSON {
+		content, err := json.MarshalIndent(struct {
+			Checks []Check `json:"checks"`
+		}{checks}, "", "  ")
+
+		if err != nil {
+			return "", err
+		}
+
+		report = string(content)
+	} else {
+		var lines []string
+
+		for _, c := range checks {
+			lines = append(lines, fmt.Sprintf("%-4v  %-11v  %v", c.Status, c.Name, c.Message))
+
+			if len(c.Hint) > 0 {
+				lines = append(lines, fmt.Sprintf("%19v%v", "hint: ", c.Hint))
+			}
+		}
+
+		report = strings.Join(append(lines, fmt.Sprintf("%v checks: %v failed, %v warnings", len(checks), len(failed), warnings)), NewLine())
+	}
+
+	if len(failed) > 0 {
+		return report, newError(failed[0].category, "%v of %v checks failed", len(failed), len(checks))
+	}
+
+	return report, nil
+}
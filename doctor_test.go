@@ -0,0 +1,193 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func doctorPackage(t *testing.T, source string) string {
+	dir := t.TempDir()
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "main_test.go"), []byte("package main\n\nvar GoVersion string\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestDoctor_Run(t *testing.T) {
+	dir := doctorPackage(t, "package main\n\nvar GitCommit, BuildTimestamp, ReleaseVersion string\n\nfunc main() {}\n")
+	env := map[string]string{"GOPATH": "/home/user/go", "PATH": "/usr/bin"}
+
+	fake := NewFakeCommander(
+		CommandCall{Name: "git", Args: []string{"--version"}, Output: "git version 2.39.5"},
+		CommandCall{Name: "git", Args: []string{"rev-parse", "--show-toplevel"}, Output: "/work"},
+		CommandCall{Name: "git", Args: []string{"rev-parse", "--is-shallow-repository"}, Output: "true"},
+		CommandCall{
+			Name:   "go",
+			Args:   []string{"env", "-json", "GOVERSION", "GOROOT", "GOPATH", "GOBIN", "GO111MODULE", "GOMOD", "GOEXE"},
+			Output: `{"GOVERSION": "go1.22.6", "GOROOT": "/usr/local/go", "GOPATH": "/home/user/go", "GOMOD": "/work/go.mod"}`,
+		},
+		CommandCall{
+			Name:   "go",
+			Args:   []string{"list", "-f", "{{if eq .Name \"main\"}}{{.ImportPath}}\t{{.Dir}}{{end}}", "./..."},
+			Output: "example.com/project\t" + dir,
+		},
+	)
+
+	d := &Doctor{
+		Git:         &Git{WorkDir: "/work", Commander: fake},
+		GoTools:     &GoTools{WorkDir: "/work", Commander: fake},
+		Package:     "main",
+		MainPattern: "./...",
+		Getenv:      func(key string) string { return env[key] },
+		GOROOT:      filepath.Join(dir, "missing"),
+	}
+
+	checks := d.Run()
+
+	var statuses []string
+
+	for _, c := range checks {
+		statuses = append(statuses, c.Name+":"+c.Status)
+	}
+
+	assert.Equal(t, []string{
+		"git:pass", "repository:pass", "history:warn", "go:pass", "goroot:pass", "install-dir:warn", "modules:pass", "variables:fail",
+	}, statuses)
+	assert.Equal(t, "go1.22.6 at '/usr/local/go'", checks[3].Message)
+	assert.Equal(t, "the binaries are installed into '/home/user/go/bin' which is not in the PATH", checks[5].Message)
+	assert.Equal(t, "'"+dir+"' does not declare GoVersion", checks[7].Message)
+	assert.NotEmpty(t, checks[7].Hint)
+
+	assert.Empty(t, fake.Remaining())
+	assert.Empty(t, fake.Unexpected())
+
+	out, err := DoctorReport(checks, false)
+
+	category, code := Categorize(err)
+	assert.Equal(t, UsageError, category)
+	assert.Equal(t, 2, code)
+	assert.True(t, strings.HasSuffix(out, "8 checks: 1 failed, 2 warnings"), out)
+	assert.Contains(t, out, "warn  history      the repository is a shallow clone")
+}
+
+func TestDoctor_Run_Failures(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	d := &Doctor{
+		Git:         &Git{WorkDir: t.TempDir()},
+		GoTools:     &GoTools{WorkDir: t.TempDir()},
+		MainPattern: "./...",
+		Getenv:      func(string) string { return "" },
+	}
+
+	checks := d.Run()
+
+	if assert.Len(t, checks, 2) {
+		assert.Equal(t, Check{
+			Name:     "git",
+			Status:   checkFail,
+			Message:  "git is not found in the PATH",
+			Hint:     "install git from https://git-scm.com, or use '--git-backend native' to only read the repository",
+			category: MissingToolError,
+		}, checks[0])
+		assert.Equal(t, "go", checks[1].Name)
+		assert.Equal(t, checkFail, checks[1].Status)
+	}
+
+	out, err := DoctorReport(checks, true)
+
+	category, _ := Categorize(err)
+	assert.Equal(t, MissingToolError, category)
+
+	var report struct {
+		Checks []map[string]string `json:"checks"`
+	}
+
+	assert.Nil(t, json.Unmarshal([]byte(out), &report))
+	assert.Len(t, report.Checks, 2)
+	assert.Equal(t, "git", report.Checks[0]["name"])
+	assert.Equal(t, checkFail, report.Checks[0]["status"])
+}
+
+func TestDoctor_CheckModules(t *testing.T) {
+	d := &Doctor{GoTools: &GoTools{WorkDir: "/work"}}
+
+	assert.Equal(t, checkFail, d.checkModules(map[string]string{"GO111MODULE": "off", "GOMOD": "/work/go.mod"}).Status)
+	assert.Equal(t, checkFail, d.checkModules(map[string]string{"GOMOD": os.DevNull}).Status)
+	assert.Equal(t, checkFail, d.checkModules(map[string]string{}).Status)
+	assert.Equal(t, checkPass, d.checkModules(map[string]string{"GOMOD": "/work/go.mod"}).Status)
+}
+
+func TestDoctor_CheckGoRoot(t *testing.T) {
+	root := t.TempDir()
+	env := map[string]string{}
+	d := &Doctor{GOROOT: root, Getenv: func(key string) string { return env[key] }}
+
+	assert.Equal(t, checkPass, d.checkGoRoot(map[string]string{"GOROOT": root}).Status)
+
+	check := d.checkGoRoot(map[string]string{"GOROOT": "/usr/local/go"})
+	assert.Equal(t, checkWarn, check.Status)
+	assert.Contains(t, check.Hint, filepath.Join(root, "bin"))
+
+	env["GOROOT"] = "/opt/go"
+	assert.Equal(t, checkWarn, d.checkGoRoot(map[string]string{"GOROOT": root}).Status)
+}
+
+func TestDoctor_CheckInstallDir(t *testing.T) {
+	env := map[string]string{}
+	d := &Doctor{Getenv: func(key string) string { return env[key] }}
+
+	assert.Equal(t, checkWarn, d.checkInstallDir(map[string]string{"GOPATH": "/home/user/go"}).Status)
+
+	env["GOBIN"] = "/opt/bin"
+	env["PATH"] = strings.Join([]string{"/usr/bin", "/opt/bin"}, string(os.PathListSeparator))
+	assert.Equal(t, checkPass, d.checkInstallDir(map[string]string{"GOBIN": "/opt/bin", "GOPATH": "/home/user/go"}).Status)
+}
+
+func TestExpectedVariables(t *testing.T) {
+	assert.Equal(t, releaseVariables, expectedVariables(nil))
+	assert.Equal(t, []string{"Commit", "BuildTimestamp"}, expectedVariables(map[string]string{
+		"GitCommit":      "Commit",
+		"ReleaseVersion": "example.com/project/internal/version.Version",
+		"GoVersion":      "",
+	}))
+}
+
+func TestDeclaredVariables(t *testing.T) {
+	dir := doctorPackage(t, "package main\n\nvar (\n\tGitCommit string\n\tcount, total = 1, 2\n)\n\nconst ReleaseVersion = \"\"\n")
+
+	declared, err := declaredVariables(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"GitCommit": true, "count": true, "total": true}, declared)
+
+	_, err = declaredVariables(filepath.Join(dir, "missing"))
+	assert.NotNil(t, err)
+}
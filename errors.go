@@ -0,0 +1,269 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"sort"
+)
+
+// ErrorCategory is the category of a failure, every category has its own exit code.
+type ErrorCategory string
+
+// The categories of the failures.
+const (
+	// UsageError is an invalid option, configuration file or combination of them.
+	UsageError ErrorCategory = "usage"
+	// MissingToolError is a missing git or Go executable.
+	MissingToolError ErrorCategory = "missing-tool"
+	// GitError is a failed git command or an unreadable git repository.
+	GitError ErrorCategory = "git"
+	// DirtyError is an uncommitted change or submodule that prevents the release.
+	DirtyError ErrorCategory = "dirty"
+	// ReferenceError is a branch, tag, commit or reference that is not found or can not be released.
+	ReferenceError ErrorCategory = "reference"
+	// PolicyError is a violated release policy rule or a non-compliant commit message.
+	PolicyError ErrorCategory = "policy"
+	// VerificationError is a failed signature verification or a release that is not in sync with the remote.
+	VerificationError ErrorCategory = "verification"
+	// ToolchainError is a failed Go command or a Go toolchain that does not satisfy the module.
+	ToolchainError ErrorCategory = "toolchain"
+	// GateError is a failed required quality gate.
+	GateError ErrorCategory = "gate"
+	// BuildError is a failed compilation of a binary.
+	BuildError ErrorCategory = "build"
+	// TimeoutError is a run that has exceeded the '--timeout' duration.
+	TimeoutError ErrorCategory = "timeout"
+	// LockedError is a repository locked by another run.
+	LockedError ErrorCategory = "locked"
+	// InternalError is any other failure.
+	InternalError ErrorCategory = "internal"
+)
+
+// exitCodes maps the categories of the failures to the exit codes of rego, they are stable across releases.
+var exitCodes = map[ErrorCategory]int{
+	UsageError:        2,
+	MissingToolError:  3,
+	GitError:          4,
+	DirtyError:        5,
+	ReferenceError:    6,
+	PolicyError:       7,
+	VerificationError: 8,
+	ToolchainError:    9,
+	GateError:         10,
+	BuildError:        11,
+	TimeoutError:      12,
+	LockedError:       13,
+	InternalError:     executionErrorCode,
+}
+
+// errorDescriptions describes the failures of every category, e.g. in the man page.
+var errorDescriptions = map[ErrorCategory]string{
+	UsageError:        "an invalid option, configuration file or combination of them",
+	MissingToolError:  "the git or Go executable is not found",
+	GitError:          "a git command has failed or the git repository can not be read",
+	DirtyError:        "uncommitted changes or submodules that are not in sync prevent the release",
+	ReferenceError:    "the branch, tag, commit or reference is not found or can not be released",
+	PolicyError:       "a release policy rule is violated or a commit message is not compliant",
+	VerificationError: "a signature is not valid or the release is not in sync with the remote",
+	ToolchainError:    "a Go command has failed or the Go toolchain does not satisfy the module",
+	GateError:         "a required quality gate has failed",
+	BuildError:        "a binary could not be compiled",
+	TimeoutError:      "the '--timeout' duration is exceeded",
+	LockedError:       "the repository is locked by another rego run",
+	InternalError:     "any other failure",
+}
+
+// The error formats.
+const (
+	textErrorFormat = "text"
+	jsonErrorFormat = "json"
+)
+
+// errorFormats is the set of the supported error formats.
+var errorFormats = map[string]bool{textErrorFormat: true, jsonErrorFormat: true}
+
+// Error is a failure along with its category and the details describing it.
+type Error struct {
+	// Category is the category of the failure, which decides the exit code.
+	Category ErrorCategory
+	// Details holds the machine-readable details of the failure, e.g. the failed command line.
+	Details map[string]interface{}
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the underlying error.
+func (e *Error) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError returns an error of the specified category with a formatted message.
+func newError(category ErrorCategory, format string, args ...interface{}) error {
+	return &Error{Category: category, Err: fmt.Errorf(format, args...)}
+}
+
+// withCategory returns the specified error along with the specified category and details, unless it is nil or it
+// has already been categorized, since the category closest to the failure is the most accurate one.
+func withCategory(category ErrorCategory, err error, details ...interface{}) error {
+	if err == nil || errorOf(err) != nil {
+		return err
+	}
+
+	e := &Error{Category: category, Err: err}
+
+	for i := 0; i+1 < len(details); i += 2 {
+		if e.Details == nil {
+			e.Details = map[string]interface{}{}
+		}
+		e.Details[fmt.Sprint(details[i])] = details[i+1]
+	}
+
+	return e
+}
+
+// causer is implemented by the errors wrapping another one.
+type causer interface {
+	Cause() error
+}
+
+// errorOf returns the first categorized error in the chain of the causes of the specified error, or nil if none.
+func errorOf(err error) *Error {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e
+		} else if _, ok := err.(*ExecError); ok {
+			return nil
+		} else if c, ok := err.(causer); ok {
+			err = c.Cause()
+		} else {
+			return nil
+		}
+	}
+	return nil
+}
+
+// execErrorOf returns the command error in the chain of the causes of the specified error, or nil if none.
+func execErrorOf(err error) *ExecError {
+	for err != nil {
+		if e, ok := err.(*ExecError); ok {
+			return e
+		} else if c, ok := err.(causer); ok {
+			err = c.Cause()
+		} else {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Categorize returns the category of the specified error and its exit code, the uncategorized errors are internal.
+func Categorize(err error) (ErrorCategory, int) {
+	category := InternalError
+
+	if e := errorOf(err); e != nil {
+		category = e.Category
+	}
+
+	return category, exitCodes[category]
+}
+
+// commandError categorizes the error of a command: a missing executable, a killed command on timeout, or else a
+// failure of the specified category along with the command line, the exit code and the standard error.
+func commandError(category ErrorCategory, err error) error {
+	e := execErrorOf(err)
+
+	if e == nil {
+		return withCategory(category, err)
+	}
+
+	if x, ok := e.Err.(*exec.Error); ok && x.Err == exec.ErrNotFound {
+		category = MissingToolError
+	} else if e.Err == context.DeadlineExceeded {
+		category = TimeoutError
+	}
+
+	return withCategory(category, err, "command", e.CommandLine, "exitCode", e.ExitCode, "stderr", e.Stderr)
+}
+
+// categorizedCommand categorizes the errors of a command.
+type categorizedCommand struct {
+	Command
+	category ErrorCategory
+}
+
+func (c *categorizedCommand) Execute(args ...string) (string, error) {
+	out, err := c.Command.Execute(args...)
+	return out, commandError(c.category, err)
+}
+
+func (c *categorizedCommand) ExecuteContext(ctx context.Context, opts ExecOptions, args ...string) (string, error) {
+	out, err := c.Command.ExecuteContext(ctx, opts, args...)
+	return out, commandError(c.category, err)
+}
+
+// writeJSONError writes the specified error as a JSON object holding its exit code, category, message and details,
+// with their secrets masked by the specified redactor.
+func writeJSONError(w io.Writer, err error, redactor *Redactor) error {
+	category, code := Categorize(err)
+
+	report := struct {
+		Code     int                    `json:"code"`
+		Category ErrorCategory          `json:"category"`
+		Message  string                 `json:"message"`
+		Details  map[string]interface{} `json:"details,omitempty"`
+	}{Code: code, Category: category, Message: redactor.Redact(err.Error())}
+
+	if e := errorOf(err); e != nil && len(e.Details) > 0 {
+		report.Details = map[string]interface{}{}
+
+		for k, v := range e.Details {
+			a := redactor.attr(slog.Any(k, v))
+			report.Details[k] = a.Value.Any()
+		}
+	}
+
+	return json.NewEncoder(w).Encode(report)
+}
+
+// ErrorCategories returns the categories of the failures sorted by their exit codes.
+func ErrorCategories() []ErrorCategory {
+	var categories []ErrorCategory
+
+	for c := range exitCodes {
+		categories = append(categories, c)
+	}
+
+	sort.Slice(categories, func(i, j int) bool { return exitCodes[categories[i]] < exitCodes[categories[j]] })
+
+	return categories
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// WorkDirPlaceholder replaces the working directory of the commands in the recorded calls,
+// so that a session recorded in a directory can be replayed in any other.
+const WorkDirPlaceholder = "{{workdir}}"
+
+// CommandCall is an execution of a command along with its result, either scripted for a FakeCommander
+// or captured by a RecordingCommander.
+type CommandCall struct {
+	// Name is the base name of the executable, e.g. 'git'.
+	Name string `json:"name"`
+	// Args is the list of the arguments of the command.
+	Args []string `json:"args"`
+	// Env is the list of the 'KEY=VALUE' pairs extending the environment of the command.
+	Env []string `json:"env,omitempty"`
+	// Output is the trimmed output of the command.
+	Output string `json:"output,omitempty"`
+	// Stderr is the trimmed standard error of a failed command.
+	Stderr string `json:"stderr,omitempty"`
+	// ExitCode is the exit code of a failed command.
+	ExitCode int `json:"exitCode,omitempty"`
+	// Error is the error of a command that has not exited, e.g. not found or killed.
+	Error string `json:"error,omitempty"`
+}
+
+// String returns the command line of this call.
+func (c CommandCall) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// err returns the error this call has resulted in if any.
+func (c CommandCall) err() error {
+	if c.ExitCode == 0 && len(c.Error) == 0 {
+		return nil
+	}
+
+	err := &ExecError{CommandLine: c.String(), ExitCode: c.ExitCode, Stderr: c.Stderr}
+
+	if len(c.Error) > 0 {
+		err.ExitCode, err.Err = -1, errors.New(c.Error)
+	} else {
+		err.Err = fmt.Errorf("exit status %v", c.ExitCode)
+	}
+
+	return err
+}
+
+// equals returns true if this call is an execution of the same command with the same arguments and the same
+// environment as the specified one.
+func (c CommandCall) equals(other CommandCall) bool {
+	return c.Name == other.Name && equalStrings(c.Args, other.Args) && equalStrings(c.Env, other.Env)
+}
+
+// equalStrings returns true if both lists have the same values in the same order.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ReadCommandCalls reads the JSON command calls from the specified golden file.
+func ReadCommandCalls(path string) ([]CommandCall, error) {
+	var calls []CommandCall
+
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(content, &calls); err != nil {
+		return nil, errors.Wrapf(err, "invalid command calls file '%v'", path)
+	}
+
+	return calls, nil
+}
+
+// WriteCommandCalls writes the specified command calls into the specified golden file as JSON.
+func WriteCommandCalls(path string, calls []CommandCall) error {
+	content, err := json.MarshalIndent(calls, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// FakeCommander is a Commander whose commands do not execute anything, but return the results of the scripted calls
+// matching their names, arguments and environments, each scripted call is used once and in order among the identical ones.
+// A command matching none of the remaining calls fails.
+type FakeCommander struct {
+	mutex      sync.Mutex
+	calls      []CommandCall
+	used       []bool
+	unexpected []CommandCall
+}
+
+// NewFakeCommander returns a FakeCommander scripted with the specified calls.
+func NewFakeCommander(calls ...CommandCall) *FakeCommander {
+	return &FakeCommander{calls: calls, used: make([]bool, len(calls))}
+}
+
+// Command returns a fake command described by the specified spec.
+func (f *FakeCommander) Command(spec CommandSpec) Command {
+	return &fakeCommand{commander: f, spec: spec}
+}
+
+// Remaining returns the scripted calls that have not been executed.
+func (f *FakeCommander) Remaining() []CommandCall {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var remaining []CommandCall
+
+	for i, c := range f.calls {
+		if !f.used[i] {
+			remaining = append(remaining, c)
+		}
+	}
+
+	return remaining
+}
+
+// Unexpected returns the executed calls that matched none of the scripted ones.
+func (f *FakeCommander) Unexpected() []CommandCall {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]CommandCall{}, f.unexpected...)
+}
+
+func (f *FakeCommander) execute(call CommandCall) (CommandCall, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i, c := range f.calls {
+		if !f.used[i] && c.equals(call) {
+			f.used[i] = true
+			return c, true
+		}
+	}
+
+	f.unexpected = append(f.unexpected, call)
+
+	return call, false
+}
+
+type fakeCommand struct {
+	commander *FakeCommander
+	spec      CommandSpec
+}
+
+func (c *fakeCommand) Execute(args ...string) (string, error) {
+	ctx := c.spec.Context
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return c.ExecuteContext(ctx, ExecOptions{}, args...)
+}
+
+func (c *fakeCommand) ExecuteContext(ctx context.Context, opts ExecOptions, args ...string) (string, error) {
+	call := CommandCall{
+		Name: filepath.Base(c.spec.Name),
+		Args: replaceAll(args, c.spec.WorkDir, WorkDirPlaceholder),
+		Env:  commandEnv(c.spec, opts),
+	}
+
+	if ctx.Err() != nil {
+		return "", &ExecError{CommandLine: call.String(), ExitCode: -1, Err: ctx.Err()}
+	}
+
+	scripted, found := c.commander.execute(call)
+
+	if !found {
+		return "", &ExecError{CommandLine: call.String(), ExitCode: -1, Err: errors.New("unexpected command")}
+	}
+
+	out := replace(scripted.Output, WorkDirPlaceholder, c.spec.WorkDir)
+	scripted.Stderr = replace(scripted.Stderr, WorkDirPlaceholder, c.spec.WorkDir)
+
+	for _, stream := range []io.Writer{c.spec.Stream, opts.Stream} {
+		if stream != nil && len(out) > 0 {
+			fmt.Fprintln(stream, out)
+		}
+	}
+
+	return out, scripted.err()
+}
+
+// RecordingCommander is a Commander capturing the calls of its commands along with their results,
+// e.g. to write them into a golden file replayed later by a FakeCommander, the golden files in 'testdata'
+// are recorded from the real git and go commands by running the tests with the '-update' flag.
+type RecordingCommander struct {
+	// Commander creates the recorded commands, the real ones are executed if not set.
+	Commander Commander
+
+	mutex sync.Mutex
+	calls []CommandCall
+}
+
+// Command returns the command described by the specified spec, recording its calls.
+func (r *RecordingCommander) Command(spec CommandSpec) Command {
+	return &recordingCommand{recorder: r, spec: spec, command: newCommand(r.Commander, spec)}
+}
+
+// Calls returns the recorded calls in order.
+func (r *RecordingCommander) Calls() []CommandCall {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]CommandCall{}, r.calls...)
+}
+
+// Write writes the recorded calls into the specified golden file.
+func (r *RecordingCommander) Write(path string) error {
+	return WriteCommandCalls(path, r.Calls())
+}
+
+func (r *RecordingCommander) record(spec CommandSpec, opts ExecOptions, args []string, out string, err error) {
+	call := CommandCall{
+		Name:   filepath.Base(spec.Name),
+		Args:   replaceAll(args, spec.WorkDir, WorkDirPlaceholder),
+		Env:    commandEnv(spec, opts),
+		Output: replace(out, spec.WorkDir, WorkDirPlaceholder),
+	}
+
+	if e, ok := err.(*ExecError); ok {
+		call.ExitCode, call.Stderr = e.ExitCode, replace(e.Stderr, spec.WorkDir, WorkDirPlaceholder)
+
+		if e.ExitCode < 0 {
+			call.ExitCode, call.Error = 0, e.Err.Error()
+		}
+	} else if err != nil {
+		call.Error = err.Error()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.calls = append(r.calls, call)
+}
+
+type recordingCommand struct {
+	recorder *RecordingCommander
+	spec     CommandSpec
+	command  Command
+}
+
+func (c *recordingCommand) Execute(args ...string) (string, error) {
+	out, err := c.command.Execute(args...)
+	c.recorder.record(c.spec, ExecOptions{}, args, out, err)
+	return out, err
+}
+
+func (c *recordingCommand) ExecuteContext(ctx context.Context, opts ExecOptions, args ...string) (string, error) {
+	out, err := c.command.ExecuteContext(ctx, opts, args...)
+	c.recorder.record(c.spec, opts, args, out, err)
+	return out, err
+}
+
+// commandEnv returns the environment pairs of a command execution, the ones of its spec followed by the ones of its
+// options, with the working directory replaced by its placeholder, or nil if there is none.
+func commandEnv(spec CommandSpec, opts ExecOptions) []string {
+	if len(spec.Env)+len(opts.Env) == 0 {
+		return nil
+	}
+
+	return replaceAll(append(append([]string{}, spec.Env...), opts.Env...), spec.WorkDir, WorkDirPlaceholder)
+}
+
+// replaceAll returns a copy of the specified values with every occurrence of old replaced by new.
+func replaceAll(values []string, old, new string) []string {
+	replaced := make([]string, len(values))
+
+	for i, v := range values {
+		replaced[i] = replace(v, old, new)
+	}
+
+	return replaced
+}
+
+// replace returns the specified value with every occurrence of old replaced by new, an empty old replaces nothing.
+func replace(value, old, new string) string {
+	if len(old) == 0 {
+		return value
+	}
+	return strings.Replace(value, old, new, -1)
+}
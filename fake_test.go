@@ -0,0 +1,220 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeCommander_Execute(t *testing.T) {
+	fake := NewFakeCommander(
+		CommandCall{Name: "git", Args: []string{"rev-parse", "--show-toplevel"}, Output: WorkDirPlaceholder},
+		CommandCall{Name: "go", Args: []string{"build", WorkDirPlaceholder + "/cmd"}, ExitCode: 2, Stderr: "boom"},
+		CommandCall{Name: "gofmt", Args: []string{"-l"}, Error: "exec: \"gofmt\": executable file not found in $PATH"},
+	)
+
+	out, err := fake.Command(CommandSpec{Name: "git", WorkDir: "/work"}).Execute("rev-parse", "--show-toplevel")
+	assert.Nil(t, err)
+	assert.Equal(t, "/work", out)
+
+	_, err = fake.Command(CommandSpec{Name: "/usr/local/go/bin/go", WorkDir: "/work"}).Execute("build", "/work/cmd")
+	assert.EqualError(t, err, "exit status 2: boom")
+	assert.Equal(t, 2, ExitCode(err))
+
+	_, err = fake.Command(CommandSpec{Name: "gofmt", WorkDir: "/work"}).Execute("-l")
+	assert.EqualError(t, err, "exec: \"gofmt\": executable file not found in $PATH: ")
+	assert.Equal(t, -1, ExitCode(err))
+
+	assert.Empty(t, fake.Remaining())
+	assert.Empty(t, fake.Unexpected())
+}
+
+func TestFakeCommander_Order(t *testing.T) {
+	fake := NewFakeCommander(
+		CommandCall{Name: "git", Args: []string{"rev-parse", "HEAD"}, Output: "first"},
+		CommandCall{Name: "git", Args: []string{"status"}},
+		CommandCall{Name: "git", Args: []string{"rev-parse", "HEAD"}, Output: "second"},
+	)
+
+	git := fake.Command(CommandSpec{Name: "git"})
+
+	out, _ := git.Execute("rev-parse", "HEAD")
+	assert.Equal(t, "first", out)
+
+	out, _ = git.Execute("rev-parse", "HEAD")
+	assert.Equal(t, "second", out)
+
+	assert.Equal(t, []CommandCall{{Name: "git", Args: []string{"status"}}}, fake.Remaining())
+}
+
+func TestFakeCommander_Unexpected(t *testing.T) {
+	fake := NewFakeCommander()
+
+	_, err := fake.Command(CommandSpec{Name: "git"}).Execute("status")
+
+	assert.EqualError(t, err, "unexpected command: ")
+	assert.Equal(t, []CommandCall{{Name: "git", Args: []string{"status"}}}, fake.Unexpected())
+}
+
+func TestFakeCommander_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fake := NewFakeCommander(CommandCall{Name: "git", Args: []string{"fetch"}})
+
+	_, err := fake.Command(CommandSpec{Name: "git", Context: ctx}).Execute("fetch")
+
+	assert.EqualError(t, err, "context canceled: ")
+	assert.Len(t, fake.Remaining(), 1)
+}
+
+func TestFakeCommander_Stream(t *testing.T) {
+	var stream bytes.Buffer
+
+	fake := NewFakeCommander(CommandCall{Name: "go", Args: []string{"test"}, Output: "ok"})
+
+	_, err := fake.Command(CommandSpec{Name: "go", Stream: &stream}).Execute("test")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "ok"+NewLine(), stream.String())
+}
+
+func TestFakeCommander_Env(t *testing.T) {
+	fake := NewFakeCommander(CommandCall{Name: "go", Args: []string{"env", "GOOS"}, Env: []string{"GOOS=plan9", "GOFLAGS=-v"}, Output: "plan9"})
+
+	_, err := fake.Command(CommandSpec{Name: "go", Env: []string{"GOOS=plan9"}}).Execute("env", "GOOS")
+	assert.Equal(t, -1, ExitCode(err))
+
+	out, err := fake.Command(CommandSpec{Name: "go", Env: []string{"GOOS=plan9"}}).ExecuteContext(context.Background(),
+		ExecOptions{Env: []string{"GOFLAGS=-v"}}, "env", "GOOS")
+	assert.Nil(t, err)
+	assert.Equal(t, "plan9", out)
+
+	assert.Empty(t, fake.Remaining())
+	assert.Equal(t, []CommandCall{{Name: "go", Args: []string{"env", "GOOS"}, Env: []string{"GOOS=plan9"}}}, fake.Unexpected())
+}
+
+func TestRecordingCommander(t *testing.T) {
+	var dir, other string
+	var err error
+
+	if dir, err = ioutil.TempDir("", "test_rego_recording_"); err != nil {
+		assert.Fail(t, err.Error())
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		assert.Fail(t, err.Error())
+		return
+	}
+
+	recorder := &RecordingCommander{}
+	sh := recorder.Command(CommandSpec{Name: "sh", WorkDir: dir})
+
+	out, err := sh.Execute("-c", "pwd")
+	assert.Nil(t, err)
+	assert.Equal(t, dir, out)
+
+	out, err = recorder.Command(CommandSpec{Name: "sh", WorkDir: dir, Env: []string{"REGO_DIR=" + dir}}).
+		ExecuteContext(context.Background(), ExecOptions{Env: []string{"REGO_NAME=rego"}}, "-c", "echo $REGO_NAME")
+	assert.Nil(t, err)
+	assert.Equal(t, "rego", out)
+
+	_, err = sh.Execute("-c", "echo failed in $(pwd) >&2; exit 3")
+	assert.EqualError(t, err, "exit status 3: failed in "+dir)
+
+	_, err = recorder.Command(CommandSpec{Name: "rego-missing-command", WorkDir: dir}).Execute()
+	assert.NotNil(t, err)
+
+	calls := recorder.Calls()
+
+	assert.Len(t, calls, 4)
+	assert.Equal(t, CommandCall{Name: "sh", Args: []string{"-c", "pwd"}, Output: WorkDirPlaceholder}, calls[0])
+	assert.Equal(t, CommandCall{
+		Name:   "sh",
+		Args:   []string{"-c", "echo $REGO_NAME"},
+		Env:    []string{"REGO_DIR=" + WorkDirPlaceholder, "REGO_NAME=rego"},
+		Output: "rego",
+	}, calls[1])
+	assert.Equal(t, CommandCall{
+		Name:     "sh",
+		Args:     []string{"-c", "echo failed in $(pwd) >&2; exit 3"},
+		ExitCode: 3,
+		Stderr:   "failed in " + WorkDirPlaceholder,
+	}, calls[2])
+	assert.Equal(t, 0, calls[3].ExitCode)
+	assert.NotEmpty(t, calls[3].Error)
+
+	golden := filepath.Join(dir, "calls.json")
+
+	if err = recorder.Write(golden); err != nil {
+		assert.Fail(t, err.Error())
+		return
+	}
+
+	if calls, err = ReadCommandCalls(golden); err != nil {
+		assert.Fail(t, err.Error())
+		return
+	}
+
+	other = filepath.Join(dir, "other")
+	fake := NewFakeCommander(calls...)
+	sh = fake.Command(CommandSpec{Name: "sh", WorkDir: other})
+
+	out, err = sh.Execute("-c", "pwd")
+	assert.Nil(t, err)
+	assert.Equal(t, other, out)
+
+	out, err = fake.Command(CommandSpec{Name: "sh", WorkDir: other, Env: []string{"REGO_DIR=" + other}}).
+		ExecuteContext(context.Background(), ExecOptions{Env: []string{"REGO_NAME=rego"}}, "-c", "echo $REGO_NAME")
+	assert.Nil(t, err)
+	assert.Equal(t, "rego", out)
+
+	_, err = sh.Execute("-c", "echo failed in $(pwd) >&2; exit 3")
+	assert.EqualError(t, err, "exit status 3: failed in "+other)
+	assert.Equal(t, 3, ExitCode(err))
+
+	_, err = fake.Command(CommandSpec{Name: "rego-missing-command", WorkDir: other}).Execute()
+	assert.Equal(t, -1, ExitCode(err))
+	assert.Empty(t, fake.Remaining())
+}
+
+func TestReadCommandCalls_Invalid(t *testing.T) {
+	file, err := ioutil.TempFile("", "test_rego_calls_")
+
+	if err != nil {
+		assert.Fail(t, err.Error())
+		return
+	}
+
+	defer os.Remove(file.Name())
+
+	file.WriteString("{")
+	file.Close()
+
+	_, err = ReadCommandCalls(file.Name())
+	assert.Contains(t, err.Error(), "invalid command calls file")
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The kinds of the quality gates.
+const (
+	VetGate      = "vet"
+	TestGate     = "test"
+	TidyGate     = "tidy"
+	VerifyGate   = "verify"
+	GofmtGate    = "gofmt"
+	GenerateGate = "generate"
+)
+
+var gateKinds = map[string]bool{
+	VetGate: true, TestGate: true, TidyGate: true, VerifyGate: true, GofmtGate: true, GenerateGate: true,
+}
+
+// Gate is a quality check run in the build working tree before compiling a release.
+type Gate struct {
+	// Name is the kind of the gate, one of: 'vet', 'test', 'tidy' (a 'go mod tidy' without changes), 'verify'
+	// ('go mod verify'), 'gofmt' (no unformatted files) or 'generate' (a 'go generate' without changes).
+	Name string `json:"name"`
+	// Packages is the list of the package patterns checked by 'vet', 'test', 'gofmt' and 'generate', defaults to './...'.
+	Packages []string `json:"packages"`
+	// Flags is the list of the extra flags passed to 'go vet' or 'go test'.
+	Flags []string `json:"flags"`
+	// Advisory reports the failure of this gate without failing the release.
+	Advisory bool `json:"advisory"`
+}
+
+// GateResult is the outcome of running a gate.
+type GateResult struct {
+	// Name is the kind of the gate.
+	Name string `json:"name"`
+	// Passed is true if the gate passed.
+	Passed bool `json:"passed"`
+	// Advisory is true if the gate is advisory.
+	Advisory bool `json:"advisory,omitempty"`
+	// Duration is the time the gate took to run.
+	Duration time.Duration `json:"-"`
+	// Output is the captured output of the gate, describing the failure if failed.
+	Output string `json:"output,omitempty"`
+}
+
+// Status returns either 'passed', 'failed' or 'failed (advisory)'.
+func (r GateResult) Status() string {
+	if r.Passed {
+		return "passed"
+	} else if r.Advisory {
+		return "failed (advisory)"
+	}
+	return "failed"
+}
+
+// ValidateGates returns an error if any of the specified gates is of an unknown kind.
+func ValidateGates(gates []Gate) error {
+	for _, gate := range gates {
+		if !gateKinds[gate.Name] {
+			return fmt.Errorf("unknown gate '%v', the known gates are: vet, test, tidy, verify, gofmt and generate", gate.Name)
+		}
+	}
+	return nil
+}
+
+func (gate Gate) packages() []string {
+	if len(gate.Packages) > 0 {
+		return gate.Packages
+	}
+	return []string{"./..."}
+}
+
+// Run runs this gate using the specified Go tools, the changes are checked under the specified paths of the
+// working tree of the specified git repository, and reverted if any.
+func (gate Gate) Run(gt *GoTools, g *Git, paths []string) GateResult {
+	var out string
+	var err error
+
+	start := time.Now()
+
+	switch gate.Name {
+	case VetGate:
+		out, err = gt.Vet(gate.packages(), gate.Flags)
+	case TestGate:
+		out, err = gt.Test(gate.packages(), gate.Flags)
+	case TidyGate:
+		out, err = noDiff(g, paths, gt.ModTidy)
+	case VerifyGate:
+		out, err = gt.ModVerify()
+	case GofmtGate:
+		var files []string
+
+		if files, err = gt.UnformattedFiles(gate.packages()); err == nil && len(files) > 0 {
+			err = fmt.Errorf("unformatted files:%v %v", NewLine(), strings.Join(files, NewLine()+" "))
+		}
+	case GenerateGate:
+		// the go command may update the 'go.mod' and the 'go.sum' files by itself (e.g. adding a missing 'go'
+		// directive) while loading the packages to generate, that is not the generators' doing.
+		out, err = noDiff(g, paths, func() (string, error) { return gt.Generate(gate.packages()) }, "go.mod", "go.sum")
+	default:
+		err = fmt.Errorf("unknown gate '%v'", gate.Name)
+	}
+
+	result := GateResult{Name: gate.Name, Passed: err == nil, Advisory: gate.Advisory, Duration: time.Since(start), Output: out}
+
+	if err != nil {
+		result.Output = err.Error()
+	}
+
+	return result
+}
+
+// noDiff runs the specified step and returns an error listing the files it changed, after reverting them, the changed
+// files named as any of the specified ignored names are reverted without failing. The files that were already
+// uncommitted are never reverted, the step fails if it modified any of them.
+func noDiff(g *Git, paths []string, step func() (string, error), ignored ...string) (string, error) {
+	var before, after TreeState
+	var hashes map[string]string
+	var out string
+	var err error
+
+	if before, err = g.TreeState(paths...); err != nil {
+		return "", err
+	} else if hashes, err = fileHashes(g, before.Files); err != nil {
+		return "", err
+	} else if out, err = step(); err != nil {
+		return out, err
+	} else if after, err = g.TreeState(paths...); err != nil {
+		return out, err
+	}
+
+	var current map[string]string
+	var changed, reverted, modified []string
+
+	if current, err = fileHashes(g, before.Files); err != nil {
+		return out, err
+	}
+
+	for _, file := range before.Files {
+		if current[file] != hashes[file] {
+			modified = append(modified, file)
+		}
+	}
+
+	for _, file := range after.Files {
+		if _, dirty := hashes[file]; dirty {
+			continue
+		} else if containsString(ignored, path.Base(file)) {
+			reverted = append(reverted, file)
+		} else {
+			changed = append(changed, file)
+		}
+	}
+
+	if len(reverted) > 0 || len(changed) > 0 {
+		if err = g.Discard(append(reverted, changed...)...); err != nil {
+			return out, err
+		}
+	}
+
+	var problems []string
+
+	if len(changed) > 0 {
+		problems = append(problems, fmt.Sprintf("changed files:%v %v", NewLine(), strings.Join(changed, NewLine()+" ")))
+	}
+
+	if len(modified) > 0 {
+		problems = append(problems, fmt.Sprintf("modified uncommitted files, left as modified:%v %v", NewLine(), strings.Join(modified, NewLine()+" ")))
+	}
+
+	if len(problems) > 0 {
+		return out, errors.New(strings.Join(problems, NewLine()))
+	}
+
+	return out, nil
+}
+
+// fileHashes returns the SHA-256 hex digests of the contents of the specified files relative to the repository root,
+// mapped by their paths, a deleted file is mapped to an empty string.
+func fileHashes(g *Git, files []string) (map[string]string, error) {
+	hashes := make(map[string]string)
+
+	if len(files) == 0 {
+		return hashes, nil
+	}
+
+	top, err := g.TopLevel()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		content, e := ioutil.ReadFile(filepath.Join(top, file))
+
+		if os.IsNotExist(e) {
+			hashes[file] = ""
+			continue
+		} else if e != nil {
+			return nil, e
+		}
+
+		sum := sha256.Sum256(content)
+		hashes[file] = hex.EncodeToString(sum[:])
+	}
+
+	return hashes, nil
+}
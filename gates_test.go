@@ -0,0 +1,161 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateGates(t *testing.T) {
+	assert.Nil(t, ValidateGates([]Gate{{Name: "vet"}, {Name: "test"}, {Name: "tidy"}, {Name: "verify"}, {Name: "gofmt"}, {Name: "generate"}}))
+	assert.EqualError(t, ValidateGates([]Gate{{Name: "vet"}, {Name: "lint"}}),
+		"unknown gate 'lint', the known gates are: vet, test, tidy, verify, gofmt and generate")
+}
+
+func TestGateResult_Status(t *testing.T) {
+	assert.Equal(t, "passed", GateResult{Passed: true, Advisory: true}.Status())
+	assert.Equal(t, "failed", GateResult{}.Status())
+	assert.Equal(t, "failed (advisory)", GateResult{Advisory: true}.Status())
+}
+
+func (suite *GoToolsTestSuite) runGate(gate Gate) GateResult {
+	return gate.Run(suite.goTools, &Git{WorkDir: suite.goTools.WorkDir}, nil)
+}
+
+func (suite *GoToolsTestSuite) write(name, content string) {
+	if err := ioutil.WriteFile(filepath.Join(suite.goTools.WorkDir, name), []byte(content), 0600); err != nil {
+		suite.Fail("failed to write '"+name+"'", err.Error())
+	}
+}
+
+func (suite *GoToolsTestSuite) TestGate_Vet() {
+	r := suite.runGate(Gate{Name: VetGate})
+	assert.True(suite.T(), r.Passed, r.Output)
+	assert.True(suite.T(), r.Duration > 0)
+
+	suite.write("vet.go", "package main\n\nimport \"fmt\"\n\nfunc vet() {\n\tfmt.Printf(\"%d\", \"string\")\n}\n")
+
+	r = suite.runGate(Gate{Name: VetGate, Advisory: true})
+	assert.False(suite.T(), r.Passed)
+	assert.True(suite.T(), r.Advisory)
+	assert.Contains(suite.T(), r.Output, "vet.go")
+}
+
+func (suite *GoToolsTestSuite) TestGate_Test() {
+	suite.write("main_test.go", "package main\n\nimport \"testing\"\n\nfunc TestFail(t *testing.T) {\n\tif testing.Short() {\n\t\tt.Fatal(\"short\")\n\t}\n}\n")
+
+	r := suite.runGate(Gate{Name: TestGate})
+	assert.True(suite.T(), r.Passed, r.Output)
+
+	r = suite.runGate(Gate{Name: TestGate, Flags: []string{"-short"}, Packages: []string{"."}})
+	assert.False(suite.T(), r.Passed)
+	assert.Contains(suite.T(), r.Output, "TestFail")
+}
+
+func (suite *GoToolsTestSuite) TestGate_Gofmt() {
+	suite.write("unformatted.go", "package main\nfunc  unformatted( ) {}\n")
+
+	// the leading empty line of 'main.go' is not formatted either.
+	r := suite.runGate(Gate{Name: GofmtGate})
+	assert.False(suite.T(), r.Passed)
+	assert.Equal(suite.T(), "unformatted files:"+NewLine()+" main.go"+NewLine()+" unformatted.go", r.Output)
+
+	suite.write("main.go", strings.TrimLeft(content, "\n"))
+	suite.write("unformatted.go", "package main\n\nfunc unformatted() {}\n")
+
+	r = suite.runGate(Gate{Name: GofmtGate})
+	assert.True(suite.T(), r.Passed, r.Output)
+}
+
+func (suite *GoToolsTestSuite) TestGate_Tidy() {
+	goMod := filepath.Join(suite.goTools.WorkDir, "go.mod")
+	git := NewNamedCommand("git", suite.goTools.WorkDir)
+
+	suite.write("go.mod", "module project\n")
+	git.Execute("commit", "-n", "-a", "-m", "'Drop the go directive'")
+
+	// the missing 'go' directive is added by 'go mod tidy'.
+	r := suite.runGate(Gate{Name: TidyGate})
+	assert.False(suite.T(), r.Passed)
+	assert.Equal(suite.T(), "changed files:"+NewLine()+" go.mod", r.Output)
+
+	content, _ := ioutil.ReadFile(goMod)
+	assert.Equal(suite.T(), "module project\n", string(content))
+
+	suite.goTools.ModTidy()
+	git.Execute("commit", "-n", "-a", "-m", "'Tidy'")
+
+	r = suite.runGate(Gate{Name: TidyGate})
+	assert.True(suite.T(), r.Passed, r.Output)
+}
+
+func (suite *GoToolsTestSuite) TestGate_Verify() {
+	r := suite.runGate(Gate{Name: VerifyGate})
+	assert.True(suite.T(), r.Passed, r.Output)
+}
+
+func (suite *GoToolsTestSuite) TestGate_Generate() {
+	suite.write("generate.go", "package main\n\n//go:generate touch generated.txt\n")
+
+	r := suite.runGate(Gate{Name: GenerateGate})
+	assert.False(suite.T(), r.Passed)
+	assert.Equal(suite.T(), "changed files:"+NewLine()+" generated.txt", r.Output)
+
+	_, err := os.Stat(filepath.Join(suite.goTools.WorkDir, "generated.txt"))
+	assert.True(suite.T(), os.IsNotExist(err))
+
+	suite.write("generate.go", "package main\n\n//go:generate echo generated\n")
+
+	r = suite.runGate(Gate{Name: GenerateGate})
+	assert.True(suite.T(), r.Passed, r.Output)
+}
+
+func (suite *GoToolsTestSuite) TestGate_Generate_ModuleFiles() {
+	goMod := filepath.Join(suite.goTools.WorkDir, "go.mod")
+	before, _ := ioutil.ReadFile(goMod)
+
+	suite.write("generate.go", "package main\n\n//go:generate sh -c \"echo '// updated' >> go.mod\"\n")
+
+	r := suite.runGate(Gate{Name: GenerateGate})
+	assert.True(suite.T(), r.Passed, r.Output)
+
+	after, _ := ioutil.ReadFile(goMod)
+	assert.Equal(suite.T(), string(before), string(after))
+}
+
+func (suite *GoToolsTestSuite) TestGate_Generate_DirtyFiles() {
+	suite.write("notes.txt", "notes\n")
+	suite.write("go.mod", "module project\n\ngo 1.16\n\n// edited\n")
+	suite.write("generate.go", "package main\n\n//go:generate sh -c \"echo generated >> notes.txt && echo '// updated' >> go.mod\"\n")
+
+	r := suite.runGate(Gate{Name: GenerateGate})
+	assert.False(suite.T(), r.Passed)
+	assert.Equal(suite.T(), "modified uncommitted files, left as modified:"+NewLine()+" go.mod"+NewLine()+" notes.txt", r.Output)
+
+	// the files uncommitted before running the gate are not reverted.
+	notes, _ := ioutil.ReadFile(filepath.Join(suite.goTools.WorkDir, "notes.txt"))
+	assert.Equal(suite.T(), "notes\ngenerated\n", string(notes))
+
+	goMod, _ := ioutil.ReadFile(filepath.Join(suite.goTools.WorkDir, "go.mod"))
+	assert.Equal(suite.T(), "module project\n\ngo 1.16\n\n// edited\n// updated\n", string(goMod))
+}
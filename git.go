@@ -17,20 +17,148 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
+const (
+	cleanTreeState   = "clean"
+	dirtyTreeState   = "dirty"
+	ignoredTreeState = "ignored"
+)
+
+// TreeState describes the uncommitted changes of a git working tree.
+type TreeState struct {
+	// Files is the sorted list of the modified, deleted and untracked files paths relative to the repository root.
+	Files []string
+	// DiffHash is the SHA-256 hex digest of the uncommitted changes, both the diff against 'HEAD' and the untracked files.
+	DiffHash string
+	// Ignored is the list of the uncommitted files matching the ignore rules the release is built with.
+	Ignored []string
+}
+
+// Dirty returns true if there are uncommitted changes.
+func (t TreeState) Dirty() bool {
+	return len(t.Files) > 0
+}
+
+// String returns either 'clean', 'ignored' if the only uncommitted files match the ignore rules, or 'dirty'.
+func (t TreeState) String() string {
+	if t.Dirty() {
+		return dirtyTreeState
+	} else if len(t.Ignored) > 0 {
+		return ignoredTreeState
+	}
+	return cleanTreeState
+}
+
+// The kinds of the git references a revision expression may resolve from.
+const (
+	BranchReference       = "branch"
+	RemoteBranchReference = "remote-branch"
+	TagReference          = "tag"
+	CommitReference       = "commit"
+	ExpressionReference   = "expression"
+)
+
+// Reference is a git revision expression resolved to a commit.
+type Reference struct {
+	// Name is the short name of the branch or the tag the expression refers to, otherwise the expression itself.
+	Name string `json:"name"`
+	// Kind is one of: 'branch', 'remote-branch', 'tag', 'commit' or 'expression'.
+	Kind string `json:"kind"`
+	// Commit is the full commit hash the expression resolves to.
+	Commit string `json:"-"`
+}
+
 // Git is a context structure for a git command.
 type Git struct {
 	// WorkDir is the working directory where the command is being executed.
 	WorkDir string
-	// Verbose shows more verbose output while execution.
-	Verbose bool
+	// Logger receives the output of the long-running git commands at the debug level and every git command run at the
+	// trace level, nothing is logged if not set.
+	Logger *slog.Logger
+	// Context cancels the running git commands once it is done, no command is cancelled if not set.
+	Context context.Context
+	// Commander creates the git commands, the real ones are executed if not set.
+	Commander Commander
+}
+
+// command returns the described command, its failures are git errors and it is retried while the index is locked.
+func (g *Git) command(spec CommandSpec) Command {
+	spec.Context, spec.Logger = g.Context, g.Logger
+	c := &indexLockRetry{Command: newCommand(g.Commander, spec), ctx: g.Context, logger: g.Logger}
+	return &categorizedCommand{Command: c, category: GitError}
+}
+
+// indexLockBackoff is the list of the delays between the retries of a git command failing because the index is
+// locked, e.g. by an IDE refreshing the status of the repository at the same time.
+var indexLockBackoff = []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, 1600 * time.Millisecond}
+
+// indexLockRetry retries a git command failing because the 'index.lock' file exists, with an increasing delay.
+type indexLockRetry struct {
+	Command
+	ctx    context.Context
+	logger *slog.Logger
+}
+
+func (c *indexLockRetry) Execute(args ...string) (string, error) {
+	ctx := c.ctx
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return c.retry(ctx, args, func() (string, error) { return c.Command.Execute(args...) })
+}
+
+func (c *indexLockRetry) ExecuteContext(ctx context.Context, opts ExecOptions, args ...string) (string, error) {
+	if opts.Stdin != nil {
+		// the standard input can not be read again.
+		return c.Command.ExecuteContext(ctx, opts, args...)
+	}
+
+	return c.retry(ctx, args, func() (string, error) { return c.Command.ExecuteContext(ctx, opts, args...) })
+}
+
+func (c *indexLockRetry) retry(ctx context.Context, args []string, execute func() (string, error)) (string, error) {
+	out, err := execute()
+
+	for _, delay := range indexLockBackoff {
+		if e := execErrorOf(err); e == nil || !strings.Contains(e.Stderr, "index.lock") {
+			break
+		}
+
+		loggerOrDiscard(c.logger).Debug("retrying while the index is locked", "command", "git "+strings.Join(args, " "), "delay", delay)
+
+		select {
+		case <-ctx.Done():
+			return out, err
+		case <-time.After(delay):
+		}
+
+		out, err = execute()
+	}
+
+	return out, err
 }
 
 func (g *Git) withGit() Command {
-	return NewNamedCommand("git", g.WorkDir)
+	return g.command(CommandSpec{Name: "git", WorkDir: g.WorkDir})
+}
+
+// withStreamingGit returns a git command whose output is logged at the debug level, for the long-running commands.
+func (g *Git) withStreamingGit() Command {
+	return g.command(CommandSpec{Name: "git", WorkDir: g.WorkDir, Stream: commandStream(g.Logger, "git")})
 }
 
 // IsCommitExists takes a git commit hash and returns true if it's found, false otherwise.
@@ -40,7 +168,7 @@ func (g *Git) IsCommitExists(hash string) (bool, error) {
 	var err error
 
 	if out, err = g.withGit().Execute("show", "-s", "--format=%H", hash); err != nil {
-		if !strings.HasPrefix(err.Error(), "exit status 128: fatal: bad object") {
+		if ExitCode(err) != 128 || !strings.HasPrefix(execErrorOf(err).Stderr, "fatal: bad object") {
 			return false, err
 		}
 	}
@@ -54,15 +182,16 @@ func (g *Git) Checkout(hash string) error {
 	return err
 }
 
-// Status returns the status of the current git repository targeted by this Git object, the status returned
-// is in string format, the string is empty if all is committed, it returns error on failure.
-func (g *Git) Status() (string, error) {
+// Status returns the status of the current git repository targeted by this Git object, parsed from
+// 'git status --porcelain=v2 -z', the status is empty if all is committed, it returns error on failure,
+// if paths are specified only the changes under them are considered.
+func (g *Git) Status(paths ...string) (Status, error) {
 	var out string
 	var err error
-	if out, err = g.withGit().Execute("status", "-s", "-uall"); err != nil {
-		return "", err
+	if out, err = g.withGit().Execute(append([]string{"status", "--porcelain=v2", "-z", "-uall", "--"}, paths...)...); err != nil {
+		return nil, err
 	}
-	return out, nil
+	return ParseStatus(out)
 }
 
 // GetTagCommit returns the git commit hash of the specified git tag, it returns an error on failure.
@@ -73,7 +202,7 @@ func (g *Git) GetTagCommit(tag string) (string, error) {
 		fmt.Sprintf("refs/tags/%v", tag), "--format='%(objectname)'"); err != nil {
 		return "", err
 	} else if len(strings.Trim(out, "'")) == 0 {
-		return "", fmt.Errorf("tag '%v' is not found", tag)
+		return "", newError(ReferenceError, "tag '%v' is not found", tag)
 	}
 	return strings.Trim(out, "'"), nil
 }
@@ -86,7 +215,519 @@ func (g *Git) GetBranchCommit(branch string) (string, error) {
 		fmt.Sprintf("refs/heads/%v", branch), "--format='%(objectname)'"); err != nil {
 		return "", err
 	} else if len(strings.Trim(out, "'")) == 0 {
-		return "", fmt.Errorf("branch '%v' is not found", branch)
+		return "", newError(ReferenceError, "branch '%v' is not found", branch)
 	}
 	return strings.Trim(out, "'"), nil
 }
+
+// HeadCommit returns the git commit hash currently checked out, it returns an error on failure.
+func (g *Git) HeadCommit() (string, error) {
+	return g.withGit().Execute("rev-parse", "--verify", "HEAD^{commit}")
+}
+
+func lines(out string) []string {
+	var result []string
+
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); len(line) > 0 {
+			result = append(result, line)
+		}
+	}
+
+	return result
+}
+
+// TreeState returns the uncommitted changes of the working tree, it returns an error on failure,
+// if paths are specified only the changes under them are considered.
+func (g *Git) TreeState(paths ...string) (TreeState, error) {
+	var state TreeState
+	var diff, modified, untracked string
+	var err error
+
+	if diff, err = g.withGit().Execute(append([]string{"diff", "HEAD", "--binary", "--"}, paths...)...); err != nil {
+		return state, err
+	} else if modified, err = g.withGit().Execute(append([]string{"diff", "HEAD", "--name-only", "--"}, paths...)...); err != nil {
+		return state, err
+	} else if untracked, err = g.withGit().Execute(append([]string{"ls-files", "--others", "--exclude-standard", "--full-name", "--"}, paths...)...); err != nil {
+		return state, err
+	}
+
+	state.Files = append(lines(modified), lines(untracked)...)
+
+	if !state.Dirty() {
+		return state, nil
+	}
+
+	sort.Strings(state.Files)
+
+	var top string
+
+	if top, err = g.TopLevel(); err != nil {
+		return state, err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(diff))
+
+	for _, file := range lines(untracked) {
+		var content []byte
+
+		if content, err = ioutil.ReadFile(filepath.Join(top, file)); err != nil {
+			return state, err
+		}
+
+		hash.Write([]byte(file))
+		hash.Write(content)
+	}
+
+	state.DiffHash = hex.EncodeToString(hash.Sum(nil))
+
+	return state, nil
+}
+
+// TopLevel returns the absolute path of the repository root directory, it returns an error on failure.
+func (g *Git) TopLevel() (string, error) {
+	return g.withGit().Execute("rev-parse", "--show-toplevel")
+}
+
+// Version returns the version of git (e.g. 'git version 2.39.5'), it returns an error on failure.
+func (g *Git) Version() (string, error) {
+	return g.withGit().Execute("--version")
+}
+
+// IsShallow returns true if the repository is a shallow clone missing a part of the history, it returns an error on
+// failure.
+func (g *Git) IsShallow() (bool, error) {
+	out, err := g.withGit().Execute("rev-parse", "--is-shallow-repository")
+	return out == "true", err
+}
+
+// LastCommit returns the hash of the most recent commit reachable from the specified commit that touches any of the
+// specified paths, or the commit itself if no paths are specified, it returns an error on failure.
+func (g *Git) LastCommit(commit string, paths ...string) (string, error) {
+	out, err := g.withGit().Execute(append([]string{"log", "-1", "--format=%H", commit, "--"}, paths...)...)
+
+	if err != nil {
+		return "", err
+	} else if len(out) == 0 {
+		return "", newError(ReferenceError, "no commits reachable from '%v' touch '%v'", commit, strings.Join(paths, ", "))
+	}
+
+	return out, nil
+}
+
+// Submodules returns the submodules of the working tree recursively along with their states,
+// it returns an error on failure.
+func (g *Git) Submodules() ([]Submodule, error) {
+	out, err := g.withGit().Execute("submodule", "status", "--recursive")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseSubmoduleStatus(out)
+}
+
+// UpdateSubmodules initializes the submodules of the working tree recursively and checks out their recorded commits,
+// it returns an error on failure.
+func (g *Git) UpdateSubmodules() error {
+	_, err := g.withStreamingGit().Execute("submodule", "update", "--init", "--recursive")
+	return err
+}
+
+// ResolveReference resolves any revision expression understood by 'git rev-parse' (e.g. a short hash, 'HEAD~3',
+// 'origin/main' or 'v1.2^{}') to a full commit hash along with the kind and the name of the reference it is based on,
+// it returns an error if the expression does not resolve to a commit or on failure.
+func (g *Git) ResolveReference(expr string) (Reference, error) {
+	ref := Reference{Name: expr, Kind: ExpressionReference}
+
+	var out string
+	var err error
+
+	if out, err = g.withGit().Execute("rev-parse", "--verify", "--quiet", expr+"^{commit}"); err != nil {
+		if ExitCode(err) != 1 {
+			return ref, err
+		}
+	}
+
+	if len(out) == 0 {
+		return ref, newError(ReferenceError, "reference '%v' is not found", expr)
+	}
+
+	ref.Commit = out
+
+	// a peeled tag (e.g. 'v1.2^{}') still refers to the tag.
+	base := expr
+
+	if i := strings.Index(expr, "^{"); i > 0 && strings.HasSuffix(expr, "}") {
+		base = expr[:i]
+	}
+
+	// a hash or an expression has no symbolic name, so the failure is not relevant.
+	out, _ = g.withGit().Execute("rev-parse", "--symbolic-full-name", base)
+
+	switch {
+	case strings.HasPrefix(out, "refs/heads/"):
+		ref.Name, ref.Kind = strings.TrimPrefix(out, "refs/heads/"), BranchReference
+	case strings.HasPrefix(out, "refs/remotes/"):
+		ref.Name, ref.Kind = strings.TrimPrefix(out, "refs/remotes/"), RemoteBranchReference
+	case strings.HasPrefix(out, "refs/tags/"):
+		ref.Name, ref.Kind = strings.TrimPrefix(out, "refs/tags/"), TagReference
+	case len(out) == 0 && strings.HasPrefix(ref.Commit, strings.ToLower(expr)):
+		ref.Kind = CommitReference
+	}
+
+	return ref, nil
+}
+
+// DefaultBranch detects the branch to release from when none is specified, by falling back through the branch
+// 'origin/HEAD' points to, the 'init.defaultBranch' configuration, the currently checked out branch, then 'main' and
+// 'master', only the rules resolving to an existing local branch are considered. It returns the branch name along
+// with a description of the rule that picked it, or an error if none of the rules applies.
+func (g *Git) DefaultBranch() (string, string, error) {
+	rules := []struct {
+		description string
+		branch      func() string
+	}{
+		{"as pointed to by 'origin/HEAD'", func() string {
+			out, _ := g.withGit().Execute("symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD")
+			return strings.TrimPrefix(out, "origin/")
+		}},
+		{"by the 'init.defaultBranch' configuration", func() string {
+			out, _ := g.withGit().Execute("config", "--get", "init.defaultBranch")
+			return out
+		}},
+		{"as the currently checked out branch", func() string {
+			out, _ := g.withGit().Execute("symbolic-ref", "--quiet", "--short", "HEAD")
+			return out
+		}},
+		{"as the conventional default branch", func() string { return "main" }},
+		{"as the conventional default branch", func() string { return "master" }},
+	}
+
+	for _, rule := range rules {
+		if branch := rule.branch(); len(branch) > 0 {
+			if _, err := g.GetBranchCommit(branch); err == nil {
+				return branch, rule.description, nil
+			}
+		}
+	}
+
+	return "", "", newError(ReferenceError, "no default branch is found, please specify one")
+}
+
+// Fetch updates the remote-tracking branches of the specified remote, it returns an error on failure.
+func (g *Git) Fetch(remote string) error {
+	_, err := g.withStreamingGit().Execute("fetch", "--quiet", "--prune", remote)
+	return err
+}
+
+// IsAncestor returns true if the specified commit is reachable from the specified reference,
+// it returns an error on failure.
+func (g *Git) IsAncestor(commit, ref string) (bool, error) {
+	if _, err := g.withGit().Execute("merge-base", "--is-ancestor", commit, ref); err != nil {
+		if ExitCode(err) == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// RemoteBranchesContaining returns the names of the remote-tracking branches of the specified remote
+// that the specified commit is reachable from, it returns an error on failure.
+func (g *Git) RemoteBranchesContaining(remote, commit string) ([]string, error) {
+	out, err := g.withGit().Execute("for-each-ref", "--contains", commit, "--format=%(refname:short)",
+		fmt.Sprintf("refs/remotes/%v/", remote))
+
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []string
+
+	for _, branch := range lines(out) {
+		// 'origin/HEAD' is a symbolic reference to one of the other branches.
+		if branch != remote+"/HEAD" && branch != remote {
+			branches = append(branches, branch)
+		}
+	}
+
+	return branches, nil
+}
+
+// Upstream returns the short name of the upstream branch of the specified local branch (e.g. 'origin/main'),
+// it returns an error if there is none or on failure.
+func (g *Git) Upstream(branch string) (string, error) {
+	out, err := g.withGit().Execute("rev-parse", "--abbrev-ref", "--symbolic-full-name", branch+"@{upstream}")
+
+	if err != nil {
+		return "", newError(ReferenceError, "branch '%v' has no upstream branch", branch)
+	}
+
+	return out, nil
+}
+
+// UpstreamRemote returns the name of the remote of the upstream branch of the specified local branch,
+// it returns an error if there is none or on failure.
+func (g *Git) UpstreamRemote(branch string) (string, error) {
+	out, err := g.withGit().Execute("config", "--get", "branch."+branch+".remote")
+
+	if err != nil {
+		return "", newError(ReferenceError, "branch '%v' has no upstream remote", branch)
+	}
+
+	return out, nil
+}
+
+// CountCommits returns the number of the commits reachable from 'to' but not from 'from',
+// it returns an error on failure.
+func (g *Git) CountCommits(from, to string) (int, error) {
+	var count int
+
+	out, err := g.withGit().Execute("rev-list", "--count", fmt.Sprintf("%v..%v", from, to))
+
+	if err != nil {
+		return 0, err
+	} else if _, err = fmt.Sscan(out, &count); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RemoteTagCommit returns the commit hash the specified tag points to in the specified remote repository,
+// peeling annotated tags, it returns an error if the tag is not found or on failure.
+func (g *Git) RemoteTagCommit(remote, tag string) (string, error) {
+	out, err := g.withGit().Execute("ls-remote", "--tags", remote, "refs/tags/"+tag, "refs/tags/"+tag+"^{}")
+
+	if err != nil {
+		return "", err
+	}
+
+	var commit string
+
+	for _, line := range lines(out) {
+		fields := strings.Fields(line)
+
+		if len(fields) != 2 {
+			continue
+		} else if fields[1] == "refs/tags/"+tag+"^{}" {
+			// the peeled commit of an annotated tag takes precedence over the tag object.
+			return fields[0], nil
+		} else if fields[1] == "refs/tags/"+tag {
+			commit = fields[0]
+		}
+	}
+
+	if len(commit) == 0 {
+		return "", newError(VerificationError, "tag '%v' is not found in remote '%v'", tag, remote)
+	}
+
+	return commit, nil
+}
+
+// Tags returns the names of all the tags, it returns an error on failure.
+func (g *Git) Tags() ([]string, error) {
+	out, err := g.withGit().Execute("tag", "--list")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lines(out), nil
+}
+
+// Branches returns the names of the local branches, along with the remote-tracking branches if remote is true,
+// it returns an error on failure.
+func (g *Git) Branches(remote bool) ([]string, error) {
+	args := []string{"for-each-ref", "--format=%(refname)", "refs/heads"}
+
+	if remote {
+		args = append(args, "refs/remotes")
+	}
+
+	out, err := g.withGit().Execute(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []string
+
+	for _, ref := range lines(out) {
+		// the symbolic references of the remotes, e.g. 'origin/HEAD', are not branches.
+		if !strings.HasSuffix(ref, "/HEAD") {
+			branches = append(branches, strings.TrimPrefix(strings.TrimPrefix(ref, "refs/heads/"), "refs/remotes/"))
+		}
+	}
+
+	return branches, nil
+}
+
+// BranchesContaining returns the names of the local branches and the remote-tracking branches, without their remote
+// names, that the specified commit is reachable from, it returns an error on failure.
+func (g *Git) BranchesContaining(commit string) ([]string, error) {
+	out, err := g.withGit().Execute("for-each-ref", "--contains", commit,
+		"--format=%(refname)", "refs/heads/", "refs/remotes/")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []string
+	found := make(map[string]bool)
+
+	for _, ref := range lines(out) {
+		var name string
+
+		if strings.HasPrefix(ref, "refs/heads/") {
+			name = strings.TrimPrefix(ref, "refs/heads/")
+		} else if parts := strings.SplitN(strings.TrimPrefix(ref, "refs/remotes/"), "/", 2); len(parts) == 2 && parts[1] != "HEAD" {
+			name = parts[1]
+		}
+
+		if len(name) > 0 && !found[name] {
+			found[name] = true
+			branches = append(branches, name)
+		}
+	}
+
+	return branches, nil
+}
+
+func (g *Git) verify(kind, name, allowedSignersFile string) (Signature, error) {
+	args := []string{"verify-" + kind, "--raw", name}
+
+	if len(allowedSignersFile) > 0 {
+		args = append([]string{"-c", "gpg.ssh.allowedSignersFile=" + allowedSignersFile}, args...)
+	}
+
+	out, err := g.command(CommandSpec{Name: "git", WorkDir: g.WorkDir, Combined: true}).Execute(args...)
+
+	if s, good := ParseSignature(out); err == nil && good {
+		return s, nil
+	} else if len(out) == 0 {
+		return Signature{}, newError(VerificationError, "%v '%v' is not signed", kind, name)
+	}
+
+	return Signature{}, newError(VerificationError, "%v '%v' does not carry a valid signature: %v", kind, name, out)
+}
+
+// VerifyCommit verifies the GPG or SSH signature of the specified commit, using the specified SSH allowed signers file
+// if not empty, it returns the signature or an error if the commit is not signed or its signature is not valid.
+func (g *Git) VerifyCommit(commit, allowedSignersFile string) (Signature, error) {
+	return g.verify("commit", commit, allowedSignersFile)
+}
+
+// VerifyTag verifies the GPG or SSH signature of the specified annotated tag, using the specified SSH allowed signers
+// file if not empty, it returns the signature or an error if the tag is not signed or its signature is not valid.
+func (g *Git) VerifyTag(tag, allowedSignersFile string) (Signature, error) {
+	return g.verify("tag", tag, allowedSignersFile)
+}
+
+// CommitMessage is the hash and the full message of a git commit.
+type CommitMessage struct {
+	// Hash is the commit hash.
+	Hash string
+	// Message is the commit message including its trailers.
+	Message string
+}
+
+// Subject returns the first line of the commit message.
+func (c CommitMessage) Subject() string {
+	return strings.SplitN(strings.TrimSpace(c.Message), "\n", 2)[0]
+}
+
+// PreviousTag returns the most recent tag reachable from the specified commit and matching the specified glob pattern
+// if not empty, or an empty string if there is none, it returns an error on failure.
+func (g *Git) PreviousTag(commit, pattern string) (string, error) {
+	args := []string{"describe", "--tags", "--abbrev=0"}
+
+	if len(pattern) > 0 {
+		args = append(args, "--match", pattern)
+	}
+
+	out, err := g.withGit().Execute(append(args, commit)...)
+
+	// git fails with 128 and a 'No names found' or a 'No tags can describe' message when no tag describes the commit.
+	if e := execErrorOf(err); e != nil && e.ExitCode == 128 &&
+		(strings.HasPrefix(e.Stderr, "fatal: No names found") || strings.HasPrefix(e.Stderr, "fatal: No tags can describe")) {
+		return "", nil
+	}
+
+	return out, err
+}
+
+// Describe returns the nearest tag the specified commit is reachable from followed by the number of commits since
+// and the abbreviated commit hash (e.g. 'v1.2-3-g1a2b3c4') if it is not the tagged commit itself,
+// it returns an error if no tag describes the commit or on failure.
+func (g *Git) Describe(commit string) (string, error) {
+	return g.withGit().Execute("describe", "--tags", commit)
+}
+
+// Commits returns the commits, except the merge commits, reachable from 'to' but not from 'from', or all the ones
+// reachable from 'to' if 'from' is empty, newest first, only the ones touching any of the specified paths are returned
+// if any, it returns an error on failure.
+func (g *Git) Commits(from, to string, paths ...string) ([]CommitMessage, error) {
+	rng := to
+
+	if len(from) > 0 {
+		rng = fmt.Sprintf("%v..%v", from, to)
+	}
+
+	out, err := g.withGit().Execute(append([]string{"log", "--no-merges", "--format=%H%x00%B%x1e", rng, "--"}, paths...)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []CommitMessage
+
+	for _, record := range strings.Split(out, "\x1e") {
+		if fields := strings.SplitN(strings.TrimSpace(record), "\x00", 2); len(fields) == 2 {
+			commits = append(commits, CommitMessage{Hash: fields[0], Message: strings.TrimSpace(fields[1])})
+		}
+	}
+
+	return commits, nil
+}
+
+// Discard reverts the specified files, relative to the repository root, to their committed content in 'HEAD', and
+// removes the untracked ones, it returns an error on failure.
+func (g *Git) Discard(files ...string) error {
+	var top, out string
+	var err error
+
+	if len(files) == 0 {
+		return nil
+	} else if top, err = g.TopLevel(); err != nil {
+		return err
+	}
+
+	git := g.command(CommandSpec{Name: "git", WorkDir: top})
+
+	if out, err = git.Execute(append([]string{"ls-tree", "-r", "--name-only", "HEAD", "--"}, files...)...); err != nil {
+		return err
+	}
+
+	tracked := make(map[string]bool)
+
+	for _, file := range lines(out) {
+		tracked[file] = true
+	}
+
+	for _, file := range files {
+		if tracked[file] {
+			_, err = git.Execute("checkout", "HEAD", "--", file)
+		} else if e := os.Remove(filepath.Join(top, file)); e != nil && !os.IsNotExist(e) {
+			err = e
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
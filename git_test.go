@@ -19,6 +19,7 @@ package main
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -42,7 +43,7 @@ func (suite *GitTestSuite) SetupTest() {
 
 	suite.touch = &namedCommand{workDir: dir, name: "touch"}
 
-	suite.git = &Git{Verbose: true, WorkDir: dir}
+	suite.git = &Git{WorkDir: dir}
 
 	git := NewNamedCommand("git", dir)
 	git.Execute("init")
@@ -100,7 +101,7 @@ func TestGitTestSuite(t *testing.T) {
 }
 
 func (suite *GitTestSuite) TestGit_Status_Committed() {
-	var status string
+	var status Status
 	var err error
 
 	if status, err = suite.git.Status(); err != nil {
@@ -111,7 +112,7 @@ func (suite *GitTestSuite) TestGit_Status_Committed() {
 }
 
 func (suite *GitTestSuite) TestGit_Status_Uncommitted() {
-	var status string
+	var status Status
 	var err error
 
 	if _, err = suite.touch.Execute("dirty.go"); err != nil {
@@ -122,12 +123,49 @@ func (suite *GitTestSuite) TestGit_Status_Uncommitted() {
 		suite.Fail("failed to get status", err.Error())
 	}
 
-	assert.NotEmpty(suite.T(), status)
+	assert.Equal(suite.T(), Status{{Path: "dirty.go", Untracked: true}}, status)
+}
+
+func (suite *GitTestSuite) TestGit_Status_Categories() {
+	var status Status
+	var err error
+
+	g := NewNamedCommand("git", suite.git.WorkDir)
+
+	if _, err = g.Execute("checkout", "develop"); err != nil {
+		suite.Fail("failed to checkout branch 'develop'", err.Error())
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(suite.git.WorkDir, "README"), []byte("changed"), 0600); err != nil {
+		suite.Fail("failed to change 'README'", err.Error())
+	}
+
+	if _, err = g.Execute("mv", "empty.go", "renamed file.go"); err != nil {
+		suite.Fail("failed to rename 'empty.go'", err.Error())
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(suite.git.WorkDir, "new.go"), []byte("package main"), 0600); err != nil {
+		suite.Fail("failed to create 'new.go'", err.Error())
+	}
+
+	if _, err = g.Execute("add", "new.go"); err != nil {
+		suite.Fail("failed to add 'new.go'", err.Error())
+	}
+
+	if status, err = suite.git.Status(); err != nil {
+		suite.Fail("failed to get status", err.Error())
+	}
+
+	assert.Equal(suite.T(), Status{
+		{Path: "README", Staged: '.', Unstaged: 'M'},
+		{Path: "new.go", Staged: 'A', Unstaged: '.'},
+		{Path: "renamed file.go", OrigPath: "empty.go", Staged: 'R', Unstaged: '.'},
+	}, status)
 }
 
 func (suite *GitTestSuite) TestGit_Status_Failure() {
 	var err error
-	var status string
+	var status Status
 
 	if err = os.RemoveAll(suite.git.WorkDir); err != nil {
 		suite.Fail("failed to remove work directory", err.Error())
@@ -292,3 +330,315 @@ func (suite *GitTestSuite) TestGit_GetBranchCommit_SuccessDevelop() {
 	assert.Equal(suite.T(), hash, commit)
 	assert.Nil(suite.T(), err)
 }
+
+func (suite *GitTestSuite) TestGit_HeadCommit_Success() {
+	var err error
+	var hash string
+	var head string
+
+	if hash, err = NewNamedCommand("git", suite.git.WorkDir).Execute("show", "-s", "--format=%H"); err != nil {
+		suite.Fail("failed to get recent commit")
+		return
+	}
+
+	head, err = suite.git.HeadCommit()
+	assert.Equal(suite.T(), hash, head)
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *GitTestSuite) TestGit_TreeState_Clean() {
+	state, err := suite.git.TreeState()
+	assert.Nil(suite.T(), err)
+	assert.False(suite.T(), state.Dirty())
+	assert.Equal(suite.T(), "clean", state.String())
+	assert.Empty(suite.T(), state.DiffHash)
+}
+
+func (suite *GitTestSuite) TestGit_TreeState_Dirty() {
+	var err error
+
+	if err = ioutil.WriteFile(filepath.Join(suite.git.WorkDir, "README"), []byte("changed"), 0600); err != nil {
+		suite.Fail("failed to change 'README'", err.Error())
+	}
+
+	if _, err = suite.touch.Execute("dirty.go"); err != nil {
+		suite.Fail("failed to touch 'dirty.go'", err.Error())
+	}
+
+	state, err := suite.git.TreeState()
+	assert.Nil(suite.T(), err)
+	assert.True(suite.T(), state.Dirty())
+	assert.Equal(suite.T(), "dirty", state.String())
+	assert.Equal(suite.T(), []string{"README", "dirty.go"}, state.Files)
+	assert.Len(suite.T(), state.DiffHash, 64)
+
+	if err = ioutil.WriteFile(filepath.Join(suite.git.WorkDir, "dirty.go"), []byte("package main"), 0600); err != nil {
+		suite.Fail("failed to change 'dirty.go'", err.Error())
+	}
+
+	changed, err := suite.git.TreeState()
+	assert.Nil(suite.T(), err)
+	assert.NotEqual(suite.T(), state.DiffHash, changed.DiffHash)
+}
+
+func (suite *GitTestSuite) TestGit_TreeState_Failure() {
+	if err := os.RemoveAll(suite.git.WorkDir); err != nil {
+		suite.Fail("failed to remove work directory", err.Error())
+	}
+
+	_, err := suite.git.TreeState()
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *GitTestSuite) addSubmodule(path string) string {
+	var dir, commit string
+	var err error
+
+	if dir, err = ioutil.TempDir("", "test_rego_submodule_"); err != nil {
+		suite.Fail("failed to create temporary directory for the submodule", err.Error())
+		return ""
+	}
+
+	suite.T().Cleanup(func() { os.RemoveAll(dir) })
+
+	sub := NewNamedCommand("git", dir)
+	sub.Execute("init")
+	sub.Execute("config", "commit.gpgsign", "false")
+
+	if _, err = sub.Execute("commit", "-n", "--allow-empty", "-m", "'Initial commit'"); err != nil {
+		suite.Fail("failed to commit in the submodule", err.Error())
+	}
+
+	if commit, err = sub.Execute("rev-parse", "HEAD"); err != nil {
+		suite.Fail("failed to get the submodule commit", err.Error())
+	}
+
+	git := NewNamedCommand("git", suite.git.WorkDir)
+
+	if _, err = git.Execute("-c", "protocol.file.allow=always", "submodule", "add", dir, path); err != nil {
+		suite.Fail("failed to add the submodule", err.Error())
+	}
+
+	if _, err = git.Execute("commit", "-n", "-m", "'Adding submodule'"); err != nil {
+		suite.Fail("failed to commit the submodule", err.Error())
+	}
+
+	return commit
+}
+
+func (suite *GitTestSuite) TestGit_Submodules_None() {
+	submodules, err := suite.git.Submodules()
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), submodules)
+}
+
+func (suite *GitTestSuite) TestGit_Submodules_InSync() {
+	commit := suite.addSubmodule("lib")
+
+	submodules, err := suite.git.Submodules()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []Submodule{{Path: "lib", Commit: commit, State: SubmoduleInSync}}, submodules)
+}
+
+func (suite *GitTestSuite) TestGit_UpdateSubmodules_Uninitialized() {
+	commit := suite.addSubmodule("lib")
+
+	if _, err := NewNamedCommand("git", suite.git.WorkDir).Execute("submodule", "deinit", "lib"); err != nil {
+		suite.Fail("failed to deinit the submodule", err.Error())
+	}
+
+	submodules, err := suite.git.Submodules()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []Submodule{{Path: "lib", Commit: commit, State: SubmoduleUninitialized}}, submodules)
+
+	assert.Nil(suite.T(), suite.git.UpdateSubmodules())
+
+	submodules, err = suite.git.Submodules()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []Submodule{{Path: "lib", Commit: commit, State: SubmoduleInSync}}, submodules)
+}
+
+func (suite *GitTestSuite) TestGit_UpdateSubmodules_OutOfSync() {
+	commit := suite.addSubmodule("lib")
+
+	sub := NewNamedCommand("git", filepath.Join(suite.git.WorkDir, "lib"))
+
+	if _, err := sub.Execute("commit", "-n", "--allow-empty", "-m", "'Moving on'"); err != nil {
+		suite.Fail("failed to commit in the submodule", err.Error())
+	}
+
+	submodules, err := suite.git.Submodules()
+	assert.Nil(suite.T(), err)
+	assert.Len(suite.T(), submodules, 1)
+	assert.Equal(suite.T(), byte(SubmoduleOutOfSync), submodules[0].State)
+	assert.NotEqual(suite.T(), commit, submodules[0].Commit)
+
+	assert.Nil(suite.T(), suite.git.UpdateSubmodules())
+
+	submodules, err = suite.git.Submodules()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []Submodule{{Path: "lib", Commit: commit, State: SubmoduleInSync}}, submodules)
+}
+
+func (suite *GitTestSuite) TestGit_Submodules_Failure() {
+	g := &Git{WorkDir: os.TempDir()}
+	_, err := g.Submodules()
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *GitTestSuite) TestGit_ResolveReference() {
+	git := NewNamedCommand("git", suite.git.WorkDir)
+
+	master, _ := git.Execute("rev-parse", "master")
+	develop, _ := git.Execute("rev-parse", "develop")
+
+	if _, err := git.Execute("tag", "-a", "-m", "'Annotated'", "v1.1", "develop"); err != nil {
+		suite.Fail("failed to create annotated tag 'v1.1'", err.Error())
+	}
+
+	if _, err := git.Execute("update-ref", "refs/remotes/origin/develop", "develop"); err != nil {
+		suite.Fail("failed to create remote branch 'origin/develop'", err.Error())
+	}
+
+	for _, c := range []struct {
+		expr string
+		ref  Reference
+	}{
+		{"master", Reference{Name: "master", Kind: BranchReference, Commit: master}},
+		{"origin/develop", Reference{Name: "origin/develop", Kind: RemoteBranchReference, Commit: develop}},
+		{"v1.0", Reference{Name: "v1.0", Kind: TagReference, Commit: master}},
+		{"v1.1^{}", Reference{Name: "v1.1", Kind: TagReference, Commit: develop}},
+		{develop[:7], Reference{Name: develop[:7], Kind: CommitReference, Commit: develop}},
+		{"develop~1", Reference{Name: "develop~1", Kind: ExpressionReference, Commit: master}},
+	} {
+		ref, err := suite.git.ResolveReference(c.expr)
+		assert.Nil(suite.T(), err, c.expr)
+		assert.Equal(suite.T(), c.ref, ref, c.expr)
+	}
+}
+
+func (suite *GitTestSuite) TestGit_ResolveReference_NotFound() {
+	_, err := suite.git.ResolveReference("unknown")
+	assert.EqualError(suite.T(), err, "reference 'unknown' is not found")
+}
+
+func (suite *GitTestSuite) TestGit_ResolveReference_FailureNoRepo() {
+	g := &Git{WorkDir: os.TempDir()}
+	_, err := g.ResolveReference("HEAD")
+	assert.NotNil(suite.T(), err)
+	assert.NotEqual(suite.T(), "reference 'HEAD' is not found", err.Error())
+}
+
+func (suite *GitTestSuite) TestGit_DefaultBranch_OriginHead() {
+	git := NewNamedCommand("git", suite.git.WorkDir)
+	git.Execute("update-ref", "refs/remotes/origin/develop", "develop")
+	git.Execute("symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/develop")
+
+	branch, rule, err := suite.git.DefaultBranch()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "develop", branch)
+	assert.Equal(suite.T(), "as pointed to by 'origin/HEAD'", rule)
+}
+
+func (suite *GitTestSuite) TestGit_DefaultBranch_InitDefaultBranch() {
+	git := NewNamedCommand("git", suite.git.WorkDir)
+	git.Execute("config", "init.defaultBranch", "develop")
+
+	branch, rule, err := suite.git.DefaultBranch()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "develop", branch)
+	assert.Equal(suite.T(), "by the 'init.defaultBranch' configuration", rule)
+}
+
+func (suite *GitTestSuite) TestGit_DefaultBranch_CurrentBranch() {
+	git := NewNamedCommand("git", suite.git.WorkDir)
+	git.Execute("config", "init.defaultBranch", "unknown")
+	git.Execute("checkout", "develop")
+
+	branch, rule, err := suite.git.DefaultBranch()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "develop", branch)
+	assert.Equal(suite.T(), "as the currently checked out branch", rule)
+}
+
+func (suite *GitTestSuite) TestGit_DefaultBranch_Conventional() {
+	git := NewNamedCommand("git", suite.git.WorkDir)
+	git.Execute("config", "init.defaultBranch", "unknown")
+	git.Execute("checkout", "--detach", "develop")
+
+	branch, rule, err := suite.git.DefaultBranch()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "master", branch)
+	assert.Equal(suite.T(), "as the conventional default branch", rule)
+}
+
+func (suite *GitTestSuite) TestGit_DefaultBranch_NotFound() {
+	git := NewNamedCommand("git", suite.git.WorkDir)
+	git.Execute("config", "init.defaultBranch", "unknown")
+	git.Execute("checkout", "--detach", "develop")
+	git.Execute("branch", "-m", "master", "stable")
+
+	_, _, err := suite.git.DefaultBranch()
+	assert.EqualError(suite.T(), err, "no default branch is found, please specify one")
+}
+
+func (suite *GitTestSuite) TestGit_PreviousTag() {
+	git := NewNamedCommand("git", suite.git.WorkDir)
+	git.Execute("tag", "services/api/v0.9", "develop")
+
+	tag, err := suite.git.PreviousTag("develop", "")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "services/api/v0.9", tag)
+
+	tag, err = suite.git.PreviousTag("develop", "v*")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "v1.0", tag)
+
+	tag, err = suite.git.PreviousTag("develop", "web/*")
+	assert.Nil(suite.T(), err)
+	assert.Empty(suite.T(), tag)
+}
+
+func (suite *GitTestSuite) TestGit_PreviousTag_Failure() {
+	_, err := suite.git.PreviousTag("unknown", "")
+	assert.NotNil(suite.T(), err)
+	assert.Equal(suite.T(), 128, ExitCode(err))
+}
+
+func TestGit_PreviousTag_NoTags(t *testing.T) {
+	fake := NewFakeCommander(CommandCall{
+		Name:     "git",
+		Args:     []string{"describe", "--tags", "--abbrev=0", "HEAD"},
+		ExitCode: 128,
+		Stderr:   "fatal: No names found, cannot describe anything.",
+	})
+
+	tag, err := (&Git{WorkDir: "/work", Commander: fake}).PreviousTag("HEAD", "")
+
+	assert.Nil(t, err)
+	assert.Empty(t, tag)
+	assert.Empty(t, fake.Remaining())
+}
+
+func (suite *GitTestSuite) TestGit_Commits() {
+	git := NewNamedCommand("git", suite.git.WorkDir)
+	master, _ := git.Execute("rev-parse", "master")
+	develop, _ := git.Execute("rev-parse", "develop")
+
+	commits, err := suite.git.Commits("master", "develop")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []CommitMessage{{Hash: develop, Message: "'Adding empty.go'"}}, commits)
+
+	commits, err = suite.git.Commits("", "develop")
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []CommitMessage{
+		{Hash: develop, Message: "'Adding empty.go'"},
+		{Hash: master, Message: "'Initial commit'"},
+	}, commits)
+	assert.Equal(suite.T(), "'Initial commit'", commits[1].Subject())
+}
+
+func (suite *GitTestSuite) TestGit_Commits_Failure() {
+	_, err := suite.git.Commits("master", "unknown")
+	assert.NotNil(suite.T(), err)
+}
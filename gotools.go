@@ -17,22 +17,372 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
+// BuildOptions holds the optional flags passed to the Go compiler while building a binary release.
+type BuildOptions struct {
+	// Tags is the list of build tags passed through '-tags'.
+	Tags []string `json:"tags,omitempty"`
+	// LDFlags is the extra linker flags, merged with the '-X' flags injecting the release information.
+	LDFlags string `json:"ldflags,omitempty"`
+	// GCFlags is the flags passed through '-gcflags' to the compiler.
+	GCFlags string `json:"gcflags,omitempty"`
+	// ASMFlags is the flags passed through '-asmflags' to the assembler.
+	ASMFlags string `json:"asmflags,omitempty"`
+	// Race enables the data race detection through '-race', or explicitly disables it if false.
+	Race *bool `json:"race,omitempty"`
+	// Cover enables the code coverage instrumentation through '-cover', or explicitly disables it if false.
+	Cover *bool `json:"cover,omitempty"`
+	// BuildMode is the build mode passed through '-buildmode'.
+	BuildMode string `json:"buildmode,omitempty"`
+	// GoFlags is the value of the 'GOFLAGS' environment variable used while building.
+	GoFlags string `json:"goflags,omitempty"`
+	// ReleaseInfo is the way the release information is embedded, either 'ldflags' (the default) which passes '-X'
+	// linker flags or 'source' which generates a Go file into the packages while building.
+	ReleaseInfo string `json:"releaseInfo,omitempty"`
+}
+
+// Merge returns a copy of these options overridden by the non-empty values of the specified options.
+func (o BuildOptions) Merge(override BuildOptions) BuildOptions {
+	merged := o
+
+	if len(override.Tags) > 0 {
+		merged.Tags = override.Tags
+	}
+
+	if len(override.LDFlags) > 0 {
+		merged.LDFlags = override.LDFlags
+	}
+
+	if len(override.GCFlags) > 0 {
+		merged.GCFlags = override.GCFlags
+	}
+
+	if len(override.ASMFlags) > 0 {
+		merged.ASMFlags = override.ASMFlags
+	}
+
+	if len(override.BuildMode) > 0 {
+		merged.BuildMode = override.BuildMode
+	}
+
+	if len(override.GoFlags) > 0 {
+		merged.GoFlags = override.GoFlags
+	}
+
+	if len(override.ReleaseInfo) > 0 {
+		merged.ReleaseInfo = override.ReleaseInfo
+	}
+
+	if override.Race != nil {
+		merged.Race = override.Race
+	}
+
+	if override.Cover != nil {
+		merged.Cover = override.Cover
+	}
+
+	return merged
+}
+
+// enabled returns true if the specified build option is set and enabled.
+func enabled(option *bool) bool {
+	return option != nil && *option
+}
+
+// BuildTarget is an operating system and architecture pair in a cross-build matrix,
+// along with the build options overriding the common ones for this pair only.
+type BuildTarget struct {
+	// OS is the target operating system passed as 'GOOS'.
+	OS string `json:"os"`
+	// Arch is the target architecture passed as 'GOARCH'.
+	Arch string `json:"arch"`
+	// Build is the build options overriding the common ones while building this target.
+	Build BuildOptions `json:"build"`
+}
+
+// String returns the target in the 'os/arch' format.
+func (t BuildTarget) String() string {
+	return fmt.Sprintf("%v/%v", t.OS, t.Arch)
+}
+
+// Env returns the environment variables selecting this target while building.
+func (t BuildTarget) Env() []string {
+	var env []string
+
+	if len(t.OS) > 0 {
+		env = append(env, fmt.Sprintf("GOOS=%v", t.OS))
+	}
+
+	if len(t.Arch) > 0 {
+		env = append(env, fmt.Sprintf("GOARCH=%v", t.Arch))
+	}
+
+	return env
+}
+
+// Variant is a named flavor of the binary release (e.g. 'release', 'debug' or 'coverage'),
+// all the variants are built from the same commit with the same release information.
+type Variant struct {
+	// Name is the variant name, injected into the binary through the public variable 'BuildVariant'.
+	Name string `json:"name"`
+	// Build is the build options overriding the common ones while building this variant.
+	Build BuildOptions `json:"build"`
+	// Env is the extra environment variables in the 'KEY=VALUE' format used while building this variant.
+	Env []string `json:"env"`
+	// Suffix is appended to the binary file name of this variant.
+	Suffix string `json:"suffix"`
+}
+
+// Binary overrides the binary name and the injected variables names of a main package.
+type Binary struct {
+	// Package is the main package import path or its path relative to the working directory (e.g. './cmd/api').
+	Package string `json:"package"`
+	// Name is the binary file name, the package directory name is used if empty.
+	Name string `json:"name"`
+	// Variables maps the names of the injected variables to the names declared in the package source.
+	Variables map[string]string `json:"variables"`
+}
+
+// Matches returns true if this binary overrides the specified main package found in the specified working directory.
+func (b Binary) Matches(workDir string, p MainPackage) bool {
+	if b.Package == p.ImportPath {
+		return true
+	}
+
+	dir := b.Package
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workDir, dir)
+	}
+
+	return filepath.Clean(dir) == filepath.Clean(p.Dir)
+}
+
 // GoTools wraps some Golang commands with some additional flags for quick use.
 type GoTools struct {
 	// WorkDir is the working directory where the command is being executed.
 	WorkDir string
-	// Verbose shows more verbose output while execution.
-	Verbose bool
+	// Logger receives the progress and the output of the long-running Go commands at the debug level and every Go
+	// command run at the trace level, nothing is logged if not set.
+	Logger *slog.Logger
+	// Options is the build options used while installing.
+	Options BuildOptions
+	// Env is the extra environment variables in the 'KEY=VALUE' format used while installing.
+	Env []string
+	// Suffix is appended to the installed binary file name if not empty.
+	Suffix string
+	// Variables is the extra public variables injected into the binary, mapped by name to value.
+	Variables map[string]string
+	// Timestamp is the build time injected into the binary, the current time is used if it is zero.
+	Timestamp time.Time
+	// Toolchain is the Go toolchain used in every command.
+	Toolchain Toolchain
+	// Context cancels the running Go commands once it is done, no command is cancelled if not set.
+	Context context.Context
+	// Commander creates the Go commands, the real ones are executed if not set.
+	Commander Commander
+	// Package is the import path of the main package to install, the package in the working directory is installed if empty.
+	Package string
+	// Name is the installed binary file name, the name chosen by 'go install' is used if empty.
+	Name string
+	// Mappings maps the names of the injected variables to the names declared in the binary source, a mapped name is
+	// either a variable name in the package passed to Install or a fully qualified one (e.g. 'example.com/project/version.Commit'),
+	// a variable mapped to an empty name is not injected.
+	Mappings map[string]string
+
+	// version caches the version of the Go toolchain.
+	version string
+}
+
+// MainPackage is a main package found in the working directory.
+type MainPackage struct {
+	// ImportPath is the package import path.
+	ImportPath string
+	// Dir is the package directory.
+	Dir string
+}
+
+// Name returns the binary name 'go install' chooses for this package, which is the last element of its import path
+// unless it is a major version suffix (e.g. 'v2').
+func (p MainPackage) Name() string {
+	name := path.Base(p.ImportPath)
+
+	if majorVersionPattern.MatchString(name) && path.Dir(p.ImportPath) != "." {
+		name = path.Base(path.Dir(p.ImportPath))
+	}
+
+	return name
+}
+
+var majorVersionPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+func (g *GoTools) command(spec CommandSpec) Command {
+	spec.Context, spec.Logger = g.Context, g.Logger
+	return newCommand(g.Commander, spec)
+}
+
+// withGo returns a Go command whose failures are toolchain errors.
+func (g *GoTools) withGo(env ...string) Command {
+	c := g.command(CommandSpec{Name: g.Toolchain.command(), WorkDir: g.WorkDir, Env: append(g.Toolchain.Env(), env...)})
+	return &categorizedCommand{Command: c, category: ToolchainError}
+}
+
+// withStreamingGo returns a Go command whose output is logged at the debug level, for the long-running commands,
+// its failures are of the specified category.
+func (g *GoTools) withStreamingGo(category ErrorCategory, env ...string) Command {
+	c := g.command(CommandSpec{
+		Name:    g.Toolchain.command(),
+		WorkDir: g.WorkDir,
+		Env:     append(g.Toolchain.Env(), env...),
+		Stream:  commandStream(g.Logger, g.Toolchain.command()),
+	})
+	return &categorizedCommand{Command: c, category: category}
+}
+
+// Version returns the version of the Go toolchain (e.g. 'go1.22.3'), it returns an error on failure.
+func (g *GoTools) Version() (string, error) {
+	if len(g.version) > 0 {
+		return g.version, nil
+	}
+
+	version, err := g.withGo().Execute("env", "GOVERSION")
+
+	if err == nil {
+		g.version = version
+	}
+
+	return version, err
 }
 
-func (g *GoTools) withGo() Command {
-	return NewNamedCommand(runtime.GOROOT()+"/bin/go", g.WorkDir)
+// GoEnv returns the values of the specified Go environment variables, it returns an error on failure.
+func (g *GoTools) GoEnv(names ...string) (map[string]string, error) {
+	out, err := g.withGo().Execute(append([]string{"env", "-json"}, names...)...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+
+	if err = json.Unmarshal([]byte(out), &values); err != nil {
+		return nil, errors.Wrap(err, "invalid output of 'go env -json'")
+	}
+
+	return values, nil
+}
+
+// Module returns the path and the directory of the main module of the working directory, it returns an error on failure.
+func (g *GoTools) Module() (string, string, error) {
+	out, err := g.withGo().Execute("list", "-m", "-f", "{{.Path}}\t{{.Dir}}")
+
+	if err != nil {
+		return "", "", err
+	}
+
+	// a workspace lists all of its modules, the first one is the module of the working directory.
+	fields := strings.SplitN(strings.SplitN(out, "\n", 2)[0], "\t", 2)
+
+	if len(fields) < 2 || len(fields[0]) == 0 {
+		return "", "", newError(ToolchainError, "no module is found in '%v'", g.WorkDir)
+	}
+
+	return fields[0], strings.TrimSpace(fields[1]), nil
+}
+
+// PackageDir returns the directory of the specified package, it returns an error on failure.
+func (g *GoTools) PackageDir(pkg string) (string, error) {
+	return g.withGo(g.installEnv()...).Execute(append(append([]string{"list"}, g.tagsFlags()...), "-f", "{{.Dir}}", pkg)...)
+}
+
+// packageFiles returns the paths of the Go files of the specified package selected by the build, i.e. by the build
+// constraints and the file names matching the target platform and the build tags, the test files excepted.
+func (g *GoTools) packageFiles(pkg string) ([]string, error) {
+	args := append(append([]string{"list"}, g.tagsFlags()...),
+		"-f", "{{.Dir}}{{range .GoFiles}}\t{{.}}{{end}}{{range .CgoFiles}}\t{{.}}{{end}}", pkg)
+
+	out, err := g.withGo(g.installEnv()...).Execute(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Split(out, "\t")
+
+	var files []string
+
+	for _, name := range fields[1:] {
+		files = append(files, filepath.Join(fields[0], name))
+	}
+
+	return files, nil
+}
+
+// CheckModule returns an error if the version of the Go toolchain does not satisfy the 'go' and 'toolchain'
+// directives of the 'go.mod' file of the module of the working directory, or of the 'go.work' file of its workspace.
+func (g *GoTools) CheckModule() error {
+	var env map[string]string
+	var version string
+	var err error
+
+	if env, err = g.GoEnv("GOMOD", "GOWORK"); err != nil {
+		return err
+	} else if version, err = g.Version(); err != nil {
+		return err
+	}
+
+	for _, file := range []string{env["GOMOD"], env["GOWORK"]} {
+		if len(file) == 0 || file == os.DevNull || file == "off" {
+			continue
+		}
+
+		d, err := readGoDirectives(file)
+
+		if err != nil {
+			return err
+		} else if err = d.check(version); err != nil {
+			return fmt.Errorf("'%v' %v", file, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// MainPackages returns the main packages matching the specified package pattern (e.g. './cmd/...') in the working directory,
+// the build tags of the build options are considered while matching.
+func (g *GoTools) MainPackages(pattern string) ([]MainPackage, error) {
+	var packages []MainPackage
+
+	args := append(append([]string{"list"}, g.buildFlags()...),
+		"-f", "{{if eq .Name \"main\"}}{{.ImportPath}}\t{{.Dir}}{{end}}", pattern)
+
+	out, err := g.withGo(g.installEnv()...).Execute(args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.SplitN(strings.TrimSpace(line), "\t", 2); len(fields) == 2 {
+			packages = append(packages, MainPackage{ImportPath: fields[0], Dir: fields[1]})
+		}
+	}
+
+	return packages, nil
 }
 
 // Clean invokes: 'go clean -i ./...'.
@@ -46,29 +396,367 @@ func (g *GoTools) Clean() error {
 	return nil
 }
 
-// Install invokes: 'go install -ldflags -X <pkg>.GitCommit=<commit> -X <pkg>.ReleaseVersion=<releaseVersion> -X <pkg>.BuildTimestamp=<current timestamp formatted in RFC3339>'.
+// buildFlags returns the flags built from the build options that are shared among the Go commands.
+func (g *GoTools) buildFlags() []string {
+	var args []string
+
+	if len(g.Options.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(g.Options.Tags, ","))
+	}
+
+	if len(g.Options.GCFlags) > 0 {
+		args = append(args, "-gcflags", g.Options.GCFlags)
+	}
+
+	if len(g.Options.ASMFlags) > 0 {
+		args = append(args, "-asmflags", g.Options.ASMFlags)
+	}
+
+	if enabled(g.Options.Race) {
+		args = append(args, "-race")
+	}
+
+	if enabled(g.Options.Cover) {
+		args = append(args, "-cover")
+	}
+
+	if len(g.Options.BuildMode) > 0 {
+		args = append(args, "-buildmode", g.Options.BuildMode)
+	}
+
+	return args
+}
+
+// installArgs returns the 'go install' arguments built from the build options, or the 'go build -o <output>'
+// arguments if an output is specified, the specified variables are merged into the '-ldflags' value after the extra linker flags.
+func (g *GoTools) installArgs(output string, vars []string) []string {
+	args := []string{"install"}
+
+	if len(output) > 0 {
+		args = []string{"build", "-o", output}
+	}
+
+	args = append(args, g.buildFlags()...)
+
+	if ldflags := strings.TrimSpace(fmt.Sprintf("%v %v", g.Options.LDFlags, strings.Join(vars, " "))); len(ldflags) > 0 {
+		args = append(args, "-ldflags", ldflags)
+	}
+
+	if len(g.Package) > 0 {
+		args = append(args, g.Package)
+	}
+
+	return args
+}
+
+// installEnv returns the extra environment variables used while installing.
+func (g *GoTools) installEnv() []string {
+	env := append([]string{}, g.Env...)
+
+	if len(g.Options.GoFlags) > 0 {
+		env = append(env, fmt.Sprintf("GOFLAGS=%v", g.Options.GoFlags))
+	}
+
+	return env
+}
+
+// crossTarget returns the '<os>_<arch>' directory name of the target selected by the environment variables if it is
+// not the host, or an empty string otherwise.
+func (g *GoTools) crossTarget() string {
+	goos, goarch := runtime.GOOS, runtime.GOARCH
+
+	for _, v := range g.installEnv() {
+		if strings.HasPrefix(v, "GOOS=") {
+			goos = strings.TrimPrefix(v, "GOOS=")
+		} else if strings.HasPrefix(v, "GOARCH=") {
+			goarch = strings.TrimPrefix(v, "GOARCH=")
+		}
+	}
+
+	if goos == runtime.GOOS && goarch == runtime.GOARCH {
+		return ""
+	}
+
+	return fmt.Sprintf("%v_%v", goos, goarch)
+}
+
+// output returns the path of the binary file named after 'Name' with the suffix appended to it, next to where 'go install'
+// would have put it, or an empty string if there is neither a name nor a suffix and the target is the host, since
+// 'go install' refuses to install the cross-compiled binaries once 'GOBIN' is set.
+func (g *GoTools) output() (string, error) {
+	cross := g.crossTarget()
+
+	if len(g.Suffix) == 0 && len(g.Name) == 0 && len(cross) == 0 {
+		return "", nil
+	}
+
+	args := append(append([]string{"list"}, g.buildFlags()...), "-f", "{{.Target}}")
+
+	if len(g.Package) > 0 {
+		args = append(args, g.Package)
+	}
+
+	target, err := g.withGo(g.installEnv()...).Execute(args...)
+
+	if err == nil && len(target) == 0 && len(cross) > 0 {
+		target, err = g.crossOutput(cross)
+	}
+
+	if err != nil {
+		return "", err
+	} else if len(target) == 0 {
+		return "", fmt.Errorf("failed to find the install location of the binary in '%v'", g.WorkDir)
+	}
+
+	ext := filepath.Ext(target)
+
+	if ext != ".exe" {
+		ext = ""
+	}
+
+	name := strings.TrimSuffix(filepath.Base(target), ext)
+
+	if len(g.Name) > 0 {
+		name = g.Name
+	}
+
+	return filepath.Join(filepath.Dir(target), name+g.Suffix+ext), nil
+}
+
+// crossOutput returns where a cross-compiled binary goes when 'GOBIN' is set, which has no install location for
+// 'go list', that is the '<os>_<arch>' directory under 'GOBIN' as 'go install' does under 'GOPATH/bin'.
+func (g *GoTools) crossOutput(cross string) (string, error) {
+	bin, err := g.withGo(g.installEnv()...).Execute("env", "GOBIN")
+
+	if err != nil || len(bin) == 0 {
+		return "", err
+	}
+
+	args := append(append([]string{"list"}, g.tagsFlags()...), "-f", "{{.ImportPath}}")
+
+	if len(g.Package) > 0 {
+		args = append(args, g.Package)
+	}
+
+	pkg, err := g.withGo(g.installEnv()...).Execute(args...)
+
+	if err != nil {
+		return "", err
+	}
+
+	name := MainPackage{ImportPath: pkg}.Name()
+
+	if strings.HasPrefix(cross, "windows_") {
+		name += ".exe"
+	}
+
+	return filepath.Join(bin, cross, name), nil
+}
+
+// variables returns the '-X' linker flags of the specified variables followed by the extra ones sorted by name,
+// the variables are qualified by the specified package unless mapped otherwise.
+func (g *GoTools) variables(pkg string, values [][2]string) []string {
+	return linkerFlags(g.qualified(pkg, values))
+}
+
+// linkerFlags returns the '-X' linker flags of the specified qualified variables.
+func linkerFlags(values [][2]string) []string {
+	var vars []string
+
+	for _, v := range values {
+		vars = append(vars, "-X", fmt.Sprintf("\"%v=%v\"", v[0], v[1]))
+	}
+
+	return vars
+}
+
+// qualified returns the specified variables followed by the extra ones sorted by name, the variables are qualified
+// by the specified package unless mapped otherwise.
+func (g *GoTools) qualified(pkg string, values [][2]string) [][2]string {
+	var names []string
+	var vars [][2]string
+
+	for name := range g.Variables {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		values = append(values, [2]string{name, g.Variables[name]})
+	}
+
+	for _, v := range values {
+		name := v[0]
+
+		if mapped, found := g.Mappings[name]; found {
+			if name = strings.TrimSpace(mapped); len(name) == 0 {
+				continue
+			}
+		}
+
+		if !strings.Contains(name, ".") {
+			name = fmt.Sprintf("%v.%v", pkg, name)
+		}
+
+		vars = append(vars, [2]string{name, v[1]})
+	}
+
+	return vars
+}
+
+// Install invokes: 'go install -ldflags -X <pkg>.GitCommit=<commit> -X <pkg>.ReleaseVersion=<releaseVersion> -X <pkg>.BuildTimestamp=<current timestamp formatted in RFC3339>'
+// along with the flags specified in the build options and the extra variables, if a name or a suffix is specified
+// it invokes 'go build -o <install location with the name and the suffix>' instead. If the release information is
+// embedded through the 'source' mode, the variables are set by a generated Go file instead, which is removed once built.
 // See 'go install --help'
 func (g *GoTools) Install(commit, releaseVersion, pkg string) error {
 
 	var err error
 	var goVersion string
+	var output string
+
+	if err = ValidReleaseInfo(g.Options.ReleaseInfo); err != nil {
+		return err
+	}
 
-	now := time.Now().UTC()
+	now := g.Timestamp
 
-	goVersion, _ = g.withGo().Execute("version")
+	if now.IsZero() {
+		now = time.Now().UTC()
+	}
+
+	if goVersion, err = g.Version(); err != nil {
+		return err
+	}
+
+	values := g.qualified(pkg, [][2]string{
+		{"GitCommit", commit},
+		{"BuildTimestamp", now.Format(time.RFC3339)},
+		{"ReleaseVersion", releaseVersion},
+		{"GoVersion", goVersion},
+	})
+
+	if output, err = g.output(); err != nil {
+		return err
+	}
 
-	vars := []string{
-		"-X", fmt.Sprintf("\"%v.GitCommit=%v\"", pkg, commit),
-		"-X", fmt.Sprintf("\"%v.BuildTimestamp=%v\"", pkg, now.Format(time.RFC3339)),
-		"-X", fmt.Sprintf("\"%v.ReleaseVersion=%v\"", pkg, releaseVersion),
-		"-X", fmt.Sprintf("\"%v.GoVersion=%v\"", pkg, goVersion),
+	args := g.installArgs(output, linkerFlags(values))
+
+	if g.Options.ReleaseInfo == sourceReleaseInfo {
+		var generated []string
+
+		generated, err = g.writeReleaseInfo(values)
+
+		// whatever happens, the generated files are not left in the working tree.
+		defer func() {
+			if e := removeReleaseInfo(generated); e != nil {
+				loggerOrDiscard(g.Logger).Warn("failed to remove the generated release information", "files", generated, "error", e)
+			}
+		}()
+
+		if err != nil {
+			return err
+		}
+
+		source := *g
+		source.Options.Tags = append(append([]string{}, g.Options.Tags...), releaseInfoTag)
+		args = source.installArgs(output, nil)
 	}
 
-	args := []string{"install", "-ldflags", strings.Join(vars, " ")}
+	loggerOrDiscard(g.Logger).Debug("installing", "args", args)
 
-	if _, err = NewNamedCommand("go", g.WorkDir).Execute(args...); err != nil {
+	if _, err = g.withStreamingGo(BuildError, g.installEnv()...).Execute(args...); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// tagsFlags returns the '-tags' flag of the build options if any, which is the only build flag shared with all the
+// Go commands that do not build binaries.
+func (g *GoTools) tagsFlags() []string {
+	if len(g.Options.Tags) > 0 {
+		return []string{"-tags", strings.Join(g.Options.Tags, ",")}
+	}
+	return nil
+}
+
+// Vet invokes: 'go vet <flags> <packages>' with the build tags, it returns the output or an error describing the
+// reported problems.
+func (g *GoTools) Vet(packages, flags []string) (string, error) {
+	args := append(append(append([]string{"vet"}, g.tagsFlags()...), flags...), packages...)
+	return g.withStreamingGo(GateError, g.installEnv()...).Execute(args...)
+}
+
+// Test invokes: 'go test <flags> <packages>' with the flags of the build options, it returns the output or an error
+// describing the failures.
+func (g *GoTools) Test(packages, flags []string) (string, error) {
+	args := append(append(append([]string{"test"}, g.buildFlags()...), flags...), packages...)
+	out, err := g.withStreamingGo(GateError, g.installEnv()...).Execute(args...)
+
+	if err != nil && len(out) > 0 {
+		// the failures are reported on the standard output.
+		return out, fmt.Errorf("%v%v%v", err.Error(), NewLine(), out)
+	}
+
+	return out, err
+}
+
+// Generate invokes: 'go generate <packages>' with the build tags, it returns an error on failure.
+func (g *GoTools) Generate(packages []string) (string, error) {
+	args := append(append([]string{"generate"}, g.tagsFlags()...), packages...)
+	return g.withStreamingGo(GateError, g.installEnv()...).Execute(args...)
+}
+
+// ModTidy invokes: 'go mod tidy', it returns an error on failure.
+func (g *GoTools) ModTidy() (string, error) {
+	return g.withGo().Execute("mod", "tidy")
+}
+
+// ModVerify invokes: 'go mod verify', it returns an error if a dependency has been modified since it was downloaded.
+func (g *GoTools) ModVerify() (string, error) {
+	return g.withGo().Execute("mod", "verify")
+}
+
+// UnformattedFiles returns the Go files of the specified packages whose formatting differs from the one of the
+// 'gofmt' of the Go toolchain, it returns an error on failure.
+func (g *GoTools) UnformattedFiles(packages []string) ([]string, error) {
+	var goroot, out string
+	var err error
+
+	if goroot, err = g.withGo().Execute("env", "GOROOT"); err != nil {
+		return nil, err
+	}
+
+	args := append(append([]string{"list"}, g.tagsFlags()...), "-f",
+		`{{$d := .Dir}}{{range .GoFiles}}{{$d}}/{{.}}{{"\n"}}{{end}}{{range .CgoFiles}}{{$d}}/{{.}}{{"\n"}}{{end}}`+
+			`{{range .TestGoFiles}}{{$d}}/{{.}}{{"\n"}}{{end}}{{range .XTestGoFiles}}{{$d}}/{{.}}{{"\n"}}{{end}}`)
+
+	if out, err = g.withGo(g.installEnv()...).Execute(append(args, packages...)...); err != nil {
+		return nil, err
+	}
+
+	files := lines(out)
+
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	gofmt := g.command(CommandSpec{Name: filepath.Join(goroot, "bin", "gofmt"), WorkDir: g.WorkDir})
+
+	if out, err = gofmt.Execute(append([]string{"-l"}, files...)...); err != nil {
+		return nil, err
+	}
+
+	var unformatted []string
+
+	for _, file := range lines(out) {
+		if rel, e := filepath.Rel(g.WorkDir, file); e == nil && !strings.HasPrefix(rel, "..") {
+			file = rel
+		}
+		unformatted = append(unformatted, file)
+	}
+
+	return unformatted, nil
+}
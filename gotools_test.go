@@ -20,11 +20,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -65,7 +67,7 @@ func (suite *GoToolsTestSuite) SetupTest() {
 
 	os.Setenv("GOPATH", suite.goPath)
 
-	suite.goTools = &GoTools{WorkDir: suite.goPath + "/src/project", Verbose: true}
+	suite.goTools = &GoTools{WorkDir: suite.goPath + "/src/project"}
 
 	if err = os.MkdirAll(suite.goTools.WorkDir, os.ModePerm); err != nil {
 		suite.Fail("failed to create project source directory before test setup", err.Error())
@@ -75,13 +77,17 @@ func (suite *GoToolsTestSuite) SetupTest() {
 		suite.Fail("failed to create 'main.go'", err.Error())
 	}
 
+	if err = ioutil.WriteFile(suite.goTools.WorkDir+"/go.mod", []byte("module project\n\ngo 1.16\n"), 0600); err != nil {
+		suite.Fail("failed to create 'go.mod'", err.Error())
+	}
+
 	git := NewNamedCommand("git", suite.goTools.WorkDir)
 
 	git.Execute("init")
 	git.Execute("config", "commit.gpgsign", "false")
 
-	if _, err = git.Execute("add", "main.go"); err != nil {
-		suite.Fail("failed to add 'main.go' before test setup", err.Error())
+	if _, err = git.Execute("add", "main.go", "go.mod"); err != nil {
+		suite.Fail("failed to add 'main.go' and 'go.mod' before test setup", err.Error())
 	}
 
 	if _, err = git.Execute("commit", "-n", "-m", "'Initial commit'"); err != nil {
@@ -144,7 +150,7 @@ func (suite *GoToolsTestSuite) TestGoTools_Install_Success() {
 		suite.Fail("failed to execute output binary", err.Error())
 	}
 
-	if goVersion, err = suite.goTools.withGo().Execute("version"); err != nil {
+	if goVersion, err = suite.goTools.Version(); err != nil {
 		suite.Fail("failed to get go version", err.Error())
 	}
 
@@ -169,3 +175,331 @@ func (suite *GoToolsTestSuite) TestGoTools_Install_Failure() {
 	assert.NotNil(suite.T(), suite.goTools.Install(commit, "1.0", "main"))
 	suite.goTools.WorkDir = dir
 }
+
+func (suite *GoToolsTestSuite) TestGoTools_Install_SuccessTags() {
+	var err error
+	var out string
+
+	tagged := `//go:build tagged
+
+package main
+
+func init() {
+	ReleaseVersion = "tagged-" + ReleaseVersion
+}
+`
+
+	if err = ioutil.WriteFile(suite.goTools.WorkDir+"/tagged.go", []byte(tagged), 0600); err != nil {
+		suite.Fail("failed to create 'tagged.go'", err.Error())
+	}
+
+	suite.goTools.Options = BuildOptions{Tags: []string{"tagged"}, LDFlags: "-s -w"}
+
+	if err = suite.goTools.Install("commit", "1.0", "main"); err != nil {
+		suite.Fail("failed to install binary", err.Error())
+	}
+
+	commandName := strings.Replace(suite.goTools.WorkDir, "/src/project", "/bin/project", 1)
+
+	if out, err = NewNamedCommand(commandName, suite.goTools.WorkDir).Execute(); err != nil {
+		suite.Fail("failed to execute output binary", err.Error())
+	}
+
+	assert.True(suite.T(), strings.HasPrefix(out, "Release: tagged-1.0"))
+}
+
+func (suite *GoToolsTestSuite) TestGoTools_Install_SuccessSuffix() {
+	var err error
+	var out string
+
+	variant := `package main
+
+var BuildVariant string
+
+func init() {
+	ReleaseVersion = ReleaseVersion + "-" + BuildVariant
+}
+`
+
+	if err = ioutil.WriteFile(suite.goTools.WorkDir+"/variant.go", []byte(variant), 0600); err != nil {
+		suite.Fail("failed to create 'variant.go'", err.Error())
+	}
+
+	suite.goTools.Suffix = "-debug"
+	suite.goTools.Variables = map[string]string{"BuildVariant": "debug"}
+
+	if err = suite.goTools.Install("commit", "1.0", "main"); err != nil {
+		suite.Fail("failed to install binary", err.Error())
+	}
+
+	commandName := strings.Replace(suite.goTools.WorkDir, "/src/project", "/bin/project-debug", 1)
+
+	if out, err = NewNamedCommand(commandName, suite.goTools.WorkDir).Execute(); err != nil {
+		suite.Fail("failed to execute output binary", err.Error())
+	}
+
+	assert.True(suite.T(), strings.HasPrefix(out, "Release: 1.0-debug"))
+
+	_, err = os.Stat(strings.Replace(suite.goTools.WorkDir, "/src/project", "/bin/project", 1))
+	assert.True(suite.T(), os.IsNotExist(err))
+}
+
+func TestGoTools_InstallArgs(t *testing.T) {
+	g := &GoTools{Options: BuildOptions{
+		Tags:      []string{"netgo", "osusergo"},
+		LDFlags:   "-s -w",
+		GCFlags:   "all=-N -l",
+		ASMFlags:  "-trimpath",
+		Race:      option(true),
+		BuildMode: "pie",
+	}}
+
+	assert.Equal(t, []string{"install",
+		"-tags", "netgo,osusergo",
+		"-gcflags", "all=-N -l",
+		"-asmflags", "-trimpath",
+		"-race",
+		"-buildmode", "pie",
+		"-ldflags", "-s -w -X main.GitCommit=abc"}, g.installArgs("", []string{"-X", "main.GitCommit=abc"}))
+
+	assert.Equal(t, []string{"install", "-ldflags", "-X main.GitCommit=abc"},
+		(&GoTools{}).installArgs("", []string{"-X", "main.GitCommit=abc"}))
+
+	assert.Equal(t, []string{"build", "-o", "/bin/project-cover", "-cover", "-ldflags", "-X main.GitCommit=abc"},
+		(&GoTools{Options: BuildOptions{Cover: option(true)}}).installArgs("/bin/project-cover", []string{"-X", "main.GitCommit=abc"}))
+}
+
+func TestGoTools_Variables(t *testing.T) {
+	g := &GoTools{Variables: map[string]string{"BuildVariant": "debug", "Another": "value"}}
+	assert.Equal(t, []string{
+		"-X", "\"pkg.GitCommit=abc\"",
+		"-X", "\"pkg.Another=value\"",
+		"-X", "\"pkg.BuildVariant=debug\"",
+	}, g.variables("pkg", [][2]string{{"GitCommit", "abc"}}))
+	assert.Empty(t, (&GoTools{}).variables("pkg", nil))
+
+	g = &GoTools{Mappings: map[string]string{
+		"GitCommit":      "Commit",
+		"ReleaseVersion": "example.com/project/version.Release",
+		"GoVersion":      "",
+	}}
+	assert.Equal(t, []string{
+		"-X", "\"pkg.Commit=abc\"",
+		"-X", "\"example.com/project/version.Release=1.0\"",
+		"-X", "\"pkg.BuildTimestamp=now\"",
+	}, g.variables("pkg", [][2]string{{"GitCommit", "abc"}, {"ReleaseVersion", "1.0"}, {"GoVersion", "go"}, {"BuildTimestamp", "now"}}))
+}
+
+func (suite *GoToolsTestSuite) writeCommand(name string) {
+	dir := filepath.Join(suite.goTools.WorkDir, "cmd", name)
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		suite.Fail("failed to create command directory", err.Error())
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(strings.Replace(content, "Release:", name+":", 1)), 0600); err != nil {
+		suite.Fail("failed to create command 'main.go'", err.Error())
+	}
+}
+
+func (suite *GoToolsTestSuite) TestGoTools_MainPackages_Success() {
+	suite.writeCommand("api")
+	suite.writeCommand("worker")
+
+	if err := os.MkdirAll(filepath.Join(suite.goTools.WorkDir, "lib"), os.ModePerm); err != nil {
+		suite.Fail("failed to create library directory", err.Error())
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(suite.goTools.WorkDir, "lib", "lib.go"), []byte("package lib\n"), 0600); err != nil {
+		suite.Fail("failed to create 'lib.go'", err.Error())
+	}
+
+	packages, err := suite.goTools.MainPackages("./...")
+	assert.Nil(suite.T(), err)
+	require.Len(suite.T(), packages, 3)
+	assert.Equal(suite.T(), []string{"project", "project/cmd/api", "project/cmd/worker"},
+		[]string{packages[0].ImportPath, packages[1].ImportPath, packages[2].ImportPath})
+
+	packages, err = suite.goTools.MainPackages("./cmd/...")
+	assert.Nil(suite.T(), err)
+	require.Len(suite.T(), packages, 2)
+	assert.Equal(suite.T(), "api", packages[0].Name())
+	assert.True(suite.T(), Binary{Package: "./cmd/api"}.Matches(suite.goTools.WorkDir, packages[0]))
+}
+
+func (suite *GoToolsTestSuite) TestGoTools_MainPackages_Failure() {
+	_, err := suite.goTools.MainPackages("./nonexistent/...")
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *GoToolsTestSuite) TestGoTools_Install_SuccessPackageName() {
+	var err error
+	var out string
+
+	suite.writeCommand("api")
+
+	suite.goTools.Package = "project/cmd/api"
+	suite.goTools.Name = "api-server"
+	suite.goTools.Mappings = map[string]string{"ReleaseVersion": "GitCommit", "GitCommit": ""}
+
+	if err = suite.goTools.Install("commit", "1.0", "main"); err != nil {
+		suite.Fail("failed to install binary", err.Error())
+	}
+
+	commandName := strings.Replace(suite.goTools.WorkDir, "/src/project", "/bin/api-server", 1)
+
+	if out, err = NewNamedCommand(commandName, suite.goTools.WorkDir).Execute(); err != nil {
+		suite.Fail("failed to execute output binary", err.Error())
+	}
+
+	assert.True(suite.T(), strings.HasPrefix(out, "api: \nCommit: 1.0\n"))
+}
+
+func TestMainPackage_Name(t *testing.T) {
+	assert.Equal(t, "api", MainPackage{ImportPath: "example.com/project/cmd/api"}.Name())
+	assert.Equal(t, "project", MainPackage{ImportPath: "example.com/project/v2"}.Name())
+	assert.Equal(t, "v2", MainPackage{ImportPath: "v2"}.Name())
+}
+
+func TestBinary_Matches(t *testing.T) {
+	p := MainPackage{ImportPath: "example.com/project/cmd/api", Dir: "/work/cmd/api"}
+
+	assert.True(t, Binary{Package: "example.com/project/cmd/api"}.Matches("/work", p))
+	assert.True(t, Binary{Package: "./cmd/api"}.Matches("/work", p))
+	assert.True(t, Binary{Package: "/work/cmd/api/"}.Matches("/other", p))
+	assert.False(t, Binary{Package: "./cmd/worker"}.Matches("/work", p))
+}
+
+func TestGoTools_InstallEnv(t *testing.T) {
+	g := &GoTools{Options: BuildOptions{GoFlags: "-trimpath"}, Env: BuildTarget{OS: "linux", Arch: "arm64"}.Env()}
+	assert.Equal(t, []string{"GOOS=linux", "GOARCH=arm64", "GOFLAGS=-trimpath"}, g.installEnv())
+	assert.Empty(t, (&GoTools{}).installEnv())
+}
+
+func TestBuildOptions_Merge(t *testing.T) {
+	base := BuildOptions{Tags: []string{"netgo"}, LDFlags: "-s -w", BuildMode: "pie"}
+	merged := base.Merge(BuildOptions{Tags: []string{"debug"}, GCFlags: "all=-N -l", Race: option(true), Cover: option(true)})
+
+	assert.Equal(t, BuildOptions{
+		Tags:      []string{"debug"},
+		LDFlags:   "-s -w",
+		GCFlags:   "all=-N -l",
+		Race:      option(true),
+		Cover:     option(true),
+		BuildMode: "pie",
+	}, merged)
+	assert.Equal(t, base, base.Merge(BuildOptions{}))
+
+	// a target or a variant may disable what is enabled at a higher level.
+	disabled := merged.Merge(BuildOptions{Race: option(false)})
+	assert.False(t, enabled(disabled.Race))
+	assert.True(t, enabled(disabled.Cover))
+	assert.NotContains(t, (&GoTools{Options: disabled}).buildFlags(), "-race")
+}
+
+func option(value bool) *bool {
+	return &value
+}
+
+func TestBuildTarget_String(t *testing.T) {
+	assert.Equal(t, "windows/386", BuildTarget{OS: "windows", Arch: "386"}.String())
+}
+
+func (suite *GoToolsTestSuite) TestGoTools_Version_Success() {
+	var expected string
+	var err error
+
+	if expected, err = NewNamedCommand("go", suite.goTools.WorkDir).Execute("env", "GOVERSION"); err != nil {
+		suite.Fail("failed to get go version", err.Error())
+	}
+
+	version, err := suite.goTools.Version()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), expected, version)
+
+	suite.goTools.Toolchain = Toolchain{Go: runtime.GOROOT() + "/bin/go"}
+	version, err = suite.goTools.Version()
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), runtime.Version(), version)
+}
+
+func (suite *GoToolsTestSuite) TestGoTools_Version_Failure() {
+	suite.goTools.Toolchain = Toolchain{Go: "/some-nonexistent-path/go"}
+	_, err := suite.goTools.Version()
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *GoToolsTestSuite) TestGoTools_CheckModule() {
+	var err error
+
+	suite.goTools.Toolchain = Toolchain{Go: runtime.GOROOT() + "/bin/go"}
+	assert.Nil(suite.T(), suite.goTools.CheckModule())
+
+	if err = ioutil.WriteFile(suite.goTools.WorkDir+"/go.mod", []byte("module project\n\ngo 1.999\n"), 0600); err != nil {
+		suite.Fail("failed to write 'go.mod'", err.Error())
+	}
+
+	assert.NotNil(suite.T(), suite.goTools.CheckModule())
+}
+
+func TestGoTools_CheckModule_Workspace(t *testing.T) {
+	dir := t.TempDir()
+	mod, work := filepath.Join(dir, "go.mod"), filepath.Join(dir, "go.work")
+
+	if err := ioutil.WriteFile(mod, []byte("module project\n\ngo 1.21\n"), 0600); err != nil {
+		t.Fatal(err)
+	} else if err = ioutil.WriteFile(work, []byte("go 1.23\n\nuse ./cmd/api\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := fmt.Sprintf(`{"GOMOD": %q, "GOWORK": %q}`, mod, work)
+	fake := NewFakeCommander(
+		CommandCall{Name: "go", Args: []string{"env", "-json", "GOMOD", "GOWORK"}, Output: env},
+		CommandCall{Name: "go", Args: []string{"env", "GOVERSION"}, Output: "go1.22.1 X:loopvar"},
+	)
+
+	// the working directory is a subdirectory of the module.
+	err := (&GoTools{WorkDir: filepath.Join(dir, "cmd", "api"), Commander: fake}).CheckModule()
+
+	assert.EqualError(t, err, fmt.Sprintf("'%v' requires 'go 1.23' but the toolchain version is 'go1.22.1 X:loopvar'", work))
+	assert.Empty(t, fake.Remaining())
+}
+
+func TestGoTools_Output_CrossTarget(t *testing.T) {
+	plan9, windows := BuildTarget{OS: "plan9", Arch: "arm"}.Env(), BuildTarget{OS: "windows", Arch: "mips"}.Env()
+	fake := NewFakeCommander(
+		CommandCall{Name: "go", Args: []string{"list", "-f", "{{.Target}}", "example.com/project/cmd/api"}, Env: plan9, Output: "/go/bin/plan9_arm/api"},
+		CommandCall{Name: "go", Args: []string{"list", "-f", "{{.Target}}", "example.com/project/cmd/api"}, Env: plan9},
+		CommandCall{Name: "go", Args: []string{"env", "GOBIN"}, Env: plan9, Output: "/gobin"},
+		CommandCall{Name: "go", Args: []string{"list", "-f", "{{.ImportPath}}", "example.com/project/cmd/api"}, Env: plan9, Output: "example.com/project/cmd/api"},
+		CommandCall{Name: "go", Args: []string{"list", "-f", "{{.Target}}", "example.com/project/cmd/api"}, Env: windows},
+		CommandCall{Name: "go", Args: []string{"env", "GOBIN"}, Env: windows, Output: "/gobin"},
+		CommandCall{Name: "go", Args: []string{"list", "-f", "{{.ImportPath}}", "example.com/project/cmd/api"}, Env: windows, Output: "example.com/project/cmd/api"},
+	)
+
+	g := &GoTools{WorkDir: "/work", Commander: fake, Package: "example.com/project/cmd/api", Env: plan9}
+
+	// 'go install' would fail with 'GOBIN' set, so the cross-compiled binaries are always built with an output.
+	output, err := g.output()
+	assert.Nil(t, err)
+	assert.Equal(t, "/go/bin/plan9_arm/api", output)
+
+	output, err = g.output()
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join("/gobin", "plan9_arm", "api"), output)
+
+	g.Env = windows
+
+	output, err = g.output()
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join("/gobin", "windows_mips", "api.exe"), output)
+
+	assert.Empty(t, fake.Remaining())
+	assert.Empty(t, fake.Unexpected())
+
+	g.Env = BuildTarget{OS: runtime.GOOS, Arch: runtime.GOARCH}.Env()
+
+	output, err = g.output()
+	assert.Nil(t, err)
+	assert.Empty(t, output)
+}
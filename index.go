@@ -0,0 +1,693 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The git file modes.
+const (
+	regularMode    = 0100644
+	executableMode = 0100755
+	symlinkMode    = 0120000
+	gitlinkMode    = 0160000
+	treeMode       = 040000
+)
+
+// indexEntry is an entry of the git index.
+type indexEntry struct {
+	path         string
+	mode         uint32
+	hash         string
+	size         uint32
+	mtime        time.Time
+	stage        int
+	skipWorktree bool
+}
+
+// gitIndex is the git index along with its modification time, needed to detect the racily clean entries.
+type gitIndex struct {
+	entries []indexEntry
+	mtime   time.Time
+}
+
+// readIndex reads the version 2, 3 or 4 git index of the repository, a missing index is empty.
+func (r *nativeRepository) readIndex() (gitIndex, error) {
+	var index gitIndex
+
+	file := filepath.Join(r.gitDir, "index")
+	info, err := os.Stat(file)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return index, nil
+		}
+		return index, err
+	}
+
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return index, err
+	}
+
+	index.mtime = info.ModTime()
+	index.entries, err = parseIndex(content)
+
+	return index, err
+}
+
+// parseIndex parses the entries of a version 2, 3 or 4 git index.
+func parseIndex(content []byte) ([]indexEntry, error) {
+	corrupted := fmt.Errorf("corrupted git index")
+
+	if len(content) < 12+20 || string(content[:4]) != "DIRC" {
+		return nil, corrupted
+	}
+
+	version := binary.BigEndian.Uint32(content[4:8])
+	count := int(binary.BigEndian.Uint32(content[8:12]))
+
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported git index version %v", version)
+	}
+
+	var entries []indexEntry
+	var previous string
+
+	data := content[12 : len(content)-20]
+
+	for i := 0; i < count; i++ {
+		if len(data) < 62 {
+			return nil, corrupted
+		}
+
+		e := indexEntry{
+			mtime: time.Unix(int64(binary.BigEndian.Uint32(data[8:12])), int64(binary.BigEndian.Uint32(data[12:16]))),
+			mode:  binary.BigEndian.Uint32(data[24:28]),
+			size:  binary.BigEndian.Uint32(data[36:40]),
+			hash:  hex.EncodeToString(data[40:60]),
+		}
+
+		flags := binary.BigEndian.Uint16(data[60:62])
+		e.stage = int(flags>>12) & 3
+		start := 62
+
+		if flags&0x4000 != 0 {
+			if version < 3 || len(data) < 64 {
+				return nil, corrupted
+			}
+
+			extended := binary.BigEndian.Uint16(data[62:64])
+			e.skipWorktree = extended&0x4000 != 0
+			start = 64
+		}
+
+		if version == 4 {
+			strip, n := indexVarint(data[start:])
+
+			if n == 0 || strip > len(previous) {
+				return nil, corrupted
+			}
+
+			nul := bytes.IndexByte(data[start+n:], 0)
+
+			if nul < 0 {
+				return nil, corrupted
+			}
+
+			e.path = previous[:len(previous)-strip] + string(data[start+n:start+n+nul])
+			data = data[start+n+nul+1:]
+		} else {
+			nul := bytes.IndexByte(data[start:], 0)
+
+			if nul < 0 {
+				return nil, corrupted
+			}
+
+			e.path = string(data[start : start+nul])
+
+			// the entries are padded with 1 to 8 NUL bytes to a multiple of 8 bytes.
+			size := (start + nul + 8) &^ 7
+
+			if size > len(data) {
+				return nil, corrupted
+			}
+
+			data = data[size:]
+		}
+
+		previous = e.path
+		entries = append(entries, e)
+	}
+
+	// the split index stores the entries in a shared index that is not read.
+	for len(data) >= 8 {
+		if string(data[:4]) == "link" {
+			return nil, fmt.Errorf("split git index is not supported")
+		}
+
+		size := int(binary.BigEndian.Uint32(data[4:8]))
+
+		if 8+size > len(data) {
+			break
+		}
+
+		data = data[8+size:]
+	}
+
+	return entries, nil
+}
+
+// indexVarint decodes the offset encoded integer of the version 4 index, it returns the number of read bytes,
+// or 0 if the data is truncated.
+func indexVarint(data []byte) (int, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+
+	b := data[0]
+	value := int(b & 0x7f)
+	n := 1
+
+	for b&0x80 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+
+		b = data[n]
+		n++
+		value = ((value + 1) << 7) | int(b&0x7f)
+	}
+
+	return value, n
+}
+
+// treeEntry is a file of a tree object.
+type treeEntry struct {
+	mode uint32
+	hash string
+}
+
+// readTree returns the entries of the specified tree object mapped by their names.
+func (r *nativeRepository) readTree(hash string) (map[string]treeEntry, error) {
+	obj, err := r.object(hash, treeObject)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]treeEntry)
+	data := obj.data
+
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+
+		if space < 0 || nul < space || len(data) < nul+21 {
+			return nil, fmt.Errorf("corrupted tree '%v'", hash)
+		}
+
+		var mode uint32
+
+		if _, err = fmt.Sscanf(string(data[:space]), "%o", &mode); err != nil {
+			return nil, fmt.Errorf("corrupted tree '%v'", hash)
+		}
+
+		entries[string(data[space+1:nul])] = treeEntry{mode: mode, hash: hex.EncodeToString(data[nul+1 : nul+21])}
+		data = data[nul+21:]
+	}
+
+	return entries, nil
+}
+
+// flattenTree returns the files of the specified tree recursively mapped by their paths.
+func (r *nativeRepository) flattenTree(hash, prefix string, files map[string]treeEntry) error {
+	entries, err := r.readTree(hash)
+
+	if err != nil {
+		return err
+	}
+
+	for name, entry := range entries {
+		if entry.mode == treeMode {
+			if err = r.flattenTree(entry.hash, prefix+name+"/", files); err != nil {
+				return err
+			}
+		} else {
+			files[prefix+name] = entry
+		}
+	}
+
+	return nil
+}
+
+// treePath returns the hash of the object at the specified slash separated path of the specified tree,
+// or an empty string if there is none.
+func (r *nativeRepository) treePath(tree, p string) (string, error) {
+	if p == "." {
+		return tree, nil
+	}
+
+	hash, mode := tree, uint32(treeMode)
+
+	for _, name := range strings.Split(p, "/") {
+		// a file has no entries under it.
+		if mode != treeMode {
+			return "", nil
+		}
+
+		entries, err := r.readTree(hash)
+
+		if err != nil {
+			return "", err
+		}
+
+		entry, found := entries[name]
+
+		if !found {
+			return "", nil
+		}
+
+		hash, mode = entry.hash, entry.mode
+	}
+
+	return hash, nil
+}
+
+// gitignoreRule is a pattern of a '.gitignore' file.
+type gitignoreRule struct {
+	dir     string
+	pattern *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// gitignore matches the paths against the rules of the '.gitignore' files and the 'info/exclude' file,
+// the last matching rule wins.
+type gitignore []gitignoreRule
+
+// withFile returns these rules along with the ones of the specified ignore file, whose patterns are relative to the
+// specified directory, a missing file adds no rules.
+func (ignore gitignore) withFile(file, dir string) (gitignore, error) {
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ignore, nil
+		}
+		return nil, err
+	}
+
+	rules := append(gitignore{}, ignore...)
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, " \r")
+
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := gitignoreRule{dir: dir}
+
+		if strings.HasPrefix(line, "!") {
+			rule.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimSuffix(line, "/")
+		}
+
+		if len(line) == 0 {
+			continue
+		}
+
+		if rule.pattern, err = regexp.Compile(globToRegexp(line)); err != nil {
+			continue
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// ignored returns true if the specified slash separated path relative to the repository root is ignored.
+func (ignore gitignore) ignored(p string, isDir bool) bool {
+	result := false
+
+	for _, rule := range ignore {
+		rel := p
+
+		if len(rule.dir) > 0 {
+			if !strings.HasPrefix(p, rule.dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(p, rule.dir+"/")
+		}
+
+		if (!rule.dirOnly || isDir) && rule.pattern.MatchString(rel) {
+			result = !rule.negate
+		}
+	}
+
+	return result
+}
+
+// worktreeHash returns the mode and the blob hash of the specified working tree file as it would be added to the
+// index, a directory is a submodule whose hash is not computed.
+func worktreeHash(file string, info os.FileInfo) (uint32, string, error) {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(file)
+		return symlinkMode, hashObject(blobObject, []byte(filepath.ToSlash(target))), err
+	case info.IsDir():
+		return gitlinkMode, "", nil
+	}
+
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return 0, "", err
+	} else if info.Mode()&0111 != 0 {
+		return executableMode, hashObject(blobObject, content), nil
+	}
+
+	return regularMode, hashObject(blobObject, content), nil
+}
+
+// worktreeMode returns the git mode of the specified working tree file.
+func worktreeMode(info os.FileInfo) uint32 {
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return symlinkMode
+	case info.IsDir():
+		return gitlinkMode
+	case info.Mode()&0111 != 0:
+		return executableMode
+	}
+	return regularMode
+}
+
+// unstaged returns the status code of the working tree file of the specified index entry, 'M' if it's modified,
+// 'D' if it's deleted or '.' if unchanged.
+func (r *nativeRepository) unstaged(e indexEntry, indexTime time.Time) (byte, error) {
+	file := filepath.Join(r.top, filepath.FromSlash(e.path))
+	info, err := os.Lstat(file)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 'D', nil
+		}
+		return 0, err
+	}
+
+	mode := worktreeMode(info)
+
+	if e.mode == gitlinkMode {
+		if mode != gitlinkMode {
+			return 'D', nil
+		}
+
+		// an uninitialized submodule is an empty directory, which is not a change.
+		sub := &NativeGit{WorkDir: file}
+		head, err := sub.HeadCommit()
+
+		if err != nil || head == e.hash {
+			return '.', nil
+		}
+
+		return 'M', nil
+	}
+
+	if mode == gitlinkMode {
+		return 'D', nil
+	} else if mode != e.mode {
+		return 'M', nil
+	} else if uint32(info.Size()) != e.size {
+		return 'M', nil
+	} else if info.ModTime().Equal(e.mtime) && info.ModTime().Before(indexTime) {
+		// like git, an entry not modified since it has been indexed is clean unless it may have been modified
+		// within the same time slot the index has been written in.
+		return '.', nil
+	}
+
+	var hash string
+
+	if _, hash, err = worktreeHash(file, info); err != nil {
+		return 0, err
+	} else if hash != e.hash {
+		return 'M', nil
+	}
+
+	return '.', nil
+}
+
+// conflictCodes returns the status codes of an unmerged path from the stages it has entries in, as 'git status' does.
+func conflictCodes(stages map[int]bool) (byte, byte) {
+	switch {
+	case stages[1] && stages[2] && stages[3]:
+		return 'U', 'U'
+	case stages[2] && stages[3]:
+		return 'A', 'A'
+	case stages[1] && stages[2]:
+		return 'U', 'D'
+	case stages[1] && stages[3]:
+		return 'D', 'U'
+	case stages[2]:
+		return 'A', 'U'
+	case stages[3]:
+		return 'U', 'A'
+	}
+	return 'D', 'D'
+}
+
+// untracked walks the working tree and returns the paths of the files that are neither tracked nor ignored,
+// a nested repository is reported as a directory.
+func (r *nativeRepository) untracked(tracked map[string]bool) ([]string, error) {
+	var files []string
+
+	ignore, err := gitignore{}.withFile(filepath.Join(r.commonDir, "info", "exclude"), "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var walk func(dir string, ignore gitignore) error
+
+	walk = func(dir string, ignore gitignore) error {
+		abs := filepath.Join(r.top, filepath.FromSlash(dir))
+
+		if ignore, err = ignore.withFile(filepath.Join(abs, ".gitignore"), dir); err != nil {
+			return err
+		}
+
+		entries, err := ioutil.ReadDir(abs)
+
+		if err != nil {
+			return err
+		}
+
+		for _, info := range entries {
+			p := path.Join(dir, info.Name())
+
+			if info.Name() == ".git" || tracked[p] {
+				continue
+			}
+
+			isDir := info.IsDir()
+
+			if ignore.ignored(p, isDir) {
+				continue
+			} else if !isDir {
+				files = append(files, p)
+				continue
+			}
+
+			if _, e := os.Lstat(filepath.Join(abs, info.Name(), ".git")); e == nil {
+				files = append(files, p+"/")
+			} else if err = walk(p, ignore); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return files, walk("", ignore)
+}
+
+// pathspecs converts the specified paths relative to the working directory to slash separated paths relative to the
+// repository root.
+func (g *NativeGit) pathspecs(r *nativeRepository, paths []string) ([]string, error) {
+	var specs []string
+
+	workDir, err := filepath.Abs(g.WorkDir)
+
+	if err != nil {
+		return nil, err
+	} else if workDir, err = filepath.EvalSymlinks(workDir); err != nil {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		rel, err := filepath.Rel(r.top, filepath.Join(workDir, p))
+
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return nil, fmt.Errorf("'%v' is outside the repository at '%v'", p, r.top)
+		}
+
+		specs = append(specs, filepath.ToSlash(rel))
+	}
+
+	return specs, nil
+}
+
+// matchPathspecs returns true if the specified path is under any of the specified pathspecs, or if there are none.
+func matchPathspecs(p string, specs []string) bool {
+	for _, s := range specs {
+		if s == "." || p == s || strings.HasPrefix(p, s+"/") || strings.HasPrefix(s, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return len(specs) == 0
+}
+
+// Status returns the changed paths of the working tree compared to the index and the index compared to the checked
+// out commit, along with the untracked files, if paths are specified only the changes under them are returned.
+// The paths are relative to the repository root and sorted as 'git status' does, the tracked ones first.
+func (g *NativeGit) Status(paths ...string) (Status, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	specs, err := g.pathspecs(r, paths)
+
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := r.readIndex()
+
+	if err != nil {
+		return nil, err
+	}
+
+	head := make(map[string]treeEntry)
+
+	if commit, _, found, err := r.resolve("HEAD^{commit}"); err != nil {
+		return nil, err
+	} else if found {
+		var c nativeCommit
+
+		if c, err = r.commit(commit); err != nil {
+			return nil, err
+		} else if err = r.flattenTree(c.tree, "", head); err != nil {
+			return nil, err
+		}
+	}
+
+	tracked := make(map[string]bool)
+	conflicts := make(map[string]map[int]bool)
+	changes := make(map[string]StatusEntry)
+
+	for _, e := range index.entries {
+		tracked[e.path] = true
+
+		if e.stage > 0 {
+			if conflicts[e.path] == nil {
+				conflicts[e.path] = make(map[int]bool)
+			}
+			conflicts[e.path][e.stage] = true
+			continue
+		}
+
+		entry := StatusEntry{Path: e.path, Staged: '.', Unstaged: '.', Submodule: e.mode == gitlinkMode}
+
+		if h, found := head[e.path]; !found {
+			entry.Staged = 'A'
+		} else if h.hash != e.hash || h.mode != e.mode {
+			entry.Staged = 'M'
+		}
+
+		if !e.skipWorktree {
+			if entry.Unstaged, err = r.unstaged(e, index.mtime); err != nil {
+				return nil, err
+			}
+		}
+
+		entry.SubmoduleCommit = entry.Submodule && entry.Unstaged == 'M'
+
+		if entry.Staged != '.' || entry.Unstaged != '.' {
+			changes[e.path] = entry
+		}
+	}
+
+	for p, stages := range conflicts {
+		entry := StatusEntry{Path: p, Conflicted: true}
+		entry.Staged, entry.Unstaged = conflictCodes(stages)
+		changes[p] = entry
+	}
+
+	for p, h := range head {
+		if !tracked[p] {
+			changes[p] = StatusEntry{Path: p, Staged: 'D', Unstaged: '.', Submodule: h.mode == gitlinkMode}
+		}
+	}
+
+	var status Status
+
+	for p, entry := range changes {
+		if matchPathspecs(p, specs) {
+			status = append(status, entry)
+		}
+	}
+
+	sort.Slice(status, func(i, j int) bool { return status[i].Path < status[j].Path })
+
+	untracked, err := r.untracked(tracked)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(untracked)
+
+	for _, p := range untracked {
+		if matchPathspecs(p, specs) {
+			status = append(status, StatusEntry{Path: p, Untracked: true})
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,416 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// defaultVersionFile is the name of the Go file 'init' declares the release variables in.
+const defaultVersionFile = "version.go"
+
+// The tag patterns of the starter policy, with and without the 'v' prefix.
+const (
+	prefixedTagPattern = `^v\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`
+	plainTagPattern    = `^\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`
+)
+
+// versionHelpers are the names of the helpers declared along with the release variables.
+var versionHelpers = []string{"VersionString", "VersionFlag", "versionFlag"}
+
+// versionTemplate is the Go file declaring the release variables and the '-version' flag helper.
+var versionTemplate = template.Must(template.New(defaultVersionFile).Parse(`package {{.}}
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// The release information is injected by 'rego' while building a release, it is empty in the other builds.
+var (
+	// GitCommit is the hash of the commit the release has been built from.
+	GitCommit string
+	// BuildTimestamp is the time the release has been built at, formatted in RFC3339.
+	BuildTimestamp string
+	// ReleaseVersion is the version of the release.
+	ReleaseVersion string
+	// GoVersion is the output of 'go version' of the toolchain the release has been built with.
+	GoVersion string
+)
+
+// VersionString returns the release information, one item per line.
+func VersionString() string {
+	return fmt.Sprintf("Release: %v\nCommit: %v\nBuild Time: %v\nBuilt with: %v", ReleaseVersion, GitCommit, BuildTimestamp, GoVersion)
+}
+
+// VersionFlag defines the '-version' flag in the specified flag set, which prints the release information and exits
+// while the flags are parsed, e.g. 'VersionFlag(flag.CommandLine)' before 'flag.Parse()'.
+func VersionFlag(fs *flag.FlagSet) {
+	fs.Var(versionFlag{}, "version", "Prints the version and exits")
+}
+
+// versionFlag is the boolean '-version' flag.
+type versionFlag struct{}
+
+func (versionFlag) String() string { return "false" }
+
+func (versionFlag) IsBoolFlag() bool { return true }
+
+func (versionFlag) Set(value string) error {
+	if set, err := strconv.ParseBool(value); err != nil || !set {
+		return err
+	}
+
+	fmt.Println(VersionString())
+	os.Exit(0)
+
+	return nil
+}
+`))
+
+// starterConfigurations is the structure of the configuration file written by 'init'.
+type starterConfigurations struct {
+	Main    string        `json:"main,omitempty"`
+	GitFlow bool          `json:"gitflow,omitempty"`
+	Policy  starterPolicy `json:"policy"`
+}
+
+// starterPolicy is the release policy of the configuration file written by 'init'.
+type starterPolicy struct {
+	AllowedBranches    []string `json:"allowedBranches"`
+	ReleaseBranches    []string `json:"releaseBranches"`
+	PrereleaseBranches []string `json:"prereleaseBranches,omitempty"`
+	TagPattern         string   `json:"tagPattern"`
+	IncreasingVersions bool     `json:"increasingVersions"`
+}
+
+// scaffoldFile is a file written by 'init'.
+type scaffoldFile struct {
+	path    string
+	content []byte
+}
+
+// Scaffold sets up a project to be released, it declares the release variables in the target package and writes a
+// starter configuration file matching the branching model and the tags of the repository.
+type Scaffold struct {
+	// Git runs the git commands in the working directory.
+	Git *Git
+	// GoTools runs the Go commands in the working directory.
+	GoTools *GoTools
+	// Package is the package to declare the release variables in, 'main' for every main package.
+	Package string
+	// MainPattern is the pattern matching the main packages, e.g. './...'.
+	MainPattern string
+	// File is the name of the Go file declaring the release variables, 'version.go' is used if empty.
+	File string
+	// ConfigFile is the path of the configuration file to write.
+	ConfigFile string
+}
+
+// Run writes the files of the scaffold and returns their paths, nothing is written if any of them exists or if any
+// of the declarations is already found in the target packages.
+func (s *Scaffold) Run() ([]string, error) {
+	file := s.File
+
+	if len(file) == 0 {
+		file = defaultVersionFile
+	}
+
+	if filepath.Base(file) != file || !strings.HasSuffix(file, ".go") || strings.HasSuffix(file, "_test.go") {
+		return nil, newError(UsageError, "invalid version file '%v', a non-test Go file name is expected (e.g. '%v')", file, defaultVersionFile)
+	}
+
+	if _, err := s.Git.TopLevel(); err != nil {
+		return nil, err
+	}
+
+	packages, err := s.GoTools.MainPackages(s.MainPattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var files []scaffoldFile
+	var conflicts []string
+
+	targets, err := s.targets(packages)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range sortedKeys(targets) {
+		if declared := conflictingDeclarations(targets[dir].declarations); len(declared) > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("'%v' already declares %v", dir, strings.Join(declared, ", ")))
+		}
+
+		var b bytes.Buffer
+
+		if err = versionTemplate.Execute(&b, targets[dir].name); err != nil {
+			return nil, err
+		}
+
+		content, err := format.Source(b.Bytes())
+
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, scaffoldFile{filepath.Join(dir, file), content})
+	}
+
+	config, err := s.config(packages)
+
+	if err != nil {
+		return nil, err
+	}
+
+	files = append(files, scaffoldFile{s.ConfigFile, config})
+
+	for _, f := range files {
+		if _, err = os.Stat(f.path); err == nil {
+			conflicts = append(conflicts, fmt.Sprintf("'%v' already exists", f.path))
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, newError(UsageError, "nothing is written, %v", strings.Join(conflicts, "; "))
+	}
+
+	var written []string
+
+	for _, f := range files {
+		if err = writeNewFile(f.path, f.content); err != nil {
+			return written, err
+		}
+
+		written = append(written, f.path)
+	}
+
+	return written, nil
+}
+
+// scaffoldTarget is a package to declare the release variables in.
+type scaffoldTarget struct {
+	name         string
+	declarations map[string]token.Token
+}
+
+// targets returns the packages to declare the release variables in by their directories.
+func (s *Scaffold) targets(packages []MainPackage) (map[string]scaffoldTarget, error) {
+	targets := make(map[string]scaffoldTarget)
+
+	if len(s.Package) == 0 || s.Package == "main" {
+		if len(packages) == 0 {
+			return nil, newError(UsageError, "no main packages match '%v', use '--main' to match them or '--package' to declare the variables in another package", s.MainPattern)
+		}
+
+		for _, p := range packages {
+			_, declarations, err := packageDeclarations(p.Dir)
+
+			if err != nil {
+				return nil, err
+			}
+
+			targets[p.Dir] = scaffoldTarget{"main", declarations}
+		}
+
+		return targets, nil
+	}
+
+	dir, err := s.GoTools.PackageDir(s.Package)
+
+	if err != nil {
+		// a package that does not exist yet is created within the main module.
+		module, moduleDir, e := s.GoTools.Module()
+
+		if e != nil {
+			return nil, e
+		} else if !strings.HasPrefix(s.Package, module+"/") {
+			return nil, newError(UsageError, "package '%v' is not found in module '%v', the import path of a package of the module is expected", s.Package, module)
+		}
+
+		dir = filepath.Join(moduleDir, filepath.FromSlash(strings.TrimPrefix(s.Package, module+"/")))
+		targets[dir] = scaffoldTarget{packageName(s.Package), nil}
+
+		return targets, nil
+	}
+
+	name, declarations, err := packageDeclarations(dir)
+
+	if err != nil {
+		return nil, err
+	} else if len(name) == 0 {
+		name = packageName(s.Package)
+	}
+
+	targets[dir] = scaffoldTarget{name, declarations}
+
+	return targets, nil
+}
+
+// config returns the content of the starter configuration file, the main packages pattern is narrowed to './cmd/...'
+// if all of them are there, the policy follows git-flow if the repository has a 'develop' branch and its tag pattern
+// follows the most common style of the existing tags, prefixed with 'v' by default.
+func (s *Scaffold) config(packages []MainPackage) ([]byte, error) {
+	var c starterConfigurations
+
+	if s.MainPattern != defaultMainPattern {
+		c.Main = s.MainPattern
+	} else if len(packages) > 0 {
+		c.Main = "./cmd/..."
+
+		for _, p := range packages {
+			if rel, err := filepath.Rel(filepath.Join(s.GoTools.WorkDir, "cmd"), p.Dir); err != nil || strings.HasPrefix(rel, "..") {
+				c.Main = ""
+			}
+		}
+	}
+
+	branch, _, err := s.Git.DefaultBranch()
+
+	if err != nil {
+		branch = "main"
+	}
+
+	branches, err := s.Git.Branches(true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range branches {
+		if b == gitFlowBranch || strings.HasSuffix(b, "/"+gitFlowBranch) {
+			c.GitFlow = true
+		}
+	}
+
+	c.Policy.IncreasingVersions = true
+	c.Policy.ReleaseBranches = []string{branch}
+	c.Policy.AllowedBranches = []string{branch}
+
+	if c.GitFlow {
+		c.Policy.AllowedBranches = []string{branch, gitFlowBranch, "release/*", "hotfix/*"}
+		c.Policy.PrereleaseBranches = []string{gitFlowBranch, "release/*"}
+	}
+
+	tags, err := s.Git.Tags()
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.Policy.TagPattern = tagPatternOf(tags)
+
+	content, err := json.MarshalIndent(c, "", "  ")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(content, '\n'), nil
+}
+
+// tagPatternOf returns the tag pattern matching the most common style of the specified tags, the module path prefix
+// of the tags is ignored.
+func tagPatternOf(tags []string) string {
+	var prefixed, plain int
+
+	for _, tag := range tags {
+		tag = path.Base(tag)
+
+		if _, err := ParseSemanticVersion(tag); err != nil {
+			continue
+		} else if strings.HasPrefix(tag, "v") {
+			prefixed++
+		} else {
+			plain++
+		}
+	}
+
+	if plain > prefixed {
+		return plainTagPattern
+	}
+
+	return prefixedTagPattern
+}
+
+// conflictingDeclarations returns the names of the release variables and the helpers found in the specified
+// declarations.
+func conflictingDeclarations(declarations map[string]token.Token) []string {
+	var names []string
+
+	for _, name := range append(append([]string{}, releaseVariables...), versionHelpers...) {
+		if _, found := declarations[name]; found {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// packageName returns the package name of a new package with the specified import path.
+func packageName(importPath string) string {
+	return strings.NewReplacer("-", "_", ".", "_").Replace(path.Base(importPath))
+}
+
+// writeNewFile writes the specified content to a new file, creating its directory if needed, it returns an error if
+// the file exists.
+func writeNewFile(name string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func sortedKeys(m map[string]scaffoldTarget) []string {
+	var keys []string
+
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scaffoldCalls(workDir string, mains ...string) []CommandCall {
+	return []CommandCall{
+		{Name: "git", Args: []string{"rev-parse", "--show-toplevel"}, Output: workDir},
+		{
+			Name:   "go",
+			Args:   []string{"list", "-f", "{{if eq .Name \"main\"}}{{.ImportPath}}\t{{.Dir}}{{end}}", "./..."},
+			Output: strings.Join(mains, "\n"),
+		},
+		{Name: "git", Args: []string{"symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD"}, Output: "origin/main"},
+		{Name: "git", Args: []string{"for-each-ref", "refs/heads/main", "--format='%(objectname)'"}, Output: "'abc'"},
+		{Name: "git", Args: []string{"for-each-ref", "--format=%(refname)", "refs/heads", "refs/remotes"}, Output: "refs/heads/main\nrefs/remotes/origin/develop"},
+		{Name: "git", Args: []string{"tag", "--list"}, Output: "v0.9.0\nv1.0.0\nrelease-1"},
+	}
+}
+
+func TestScaffold_Run(t *testing.T) {
+	dir := t.TempDir()
+	cmd := filepath.Join(dir, "cmd", "api")
+
+	if err := os.MkdirAll(cmd, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(cmd, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := NewFakeCommander(scaffoldCalls(dir, "example.com/project/cmd/api\t"+cmd)...)
+
+	s := &Scaffold{
+		Git:         &Git{WorkDir: dir, Commander: fake},
+		GoTools:     &GoTools{WorkDir: dir, Commander: fake},
+		Package:     "main",
+		MainPattern: defaultMainPattern,
+		ConfigFile:  filepath.Join(dir, defaultConfigFile),
+	}
+
+	written, err := s.Run()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(cmd, defaultVersionFile), filepath.Join(dir, defaultConfigFile)}, written)
+
+	assert.Empty(t, fake.Remaining())
+	assert.Empty(t, fake.Unexpected())
+
+	name, declarations, err := packageDeclarations(cmd)
+	assert.Nil(t, err)
+	assert.Equal(t, "main", name)
+
+	for _, v := range releaseVariables {
+		assert.Equal(t, token.VAR, declarations[v], v)
+	}
+
+	assert.Equal(t, token.FUNC, declarations["VersionFlag"])
+
+	fc, err := readConfigFile(filepath.Join(dir, defaultConfigFile), true)
+	assert.Nil(t, err)
+	assert.Equal(t, "./cmd/...", fc.Main)
+	assert.True(t, fc.GitFlow)
+	assert.Equal(t, Policy{
+		AllowedBranches:    []string{"main", "develop", "release/*", "hotfix/*"},
+		TagPattern:         prefixedTagPattern,
+		ReleaseBranches:    []string{"main"},
+		PrereleaseBranches: []string{"develop", "release/*"},
+		IncreasingVersions: true,
+	}, fc.Policy)
+
+	// nothing is overwritten, and nothing is written as long as anything conflicts.
+	if err = os.Remove(filepath.Join(dir, defaultConfigFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	fake = NewFakeCommander(scaffoldCalls(dir, "example.com/project/cmd/api\t"+cmd)...)
+	s.Git.Commander, s.GoTools.Commander = fake, fake
+
+	_, err = s.Run()
+
+	category, _ := Categorize(err)
+	assert.Equal(t, UsageError, category)
+	assert.Contains(t, err.Error(), "already declares GitCommit, BuildTimestamp, ReleaseVersion, GoVersion, VersionString, VersionFlag, versionFlag")
+	assert.Contains(t, err.Error(), "version.go' already exists")
+
+	_, err = os.Stat(filepath.Join(dir, defaultConfigFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestScaffold_Run_Package(t *testing.T) {
+	dir := t.TempDir()
+	calls := scaffoldCalls(dir)
+
+	fake := NewFakeCommander(append(append(calls[:2:2],
+		CommandCall{Name: "go", Args: []string{"list", "-f", "{{.Dir}}", "example.com/project/internal/build-info"}, ExitCode: 1, Stderr: "no required module provides package"},
+		CommandCall{Name: "go", Args: []string{"list", "-m", "-f", "{{.Path}}\t{{.Dir}}"}, Output: "example.com/project\t" + dir},
+	), calls[2:]...)...)
+
+	s := &Scaffold{
+		Git:         &Git{WorkDir: dir, Commander: fake},
+		GoTools:     &GoTools{WorkDir: dir, Commander: fake},
+		Package:     "example.com/project/internal/build-info",
+		MainPattern: defaultMainPattern,
+		File:        "release.go",
+		ConfigFile:  filepath.Join(dir, "release.json"),
+	}
+
+	written, err := s.Run()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "internal", "build-info", "release.go"), filepath.Join(dir, "release.json")}, written)
+
+	assert.Empty(t, fake.Remaining())
+	assert.Empty(t, fake.Unexpected())
+
+	name, _, err := packageDeclarations(filepath.Join(dir, "internal", "build-info"))
+	assert.Nil(t, err)
+	assert.Equal(t, "build_info", name)
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "release.json"))
+	assert.Nil(t, err)
+
+	var config map[string]interface{}
+	assert.Nil(t, json.Unmarshal(content, &config))
+	assert.NotContains(t, config, "main")
+
+	s.Package = "example.com/other/version"
+	fake = NewFakeCommander(append(calls[:2:2],
+		CommandCall{Name: "go", Args: []string{"list", "-f", "{{.Dir}}", "example.com/other/version"}, ExitCode: 1},
+		CommandCall{Name: "go", Args: []string{"list", "-m", "-f", "{{.Path}}\t{{.Dir}}"}, Output: "example.com/project\t" + dir},
+	)...)
+	s.Git.Commander, s.GoTools.Commander = fake, fake
+
+	_, err = s.Run()
+	category, _ := Categorize(err)
+	assert.Equal(t, UsageError, category)
+
+	s.File = "../version.go"
+	_, err = s.Run()
+	category, _ = Categorize(err)
+	assert.Equal(t, UsageError, category)
+}
+
+func TestTagPatternOf(t *testing.T) {
+	assert.Equal(t, prefixedTagPattern, tagPatternOf(nil))
+	assert.Equal(t, prefixedTagPattern, tagPatternOf([]string{"v1.0.0", "sub/v1.1.0", "2.0.0"}))
+	assert.Equal(t, plainTagPattern, tagPatternOf([]string{"1.0.0", "1.1.0-rc.1", "v2.0.0", "nightly"}))
+}
+
+func TestPackageName(t *testing.T) {
+	assert.Equal(t, "version", packageName("example.com/project/version"))
+	assert.Equal(t, "build_info", packageName("example.com/project/build-info"))
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	conventionalHeaderPattern = regexp.MustCompile(`^([a-zA-Z]+)(\([^()]+\))?!?: \S`)
+	signedOffPattern          = regexp.MustCompile(`(?m)^Signed-off-by: .+ <[^<>]+>\s*$`)
+)
+
+// CommitRules are the rules the messages of the released commits must comply with.
+type CommitRules struct {
+	// SignedOff requires a 'Signed-off-by' trailer (Developer Certificate of Origin).
+	SignedOff bool `json:"signedOff"`
+	// Conventional requires the message header to follow the Conventional Commits specification,
+	// e.g. 'feat(api)!: drop the v1 endpoints'.
+	Conventional bool `json:"conventional"`
+	// Types is the list of the allowed Conventional Commits types, any type is allowed if empty.
+	Types []string `json:"types"`
+	// TicketPattern is the regular expression of the ticket key the message must reference (e.g. '[A-Z]+-\d+').
+	TicketPattern string `json:"ticketPattern"`
+}
+
+// CommitViolation is a commit whose message does not comply with the commit rules.
+type CommitViolation struct {
+	// Commit is the non-compliant commit.
+	Commit CommitMessage
+	// Reasons is the list of the descriptions of the violated rules.
+	Reasons []string
+}
+
+// Enabled returns true if any of the rules is configured.
+func (r CommitRules) Enabled() bool {
+	return r.SignedOff || r.Conventional || len(r.TicketPattern) > 0
+}
+
+// Lint returns the violations of the specified commits, it returns an error if the ticket pattern is invalid.
+func (r CommitRules) Lint(commits []CommitMessage) ([]CommitViolation, error) {
+	var ticket *regexp.Regexp
+	var err error
+
+	if len(r.TicketPattern) > 0 {
+		if ticket, err = regexp.Compile(r.TicketPattern); err != nil {
+			return nil, fmt.Errorf("invalid ticket pattern '%v': %v", r.TicketPattern, err.Error())
+		}
+	}
+
+	// the types are matched regardless of their case.
+	types := make([]string, len(r.Types))
+
+	for i, t := range r.Types {
+		types[i] = strings.ToLower(t)
+	}
+
+	var violations []CommitViolation
+
+	for _, c := range commits {
+		var reasons []string
+
+		if r.SignedOff && !signedOffPattern.MatchString(c.Message) {
+			reasons = append(reasons, "missing 'Signed-off-by' trailer")
+		}
+
+		if r.Conventional {
+			if m := conventionalHeaderPattern.FindStringSubmatch(c.Subject()); m == nil {
+				reasons = append(reasons, "header does not follow Conventional Commits '<type>[(<scope>)][!]: <description>'")
+			} else if len(types) > 0 && !containsString(types, strings.ToLower(m[1])) {
+				reasons = append(reasons, fmt.Sprintf("type '%v' is not one of: %v", m[1], strings.Join(r.Types, ", ")))
+			}
+		}
+
+		if ticket != nil && !ticket.MatchString(c.Message) {
+			reasons = append(reasons, fmt.Sprintf("no ticket reference matching '%v'", r.TicketPattern))
+		}
+
+		if len(reasons) > 0 {
+			violations = append(violations, CommitViolation{Commit: c, Reasons: reasons})
+		}
+	}
+
+	return violations, nil
+}
+
+// LintReport returns the non-compliant commits, each followed by its reasons.
+func LintReport(violations []CommitViolation) string {
+	var report []string
+
+	for _, v := range violations {
+		hash := v.Commit.Hash
+
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+
+		report = append(report, fmt.Sprintf(" %v %v", hash, v.Commit.Subject()))
+
+		for _, reason := range v.Reasons {
+			report = append(report, "  "+reason)
+		}
+	}
+
+	return strings.Join(report, NewLine())
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var lintCommitsFixture = []CommitMessage{
+	{Hash: "1111111111111111", Message: "feat(api)!: drop the v1 endpoints\n\nRefs: PROJ-42\n\nSigned-off-by: Jane Doe <jane@example.com>"},
+	{Hash: "2222222222222222", Message: "Update README"},
+	{Hash: "3333333333333333", Message: "chore: bump dependencies PROJ-7\n\nSigned-off-by: Jane Doe"},
+	{Hash: "4444444444444444", Message: "fix: handle empty tags (PROJ-9)\n\nSigned-off-by: John Doe <john@example.com>"},
+}
+
+func TestCommitRules_Enabled(t *testing.T) {
+	assert.False(t, CommitRules{Types: []string{"feat"}}.Enabled())
+	assert.True(t, CommitRules{SignedOff: true}.Enabled())
+	assert.True(t, CommitRules{TicketPattern: `[A-Z]+-\d+`}.Enabled())
+}
+
+func TestCommitRules_Lint(t *testing.T) {
+	violations, err := CommitRules{
+		SignedOff:     true,
+		Conventional:  true,
+		Types:         []string{"feat", "fix"},
+		TicketPattern: `[A-Z]+-\d+`,
+	}.Lint(lintCommitsFixture)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []CommitViolation{
+		{Commit: lintCommitsFixture[1], Reasons: []string{
+			"missing 'Signed-off-by' trailer",
+			"header does not follow Conventional Commits '<type>[(<scope>)][!]: <description>'",
+			`no ticket reference matching '[A-Z]+-\d+'`,
+		}},
+		{Commit: lintCommitsFixture[2], Reasons: []string{
+			"missing 'Signed-off-by' trailer",
+			"type 'chore' is not one of: feat, fix",
+		}},
+	}, violations)
+}
+
+func TestCommitRules_Lint_AnyType(t *testing.T) {
+	violations, err := CommitRules{Conventional: true}.Lint(lintCommitsFixture)
+	assert.Nil(t, err)
+	assert.Len(t, violations, 1)
+	assert.Equal(t, "2222222222222222", violations[0].Commit.Hash)
+}
+
+func TestCommitRules_Lint_TypeCase(t *testing.T) {
+	violations, err := CommitRules{Conventional: true, Types: []string{"Feat", "FIX", "chore"}}.Lint([]CommitMessage{
+		{Hash: "4444", Message: "feat: add a flag"},
+		{Hash: "5555", Message: "Fix: handle an error"},
+		{Hash: "6666", Message: "docs: update README"},
+	})
+
+	assert.Nil(t, err)
+	assert.Len(t, violations, 1)
+	assert.Equal(t, "6666", violations[0].Commit.Hash)
+}
+
+func TestCommitRules_Lint_InvalidTicketPattern(t *testing.T) {
+	_, err := CommitRules{TicketPattern: "("}.Lint(lintCommitsFixture)
+	assert.NotNil(t, err)
+}
+
+func TestLintReport(t *testing.T) {
+	assert.Equal(t, " 222222222222 Update README"+NewLine()+
+		"  missing 'Signed-off-by' trailer"+NewLine()+
+		"  no ticket"+NewLine()+
+		" 3333 chore: bump dependencies PROJ-7"+NewLine()+
+		"  missing 'Signed-off-by' trailer",
+		LintReport([]CommitViolation{
+			{Commit: lintCommitsFixture[1], Reasons: []string{"missing 'Signed-off-by' trailer", "no ticket"}},
+			{Commit: CommitMessage{Hash: "3333", Message: lintCommitsFixture[2].Message}, Reasons: []string{"missing 'Signed-off-by' trailer"}},
+		}))
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// lockFileName is the name of the lock file in the git directory.
+const lockFileName = "rego.lock"
+
+// lockPollInterval is the interval of checking whether a held lock has been released while waiting for it.
+var lockPollInterval = 250 * time.Millisecond
+
+// unreadableLockAge is the age after which a lock file that can not be parsed is considered stale, a younger one may
+// still be being written by its holder.
+var unreadableLockAge = 10 * time.Second
+
+// LockHolder describes the run holding a repository lock.
+type LockHolder struct {
+	// PID is the process ID of the run.
+	PID int `json:"pid"`
+	// Host is the host name of the run.
+	Host string `json:"host"`
+	// Started is the time the lock has been acquired at.
+	Started time.Time `json:"started"`
+	// Command is the command of the run.
+	Command string `json:"command"`
+}
+
+// String describes the holder, e.g. 'pid 1234 on host "ci-1" since 2024-05-01T10:00:00Z'.
+func (h LockHolder) String() string {
+	if h.PID == 0 {
+		return "being acquired"
+	}
+	return fmt.Sprintf("pid %v on host '%v' since %v", h.PID, h.Host, h.Started.Format(time.RFC3339))
+}
+
+// stale returns true if the holder is a process of this host that is not running anymore, the processes of the other
+// hosts can not be checked so they are never stale.
+func (h LockHolder) stale(host string) bool {
+	return h.Host == host && !processAlive(h.PID)
+}
+
+// RepoLock is an advisory lock of a repository held by a run for its whole duration, so that two runs on the same
+// working directory do not interleave their checkouts and builds. It is a file in the git directory holding the
+// description of its holder, which is created exclusively and removed once released.
+type RepoLock struct {
+	path   string
+	holder LockHolder
+}
+
+// AcquireRepoLock acquires the lock of the repository of the specified git directory for the specified command, if
+// it is held by another run it waits for it to be released up to the specified duration, or until the specified
+// context is done. The locks of the runs that are not running anymore on this host are removed.
+func AcquireRepoLock(ctx context.Context, gitDir, command string, wait time.Duration) (*RepoLock, error) {
+	host, _ := os.Hostname()
+
+	l := &RepoLock{
+		path:   filepath.Join(gitDir, lockFileName),
+		holder: LockHolder{PID: os.Getpid(), Host: host, Started: time.Now().UTC().Truncate(time.Second), Command: command},
+	}
+
+	content, err := json.Marshal(l.holder)
+
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+
+	for {
+		var holder *LockHolder
+
+		if err = l.create(content); err == nil {
+			return l, nil
+		} else if !os.IsExist(err) {
+			return nil, err
+		} else if holder, err = l.readHolder(host); err != nil {
+			return nil, err
+		} else if holder == nil {
+			// the lock has been released or it was stale, so it's tried again right away.
+			continue
+		}
+
+		if !time.Now().Before(deadline) {
+			message := "the repository is locked by another rego run (%v), wait for it to finish or use '--wait'"
+
+			if wait > 0 {
+				message = "the repository is still locked by another rego run (%v) after waiting for " + wait.String()
+			}
+
+			return nil, withCategory(LockedError, fmt.Errorf(message, holder), "pid", holder.PID, "host", holder.Host, "started", holder.Started)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, withCategory(TimeoutError, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// create creates the lock file with the specified content, it fails if the file already exists.
+func (l *RepoLock) create(content []byte) error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(content); err != nil {
+		f.Close()
+		os.Remove(l.path)
+		return err
+	}
+
+	return f.Close()
+}
+
+// readHolder returns the holder of the existing lock file, or nil if it has been released or removed for being stale.
+func (l *RepoLock) readHolder(host string) (*LockHolder, error) {
+	content, err := ioutil.ReadFile(l.path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var holder LockHolder
+
+	if e := json.Unmarshal(content, &holder); e != nil {
+		info, err := os.Stat(l.path)
+
+		if os.IsNotExist(err) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		} else if time.Since(info.ModTime()) < unreadableLockAge {
+			return &LockHolder{}, nil
+		}
+	} else if !holder.stale(host) {
+		return &holder, nil
+	}
+
+	return nil, l.removeStale(content)
+}
+
+// removeStale removes the lock file if it still holds the specified stale content, so that a lock acquired by another
+// run in the meantime is kept.
+func (l *RepoLock) removeStale(content []byte) error {
+	current, err := ioutil.ReadFile(l.path)
+
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	} else if !bytes.Equal(current, content) {
+		return nil
+	}
+
+	if err = os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Holder returns the description of the run holding this lock.
+func (l *RepoLock) Holder() LockHolder {
+	return l.holder
+}
+
+// Release releases this lock, it returns an error on failure.
+func (l *RepoLock) Release() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// processAlive returns true if a process of the specified ID is running, or may be running where it can not be told.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	p, err := os.FindProcess(pid)
+
+	if err != nil {
+		return false
+	} else if runtime.GOOS == "windows" {
+		// finding a process only succeeds on windows if it is running.
+		return true
+	}
+
+	err = p.Signal(syscall.Signal(0))
+
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLock(t *testing.T, gitDir string, holder LockHolder) {
+	content, err := json.Marshal(holder)
+
+	if err != nil {
+		t.Fatal(err)
+	} else if err = ioutil.WriteFile(filepath.Join(gitDir, lockFileName), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func deadPID(t *testing.T) int {
+	c := exec.Command("true")
+
+	if err := c.Run(); err != nil {
+		t.Skip("no 'true' command to get a dead process ID from")
+	}
+
+	return c.ProcessState.Pid()
+}
+
+func TestAcquireRepoLock(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := AcquireRepoLock(context.Background(), dir, releaseCommand, 0)
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, os.Getpid(), lock.Holder().PID)
+	assert.Equal(t, releaseCommand, lock.Holder().Command)
+	assert.FileExists(t, filepath.Join(dir, lockFileName))
+
+	_, err = AcquireRepoLock(context.Background(), dir, releaseCommand, 0)
+
+	if assert.NotNil(t, err) {
+		assert.Equal(t, fmt.Sprintf("the repository is locked by another rego run (%v), wait for it to finish or use '--wait'", lock.Holder()), err.Error())
+
+		category, code := Categorize(err)
+		assert.Equal(t, LockedError, category)
+		assert.Equal(t, 13, code)
+		assert.Equal(t, os.Getpid(), errorOf(err).Details["pid"])
+	}
+
+	assert.Nil(t, lock.Release())
+	assert.Nil(t, lock.Release())
+
+	_, err = os.Stat(filepath.Join(dir, lockFileName))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAcquireRepoLock_Stale(t *testing.T) {
+	dir := t.TempDir()
+	host, _ := os.Hostname()
+
+	writeLock(t, dir, LockHolder{PID: deadPID(t), Host: host, Started: time.Now()})
+
+	lock, err := AcquireRepoLock(context.Background(), dir, releaseCommand, 0)
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, os.Getpid(), lock.Holder().PID)
+		lock.Release()
+	}
+}
+
+func TestAcquireRepoLock_OtherHost(t *testing.T) {
+	dir := t.TempDir()
+
+	writeLock(t, dir, LockHolder{PID: deadPID(t), Host: "elsewhere", Started: time.Now()})
+
+	_, err := AcquireRepoLock(context.Background(), dir, releaseCommand, 0)
+
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "on host 'elsewhere'")
+	}
+}
+
+func TestAcquireRepoLock_Unreadable(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, lockFileName)
+
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// a lock file that is still being written is held.
+	_, err := AcquireRepoLock(context.Background(), dir, releaseCommand, 0)
+	assert.NotNil(t, err)
+
+	old := time.Now().Add(-2 * unreadableLockAge)
+
+	if err = os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := AcquireRepoLock(context.Background(), dir, releaseCommand, 0)
+
+	if assert.Nil(t, err) {
+		lock.Release()
+	}
+}
+
+func TestAcquireRepoLock_Wait(t *testing.T) {
+	interval := lockPollInterval
+	lockPollInterval = 10 * time.Millisecond
+	defer func() { lockPollInterval = interval }()
+
+	dir := t.TempDir()
+
+	held, err := AcquireRepoLock(context.Background(), dir, releaseCommand, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = AcquireRepoLock(context.Background(), dir, releaseCommand, 30*time.Millisecond)
+
+	if assert.NotNil(t, err) {
+		assert.True(t, strings.HasSuffix(err.Error(), "after waiting for 30ms"), err.Error())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	_, err = AcquireRepoLock(ctx, dir, releaseCommand, time.Minute)
+
+	category, _ := Categorize(err)
+	assert.Equal(t, TimeoutError, category)
+
+	time.AfterFunc(50*time.Millisecond, func() { held.Release() })
+
+	lock, err := AcquireRepoLock(context.Background(), dir, releaseCommand, time.Minute)
+
+	if assert.Nil(t, err) {
+		lock.Release()
+	}
+}
+
+func TestRunner_Run_Locked(t *testing.T) {
+	dir := nativeFixture(t)
+	host, _ := os.Hostname()
+
+	writeLock(t, filepath.Join(dir, ".git"), LockHolder{PID: os.Getpid(), Host: host, Started: time.Now()})
+
+	fake := NewFakeCommander()
+	_, err := (&Runner{Conf: &configurations{WorkDir: dir, Command: releaseCommand}, Commander: fake}).Run()
+
+	category, _ := Categorize(err)
+	assert.Equal(t, LockedError, category)
+	assert.Empty(t, fake.Unexpected())
+}
+
+func TestGit_IndexLockRetry(t *testing.T) {
+	backoff := indexLockBackoff
+	indexLockBackoff = []time.Duration{time.Millisecond, time.Millisecond}
+	defer func() { indexLockBackoff = backoff }()
+
+	locked := CommandCall{
+		Name:     "git",
+		Args:     []string{"checkout", "abc"},
+		ExitCode: 128,
+		Stderr:   "fatal: Unable to create '/work/.git/index.lock': File exists.",
+	}
+
+	fake := NewFakeCommander(locked, CommandCall{Name: "git", Args: []string{"checkout", "abc"}})
+
+	assert.Nil(t, (&Git{WorkDir: "/work", Commander: fake}).Checkout("abc"))
+	assert.Empty(t, fake.Remaining())
+
+	// it gives up once the backoff is exhausted.
+	fake = NewFakeCommander(locked, locked, locked, locked)
+
+	err := (&Git{WorkDir: "/work", Commander: fake}).Checkout("abc")
+
+	assert.Equal(t, 128, ExitCode(err))
+	assert.Len(t, fake.Remaining(), 1)
+
+	// the other failures are not retried.
+	fake = NewFakeCommander(CommandCall{Name: "git", Args: []string{"checkout", "abc"}, ExitCode: 1, Stderr: "error: pathspec"}, locked)
+
+	assert.NotNil(t, (&Git{WorkDir: "/work", Commander: fake}).Checkout("abc"))
+	assert.Len(t, fake.Remaining(), 1)
+}
@@ -17,17 +17,35 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
 const (
 	executionErrorCode = 126
 )
 
-func fail(code int, format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, format+NewLine(), args...)
+// fail reports the specified error in the configured error format and exits with the exit code of its category.
+func fail(conf *configurations, err error) {
+	_, code := Categorize(err)
+
+	if conf.ErrorFormat == jsonErrorFormat {
+		writeJSONError(os.Stderr, err, NewRedactor(os.Environ()))
+	} else if conf.Logger != nil {
+		conf.Logger.Error(err.Error())
+	} else {
+		fmt.Fprintf(os.Stderr, "%v%v", err.Error(), NewLine())
+	}
+
 	os.Exit(code)
 }
 
@@ -42,102 +60,903 @@ func exit(format string, args ...interface{}) {
 
 func read(conf *configurations) {
 	if out, err := configure(conf); err != nil {
-		fail(executionErrorCode, err.Error())
+		fail(conf, withCategory(UsageError, err))
 	} else if len(out) > 0 {
 		exit(out)
 	}
 
-	if conf.Verbose {
-		print(`
-Branch: %v
-Commit hash: %v
-Tag: %v
-Working directory: %v
-Release version: %v
-Ignore tag prefix: %v
-Package: %v"
-`, conf.Branch, conf.Commit, conf.Tag, conf.WorkDir, conf.Release, conf.IgnoreTagPrefix, conf.Package)
+	conf.Logger.Debug("configurations",
+		"branch", conf.Branch,
+		"git_flow", conf.GitFlow,
+		"commit", conf.Commit,
+		"tag", conf.Tag,
+		"ref", conf.Ref,
+		"work_dir", conf.WorkDir,
+		"release", conf.Release,
+		"ignore_tag_prefix", conf.IgnoreTagPrefix,
+		"package", conf.Package,
+		"config_file", conf.ConfigFile,
+		"build", conf.Build,
+		"targets", conf.Targets,
+		"variants", conf.Variants,
+		"toolchains", conf.Toolchains,
+		"main_packages", conf.MainPattern,
+		"binaries", conf.Binaries,
+		"allow_dirty", conf.AllowDirty,
+		"check_remote", conf.CheckRemote,
+		"remote", conf.Remote,
+		"offline", conf.Offline,
+		"policy", conf.Policy,
+		"signatures", conf.Signatures,
+		"gates", conf.Gates,
+		"timeout", conf.Timeout,
+		"wait", conf.Wait,
+		"git_backend", conf.GitBackend,
+		"manifest", conf.Manifest,
+		"log_level", conf.LogLevel,
+		"log_format", conf.LogFormat)
+}
+
+// Runner runs a command with the read configurations, it logs its progress and returns the failures instead of
+// exiting, so that every path of the release flow can be tested.
+type Runner struct {
+	// Conf is the configurations the command runs with.
+	Conf *configurations
+	// Commander creates the git and Go commands, the real ones are executed if not set.
+	Commander Commander
+	// Logger receives the progress messages, they are discarded if not set.
+	Logger *slog.Logger
+	// Now returns the build time, the current time is used if not set.
+	Now func() time.Time
+}
+
+func (r *Runner) log() *slog.Logger {
+	return loggerOrDiscard(r.Logger)
+}
+
+// step runs the specified step of the release flow and logs its duration at the debug level.
+func (r *Runner) step(name string, f func() error, attrs ...interface{}) error {
+	start := time.Now()
+	err := f()
+	attrs = append([]interface{}{"step", name, "duration", time.Since(start)}, attrs...)
+
+	if err != nil {
+		r.log().Debug("step failed", attrs...)
+	} else {
+		r.log().Debug("step finished", attrs...)
+	}
+
+	return err
+}
+
+func (r *Runner) now() time.Time {
+	if r.Now != nil {
+		return r.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (r *Runner) git() *Git {
+	return &Git{WorkDir: r.Conf.WorkDir, Logger: r.Logger, Context: r.Conf.Context, Commander: r.Commander}
+}
+
+// repository returns the configured git backend to pick the release with.
+func (r *Runner) repository() Repository {
+	if r.Conf.GitBackend == nativeGitBackend {
+		return &NativeGit{WorkDir: r.Conf.WorkDir}
 	}
+	return r.git()
+}
+
+func (r *Runner) goTools(t Toolchain) *GoTools {
+	return &GoTools{WorkDir: r.Conf.WorkDir, Logger: r.Logger, Toolchain: t, Context: r.Conf.Context, Commander: r.Commander}
 }
 
-func validate(conf *configurations) {
-	g := &Git{WorkDir: conf.WorkDir, Verbose: conf.Verbose}
+// Run runs the configured command, it returns the final message to print, which is also returned along with the error
+// of a failed diagnosis.
+func (r *Runner) Run() (string, error) {
+	conf := r.Conf
+
+	if conf.Command == lintCommitsCommand {
+		return "", r.LintCommits()
+	} else if conf.Command == doctorCommand {
+		return r.Doctor()
+	} else if conf.Command == initCommand {
+		return r.Init()
+	}
+
+	// only the runs that may check out and build hold the lock, the read-only ones may run along with them.
+	if !conf.PolicyOnly {
+		if lock, err := r.lock(); err != nil {
+			return "", err
+		} else if lock != nil {
+			defer r.unlock(lock)
+		}
+	}
+
+	if err := r.step("validate", r.Validate); err != nil {
+		return "", err
+	}
+
+	if conf.PolicyOnly {
+		return fmt.Sprintf("release '%v' from %v '%v' complies with the policy", conf.Release, conf.Reference.Kind, conf.Reference.Name), nil
+	}
+
+	return "", r.Release()
+}
+
+// lock acquires the lock of the repository of the working directory, waiting for it up to the '--wait' duration,
+// it returns an error if the repository of the working directory is not found.
+func (r *Runner) lock() (*RepoLock, error) {
+	repo, err := (&NativeGit{WorkDir: r.Conf.WorkDir}).discover()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := r.Conf.Context
 
-	var status string
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	lock, err := AcquireRepoLock(ctx, repo.gitDir, r.Conf.Command, r.Conf.Wait)
+
+	if err == nil {
+		r.log().Debug("repository is locked", "path", lock.path)
+	}
+
+	return lock, err
+}
+
+func (r *Runner) unlock(lock *RepoLock) {
+	if err := lock.Release(); err != nil {
+		r.log().Warn("failed to release the repository lock", "path", lock.path, "error", err)
+	}
+}
+
+// Validate validates the working tree and picks the release commit and version, then runs the enabled checks.
+func (r *Runner) Validate() error {
+	conf := r.Conf
+	g := r.git()
+	repo := r.repository()
+
+	var status Status
 	var err error
 
-	if status, err = g.Status(); err != nil {
-		fail(executionErrorCode, err.Error())
+	if !conf.NoModuleScope {
+		if conf.Module, err = NewModuleScope(repo, conf.WorkDir); err != nil {
+			return err
+		} else if conf.Module.Scoped() {
+			r.log().Debug("scoped to module", "module", conf.Module.Prefix, "paths", conf.Module.Paths)
+		}
 	}
 
-	if len(status) > 0 {
-		fail(executionErrorCode, "Uncommitted/untracked files:%v %v", NewLine(), status)
+	if status, err = repo.Status(conf.Module.Paths...); err != nil {
+		return err
 	}
 
-	if len(conf.Tag) > 0 {
-		if conf.Verbose {
-			print("requested tag: %v", conf.Tag)
+	if filtered := status.Filter(conf.Ignore); len(filtered) < len(status) {
+		for _, e := range status {
+			if e.Ignored(conf.Ignore) {
+				conf.TreeState.Ignored = append(conf.TreeState.Ignored, e.Path)
+			}
 		}
 
-		if conf.Commit, err = g.GetTagCommit(conf.Tag); err != nil {
-			fail(executionErrorCode, err.Error())
+		r.log().Warn("ignoring uncommitted/untracked files matching the ignore rules", "files", conf.TreeState.Ignored)
+		status = filtered
+	}
+
+	if len(status) > 0 && !conf.AllowDirty {
+		var files []string
+
+		for _, e := range status {
+			files = append(files, e.Path)
 		}
 
-		conf.Release = strings.TrimPrefix(conf.Tag, conf.IgnoreTagPrefix)
-	} else if len(conf.Commit) > 0 {
-		if conf.Verbose {
-			print("requested commit: %v", conf.Commit)
+		return withCategory(DirtyError, fmt.Errorf("Uncommitted/untracked files:%v%v", NewLine(), status.Report()), "files", files)
+	}
+
+	if len(status) > 0 {
+		if err = r.validateDirty(g); err != nil {
+			return err
+		}
+	} else if len(conf.Ref) > 0 {
+		r.log().Debug("requested reference", "ref", conf.Ref)
+
+		if conf.Reference, err = repo.ResolveReference(conf.Ref); err != nil {
+			return err
+		}
+
+		conf.Commit = conf.Reference.Commit
+
+		if conf.Reference.Kind == TagReference {
+			conf.Tag = conf.Reference.Name
+			conf.Release = strings.TrimPrefix(conf.Module.TrimTag(conf.Tag), conf.IgnoreTagPrefix)
+		}
+
+		r.log().Debug("reference is resolved", "ref", conf.Ref, "kind", conf.Reference.Kind, "name", conf.Reference.Name)
+	} else if len(conf.Tag) > 0 {
+		r.log().Debug("requested tag", "tag", conf.Tag)
+
+		for _, tag := range conf.Module.TagNames(conf.Tag) {
+			if _, err = repo.GetTagCommit(tag); err == nil {
+				conf.Tag = tag
+				break
+			}
 		}
 
+		if err != nil {
+			return err
+		}
+
+		// an annotated tag points to a tag object, it is peeled to the commit it tags.
+		if conf.Reference, err = repo.ResolveReference("refs/tags/" + conf.Tag); err != nil {
+			return err
+		}
+
+		conf.Commit = conf.Reference.Commit
+		conf.Release = strings.TrimPrefix(conf.Module.TrimTag(conf.Tag), conf.IgnoreTagPrefix)
+		conf.Reference = Reference{Name: conf.Tag, Kind: TagReference, Commit: conf.Commit}
+	} else if len(conf.Commit) > 0 {
+		r.log().Debug("requested commit", "commit", conf.Commit)
+
 		var exists bool
 
-		if exists, err = g.IsCommitExists(conf.Commit); err != nil {
-			fail(executionErrorCode, err.Error())
+		if exists, err = repo.IsCommitExists(conf.Commit); err != nil {
+			return err
 		} else if !exists {
-			fail(executionErrorCode, "invalid commit specified")
+			return newError(ReferenceError, "invalid commit specified")
 		}
+
+		conf.Reference = Reference{Name: conf.Commit, Kind: CommitReference, Commit: conf.Commit}
 	} else {
-		if conf.Commit, err = g.GetBranchCommit(conf.Branch); err != nil {
-			fail(executionErrorCode, err.Error())
+		if len(conf.Branch) == 0 && conf.GitFlow {
+			conf.Branch = gitFlowBranch
+
+			r.log().Debug("branch is picked by the GitFlow branching model", "branch", conf.Branch)
+		} else if len(conf.Branch) == 0 {
+			var rule string
+
+			if conf.Branch, rule, err = repo.DefaultBranch(); err != nil {
+				return err
+			}
+
+			r.log().Debug("branch is picked "+rule, "branch", conf.Branch)
+		}
+
+		if conf.Commit, err = repo.GetBranchCommit(conf.Branch); err != nil {
+			return err
+		}
+
+		conf.Reference = Reference{Name: conf.Branch, Kind: BranchReference, Commit: conf.Commit}
+
+		r.log().Debug("requested branch", "branch", conf.Branch, "commit", conf.Commit)
+	}
+
+	if r.log().Enabled(context.Background(), slog.LevelDebug) {
+		if description, e := repo.Describe(conf.Commit); e == nil {
+			r.log().Debug("target commit", "commit", conf.Commit, "description", description)
+		} else {
+			r.log().Debug("target commit", "commit", conf.Commit)
+		}
+	}
+
+	if conf.CheckRemote {
+		check := RemoteCheck{Remote: conf.Remote, Offline: conf.Offline, Logger: r.Logger}
+
+		if err = check.Check(g, conf.Reference); err != nil {
+			return withCategory(VerificationError, err)
+		}
+	}
+
+	if conf.Policy.Enabled() {
+		if err = r.validatePolicy(g); err != nil {
+			return err
+		}
+	}
+
+	if conf.Signatures.Enabled() {
+		if conf.Signed, err = conf.Signatures.Verify(g, conf.Reference); err != nil {
+			return withCategory(VerificationError, err)
+		}
+
+		if conf.Signed.Commit != nil {
+			r.log().Debug("commit is signed", "commit", conf.Commit, "signer", conf.Signed.Commit.Signer, "key", conf.Signed.Commit.Key)
+		}
+
+		if conf.Signed.Tag != nil {
+			r.log().Debug("tag is signed", "tag", conf.Tag, "signer", conf.Signed.Tag.Signer, "key", conf.Signed.Tag.Key)
+		} else if conf.Signatures.Tag {
+			r.log().Debug("skipping the tag signature verification since no tag is released")
+		}
+	}
+
+	if conf.Commits.Enabled() {
+		return r.validateCommits(g)
+	}
+
+	return nil
+}
+
+// validateCommits checks the messages of the commits after '--since', or after the most recent tag before the target
+// commit if not specified, up to the target commit against the commit rules, and reports all the non-compliant ones.
+func (r *Runner) validateCommits(g *Git) error {
+	conf := r.Conf
+	var commits []CommitMessage
+	var err error
+
+	from := conf.Since
+
+	if len(from) == 0 {
+		commit := conf.Reference.Commit
+
+		// the released tag itself is not a previous tag.
+		if conf.Reference.Kind == TagReference {
+			commit += "^"
+		}
+
+		pattern := ""
+
+		if conf.Module.Scoped() {
+			pattern = conf.Module.Prefix + "/*"
+		}
+
+		// a root commit has no parent to look for the previous tags from.
+		if _, e := g.ResolveReference(commit); e == nil {
+			if from, err = g.PreviousTag(commit, pattern); err != nil {
+				return err
+			}
 		}
+	}
+
+	// the commits of a module only are the ones touching its directory or its local replacements.
+	if commits, err = g.Commits(from, conf.Reference.Commit, conf.Module.Paths...); err != nil {
+		return err
+	}
+
+	violations, err := conf.Commits.Lint(commits)
 
-		if conf.Verbose {
-			print("requested branch '%v' commit: %v", conf.Branch, conf.Commit)
+	if err != nil {
+		return err
+	} else if len(violations) > 0 {
+		var hashes []string
+
+		for _, v := range violations {
+			hashes = append(hashes, v.Commit.Hash)
 		}
+
+		return withCategory(PolicyError, fmt.Errorf("Non-compliant commits:%v%v", NewLine(), LintReport(violations)), "commits", hashes)
 	}
 
-	if conf.Verbose {
-		print("target commit: %v", conf.Commit)
+	since := fmt.Sprintf("since '%v'", from)
+
+	if len(from) == 0 {
+		since = "in the whole history"
 	}
+
+	level := slog.LevelDebug
+
+	if conf.Command == lintCommitsCommand {
+		level = slog.LevelInfo
+	}
+
+	r.log().Log(context.Background(), level, fmt.Sprintf("%v commits %v comply with the commit rules", len(commits), since), "commits", len(commits))
+
+	return nil
 }
 
-func release(conf *configurations) {
+// Doctor runs the diagnostics of the environment, it returns their report and an error if any of them has failed.
+func (r *Runner) Doctor() (string, error) {
+	conf := r.Conf
 
-	if conf.Verbose {
-		print("generating release: %v", conf.Release)
+	var toolchain Toolchain
+
+	if len(conf.Toolchains) > 0 {
+		toolchain = conf.Toolchains[0]
 	}
 
+	d := &Doctor{
+		Git:         r.git(),
+		GoTools:     r.goTools(toolchain),
+		Package:     conf.Package,
+		MainPattern: conf.MainPattern,
+		Binaries:    conf.Binaries,
+	}
+
+	return DoctorReport(d.Run(), conf.JSON)
+}
+
+// Init declares the release variables in the project and writes a starter configuration file, it returns the list of
+// the written files.
+func (r *Runner) Init() (string, error) {
+	conf := r.Conf
+
+	s := &Scaffold{
+		Git:         r.git(),
+		GoTools:     r.goTools(Toolchain{}),
+		Package:     conf.Package,
+		MainPattern: conf.MainPattern,
+		File:        conf.VersionFile,
+		ConfigFile:  conf.ConfigFile,
+	}
+
+	written, err := s.Run()
+
+	for _, f := range written {
+		r.log().Debug("written", "file", f)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	message := fmt.Sprintf("written:%v%v", NewLine(), strings.Join(written, NewLine()))
+
+	if len(conf.Package) > 0 && conf.Package != "main" {
+		message += fmt.Sprintf("%vthe releases have to be built with '--package %v'", NewLine(), conf.Package)
+	}
+
+	return message, nil
+}
+
+// LintCommits only checks the messages of the commits up to the target commit against the commit rules, the target
+// commit is picked the same way as for a release, except that it's the checked out commit if nothing is specified.
+func (r *Runner) LintCommits() error {
+	conf := r.Conf
+	g := r.git()
+
 	var err error
 
-	g := &Git{WorkDir: conf.WorkDir, Verbose: conf.Verbose}
+	if !conf.Commits.Enabled() {
+		return newError(UsageError, "no commit rules are found in the configuration file '%v'", conf.ConfigFile)
+	}
 
-	if err = g.Checkout(conf.Commit); err != nil {
-		fail(executionErrorCode, err.Error())
-	} else {
-		print("commit '%v' is checked out, don't forget to switch back to your working reference", conf.Commit)
+	if !conf.NoModuleScope {
+		if conf.Module, err = NewModuleScope(g, conf.WorkDir); err != nil {
+			return err
+		}
+	}
+
+	expr := "HEAD"
+
+	switch {
+	case len(conf.Ref) > 0:
+		expr = conf.Ref
+	case len(conf.Tag) > 0:
+		expr = conf.Module.TagNames(conf.Tag)[0]
+
+		if _, e := g.GetTagCommit(expr); e != nil {
+			expr = conf.Tag
+		}
+	case len(conf.Commit) > 0:
+		expr = conf.Commit
+	case len(conf.Branch) > 0:
+		expr = conf.Branch
+	}
+
+	if conf.Reference, err = g.ResolveReference(expr); err != nil {
+		return err
+	}
+
+	return r.validateCommits(g)
+}
+
+// validatePolicy evaluates the configured policy rules and reports all the violated ones.
+func (r *Runner) validatePolicy(g *Git) error {
+	conf := r.Conf
+
+	violations, err := conf.Policy.Evaluate(g, PolicyTarget{
+		Ref:             conf.Reference,
+		Release:         conf.Release,
+		Module:          conf.Module,
+		IgnoreTagPrefix: conf.IgnoreTagPrefix,
+	})
+
+	if err != nil {
+		return err
+	} else if len(violations) > 0 {
+		var messages []string
+
+		for _, v := range violations {
+			messages = append(messages, v.String())
+		}
+
+		return withCategory(PolicyError, fmt.Errorf("Policy violations:%v %v", NewLine(), strings.Join(messages, NewLine()+" ")), "violations", messages)
+	}
+
+	r.log().Debug("the release complies with the policy")
+
+	return nil
+}
+
+// validateDirty picks the checked out commit to build the working tree with its uncommitted changes.
+func (r *Runner) validateDirty(g *Git) error {
+	conf := r.Conf
+
+	var head string
+	var err error
+
+	if head, err = g.HeadCommit(); err != nil {
+		return err
+	}
+
+	expr := "HEAD"
+
+	if len(conf.Ref) > 0 {
+		expr = conf.Ref
 	}
 
-	if conf.Verbose {
-		print("building from commit '%v'", conf.Commit)
+	if conf.Reference, err = g.ResolveReference(expr); err != nil {
+		return err
+	} else if len(conf.Ref) > 0 && conf.Reference.Kind == TagReference {
+		return newError(UsageError, "the '--allow-dirty' option can not be used while releasing the tag '%v'", conf.Reference.Name)
+	} else if len(conf.Ref) > 0 && conf.Reference.Commit != head {
+		return newError(ReferenceError, "reference '%v' is not checked out, uncommitted changes can only be built on top of the checked out commit '%v'", conf.Ref, head)
+	} else if len(conf.Ref) == 0 && len(conf.Commit) > 0 && conf.Commit != head {
+		return newError(ReferenceError, "commit '%v' is not checked out, uncommitted changes can only be built on top of the checked out commit '%v'", conf.Commit, head)
+	}
+
+	if conf.TreeState, err = g.TreeState(conf.Module.Paths...); err != nil {
+		return err
+	}
+
+	conf.Commit = head
+	conf.Release = conf.Release + "+" + dirtyTreeState
+
+	r.log().Info("building the working tree with uncommitted changes", "commit", conf.Commit, "files", conf.TreeState.Files)
+
+	return nil
+}
+
+// Release checks out the release commit, runs the quality gates then builds the binaries and writes the manifest.
+func (r *Runner) Release() error {
+	conf := r.Conf
+
+	r.log().Debug("generating release", "release", conf.Release)
+
+	var err error
+
+	g := r.git()
+
+	// checking out would either fail or carry the uncommitted changes along, so a dirty working tree is built as is.
+	if !conf.TreeState.Dirty() {
+		if err = r.step("checkout", func() error { return r.checkout(g) }, "commit", conf.Commit); err != nil {
+			return err
+		}
 	}
 
-	gt := &GoTools{WorkDir: conf.WorkDir, Verbose: conf.Verbose}
+	var submodules []Submodule
+
+	if submodules, err = r.submodules(g); err != nil {
+		return err
+	}
+
+	for _, s := range submodules {
+		// a dirty working tree may have its submodules checked out at other commits, which is reported as uncommitted.
+		if problem := s.Problem(); len(problem) > 0 && (!conf.TreeState.Dirty() || s.State != SubmoduleOutOfSync) {
+			return withCategory(DirtyError, errors.New(problem), "submodule", s.Path)
+		}
+
+		r.log().Debug("submodule", "path", s.Path, "commit", s.Commit)
+	}
+
+	r.log().Debug("building from commit", "commit", conf.Commit)
+
+	toolchains := conf.Toolchains
+
+	if len(toolchains) == 0 {
+		toolchains = []Toolchain{{}}
+	}
+
+	versions := make([]string, len(toolchains))
+
+	err = r.step("toolchains", func() error {
+		for i, t := range toolchains {
+			gt := r.goTools(t)
+
+			if versions[i], err = gt.Version(); err != nil {
+				return withCategory(ToolchainError, errors.Wrapf(err, "toolchain '%v'", t))
+			} else if err = gt.CheckModule(); err != nil {
+				return withCategory(ToolchainError, errors.Wrapf(err, "toolchain '%v'", t))
+			}
+
+			r.log().Debug("toolchain", "toolchain", t, "version", versions[i])
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	gt := r.goTools(toolchains[0])
+	gt.Options = conf.Build
 
 	if err = gt.Clean(); err != nil {
-		fail(executionErrorCode, err.Error())
-	} else if err = gt.Install(conf.Commit, conf.Release, conf.Package); err != nil {
-		fail(executionErrorCode, err.Error())
+		return err
 	}
+
+	var packages []MainPackage
+
+	if packages, err = gt.MainPackages(conf.MainPattern); err != nil {
+		return err
+	} else if len(packages) == 0 {
+		return newError(UsageError, "no main packages match '%v'", conf.MainPattern)
+	}
+
+	for _, b := range conf.Binaries {
+		if !matchesAny(conf.WorkDir, b, packages) {
+			return newError(UsageError, "binary '%v' does not match any of the main packages", b.Package)
+		}
+	}
+
+	r.log().Debug("main packages", "packages", packages)
+
+	var gates []ManifestGate
+
+	if !conf.SkipGates {
+		err = r.step("gates", func() error {
+			gates, err = r.runGates(gt, g)
+			return err
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	now := r.now()
+
+	manifest := &Manifest{
+		Commit:       conf.Commit,
+		Release:      conf.Release,
+		Ref:          conf.Reference,
+		Timestamp:    now.Format(time.RFC3339),
+		TreeState:    conf.TreeState.String(),
+		DirtyFiles:   conf.TreeState.Files,
+		DiffHash:     conf.TreeState.DiffHash,
+		IgnoredFiles: conf.TreeState.Ignored,
+		Submodules:   submodules,
+		Gates:        gates,
+	}
+
+	if conf.Signed.Commit != nil || conf.Signed.Tag != nil {
+		manifest.Signatures = &conf.Signed
+	}
+
+	if conf.Module.Scoped() {
+		manifest.Module = &ManifestModule{Path: conf.Module.Prefix}
+
+		if manifest.Module.Commit, err = r.repository().LastCommit(conf.Commit, conf.Module.Paths...); err != nil {
+			return err
+		}
+	}
+
+	for _, b := range builds(conf, now, versions, packages) {
+		if len(b.name) > 0 {
+			r.log().Debug("building", "build", b.name)
+		}
+
+		b.tools.Commander = r.Commander
+		b.tools.Logger = r.Logger
+
+		err = r.step("build", func() error { return b.tools.Install(conf.Commit, conf.Release, conf.Package) }, "build", b.name)
+
+		if err != nil {
+			if len(b.name) > 0 {
+				return withCategory(BuildError, errors.Wrapf(err, "'%v'", b.name), "build", b.name)
+			}
+			return withCategory(BuildError, err)
+		}
+
+		manifest.Binaries = append(manifest.Binaries, b.binary)
+	}
+
+	if len(conf.Manifest) > 0 {
+		if err = r.step("manifest", func() error { return manifest.Write(conf.Manifest) }, "path", conf.Manifest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkout checks out the release commit along with its submodules, the native git backend can not check out so the
+// release commit must already be checked out.
+func (r *Runner) checkout(g *Git) error {
+	conf := r.Conf
+
+	if conf.GitBackend == nativeGitBackend {
+		head, err := r.repository().HeadCommit()
+
+		if err != nil {
+			return err
+		} else if head != conf.Commit {
+			return newError(ReferenceError, "commit '%v' is not checked out, it can not be checked out with the '%v' git backend", conf.Commit, nativeGitBackend)
+		}
+
+		return nil
+	}
+
+	if err := g.Checkout(conf.Commit); err != nil {
+		return err
+	}
+
+	r.log().Info("commit is checked out, don't forget to switch back to your working reference", "commit", conf.Commit)
+
+	return g.UpdateSubmodules()
+}
+
+// submodules returns the submodules of the working tree along with their states, the native git backend does not
+// support submodules so it only makes sure that there are none.
+func (r *Runner) submodules(g *Git) ([]Submodule, error) {
+	if r.Conf.GitBackend != nativeGitBackend {
+		return g.Submodules()
+	}
+
+	top, err := r.repository().TopLevel()
+
+	if err != nil {
+		return nil, err
+	} else if _, err = os.Stat(filepath.Join(top, ".gitmodules")); err == nil {
+		return nil, newError(UsageError, "submodules are not supported by the '%v' git backend", nativeGitBackend)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// runGates runs the quality gates in order and fails on the first failure of a required one,
+// it returns the results of all the gates.
+func (r *Runner) runGates(gt *GoTools, g *Git) ([]ManifestGate, error) {
+	conf := r.Conf
+
+	var results []ManifestGate
+
+	for _, gate := range conf.Gates {
+		r.log().Debug("running gate", "gate", gate.Name)
+
+		res := gate.Run(gt, g, conf.Module.Paths)
+		duration := res.Duration.Round(time.Millisecond)
+
+		results = append(results, ManifestGate{Name: res.Name, Status: res.Status(), Duration: duration.String()})
+
+		if !res.Passed && !res.Advisory {
+			return results, withCategory(GateError, fmt.Errorf("gate '%v' %v in %v:%v%v", res.Name, res.Status(), duration, NewLine(), res.Output),
+				"gate", res.Name, "status", res.Status())
+		} else if !res.Passed {
+			r.log().Warn("gate "+res.Status(), "gate", res.Name, "duration", duration, "output", res.Output)
+		} else if len(res.Output) > 0 && r.log().Enabled(context.Background(), slog.LevelDebug) {
+			r.log().Info("gate "+res.Status(), "gate", res.Name, "duration", duration, "output", res.Output)
+		} else {
+			r.log().Info("gate "+res.Status(), "gate", res.Name, "duration", duration)
+		}
+	}
+
+	return results, nil
+}
+
+type build struct {
+	name   string
+	tools  *GoTools
+	binary ManifestBinary
+}
+
+func matchesAny(workDir string, b Binary, packages []MainPackage) bool {
+	for _, p := range packages {
+		if b.Matches(workDir, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// builds returns a build for every combination of the configured toolchains, targets, variants and the specified
+// main packages, all sharing the same build timestamp, the versions of the toolchains are appended to the binaries
+// names if more than one toolchain is configured.
+func builds(conf *configurations, now time.Time, versions []string, packages []MainPackage) []build {
+	toolchains := conf.Toolchains
+	targets := conf.Targets
+	variants := conf.Variants
+
+	if len(toolchains) == 0 {
+		toolchains = []Toolchain{{}}
+	}
+
+	if len(packages) == 0 {
+		packages = []MainPackage{{}}
+	}
+
+	if len(targets) == 0 {
+		targets = []BuildTarget{{}}
+	}
+
+	if len(variants) == 0 {
+		variants = []Variant{{}}
+	}
+
+	var result []build
+
+	for i, tc := range toolchains {
+		for _, t := range targets {
+			for _, v := range variants {
+				for _, p := range packages {
+					var names []string
+
+					gt := &GoTools{
+						WorkDir:   conf.WorkDir,
+						Options:   conf.Build.Merge(t.Build).Merge(v.Build),
+						Env:       append(t.Env(), v.Env...),
+						Suffix:    v.Suffix,
+						Variables: map[string]string{"GitTreeState": conf.TreeState.String()},
+						Timestamp: now,
+						Toolchain: tc,
+						Package:   p.ImportPath,
+						Context:   conf.Context,
+						version:   versions[i],
+					}
+
+					if conf.Signed.Commit != nil {
+						gt.Variables["GitCommitSigner"] = conf.Signed.Commit.Signer
+					}
+
+					if conf.Signed.Tag != nil {
+						gt.Variables["GitTagSigner"] = conf.Signed.Tag.Signer
+					}
+
+					if len(conf.Reference.Kind) > 0 {
+						gt.Variables["GitRef"] = conf.Reference.Name
+						gt.Variables["GitRefKind"] = conf.Reference.Kind
+					}
+
+					for _, b := range conf.Binaries {
+						if b.Matches(conf.WorkDir, p) {
+							gt.Name = b.Name
+							gt.Mappings = b.Variables
+							break
+						}
+					}
+
+					if len(packages) > 1 {
+						name := gt.Name
+
+						if len(name) == 0 {
+							name = p.Name()
+						}
+
+						names = append(names, name)
+					}
+
+					if len(toolchains) > 1 {
+						names = append(names, versions[i])
+						gt.Suffix = fmt.Sprintf("%v-%v", gt.Suffix, versions[i])
+					}
+
+					if len(t.OS) > 0 || len(t.Arch) > 0 {
+						names = append(names, t.String())
+					}
+
+					if len(v.Name) > 0 {
+						names = append(names, v.Name)
+						gt.Variables["BuildVariant"] = v.Name
+					}
+
+					binary := ManifestBinary{Package: p.ImportPath, Name: gt.Name, GoVersion: versions[i], Variant: v.Name}
+
+					if len(t.OS) > 0 || len(t.Arch) > 0 {
+						binary.Target = t.String()
+					}
+
+					result = append(result, build{name: strings.Join(names, " "), tools: gt, binary: binary})
+				}
+			}
+		}
+	}
+
+	return result
 }
 
 func main() {
@@ -146,7 +965,38 @@ func main() {
 
 	read(&conf)
 
-	validate(&conf)
+	// an interrupted run stops its commands and cleans up, i.e. releases the lock and removes the generated files.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	conf.Context = ctx
+
+	if conf.Timeout > 0 {
+		var cancel context.CancelFunc
+		conf.Context, cancel = context.WithTimeout(conf.Context, conf.Timeout)
+		defer cancel()
+	}
+
+	out, err := (&Runner{Conf: &conf, Logger: conf.Logger}).Run()
+
+	if len(out) > 0 && conf.JSON {
+		fmt.Println(out)
+	} else if len(out) > 0 {
+		logResult(NewLogger(os.Stdout, conf.LogFormat, conf.LogLevel, NewRedactor(os.Environ())), out)
+	}
+
+	if err != nil {
+		// whatever has failed once the time is out, it has failed because of the timeout.
+		if conf.Context.Err() == context.DeadlineExceeded {
+			timeout := &Error{Category: TimeoutError, Err: err}
 
-	release(&conf)
+			if e := errorOf(err); e != nil {
+				timeout.Details = e.Details
+			}
+
+			err = timeout
+		}
+
+		fail(&conf, err)
+	}
 }
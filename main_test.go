@@ -0,0 +1,554 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilds_Default(t *testing.T) {
+	now := time.Now()
+	conf := &configurations{WorkDir: "/work", Build: BuildOptions{Tags: []string{"netgo"}}}
+
+	b := builds(conf, now, []string{"go1.22.3"}, nil)
+
+	assert.Len(t, b, 1)
+	assert.Empty(t, b[0].name)
+	assert.Equal(t, &GoTools{
+		WorkDir:   "/work",
+		Options:   conf.Build,
+		Variables: map[string]string{"GitTreeState": "clean"},
+		Timestamp: now,
+		version:   "go1.22.3",
+	}, b[0].tools)
+	assert.Equal(t, ManifestBinary{GoVersion: "go1.22.3"}, b[0].binary)
+}
+
+func TestBuilds_Matrix(t *testing.T) {
+	now := time.Now()
+	conf := &configurations{
+		WorkDir: "/work",
+		Build:   BuildOptions{LDFlags: "-s -w"},
+		Targets: []BuildTarget{
+			{OS: "linux", Arch: "amd64"},
+			{OS: "windows", Arch: "amd64", Build: BuildOptions{BuildMode: "exe"}},
+		},
+		Variants: []Variant{
+			{Name: "release"},
+			{Name: "debug", Build: BuildOptions{LDFlags: "-linkmode internal"}, Env: []string{"CGO_ENABLED=0"}, Suffix: "-debug"},
+		},
+	}
+
+	b := builds(conf, now, []string{"go1.22.3"}, nil)
+
+	assert.Len(t, b, 4)
+	assert.Equal(t, "linux/amd64 release", b[0].name)
+	assert.Equal(t, "windows/amd64 debug", b[3].name)
+	assert.Equal(t, &GoTools{
+		WorkDir:   "/work",
+		Options:   BuildOptions{LDFlags: "-linkmode internal", BuildMode: "exe"},
+		Env:       []string{"GOOS=windows", "GOARCH=amd64", "CGO_ENABLED=0"},
+		Suffix:    "-debug",
+		Variables: map[string]string{"BuildVariant": "debug", "GitTreeState": "clean"},
+		Timestamp: now,
+		version:   "go1.22.3",
+	}, b[3].tools)
+	assert.Equal(t, ManifestBinary{GoVersion: "go1.22.3", Target: "windows/amd64", Variant: "debug"}, b[3].binary)
+	assert.Equal(t, map[string]string{"BuildVariant": "release", "GitTreeState": "clean"}, b[0].tools.Variables)
+}
+
+func TestBuilds_Toolchains(t *testing.T) {
+	now := time.Now()
+	conf := &configurations{
+		WorkDir:    "/work",
+		Toolchains: []Toolchain{{Version: "go1.21.0"}, {Go: "/opt/go/bin/go"}},
+		Variants:   []Variant{{Name: "debug", Suffix: "-debug"}},
+	}
+
+	b := builds(conf, now, []string{"go1.21.0", "go1.22.3"}, nil)
+
+	assert.Len(t, b, 2)
+	assert.Equal(t, "go1.21.0 debug", b[0].name)
+	assert.Equal(t, "-debug-go1.21.0", b[0].tools.Suffix)
+	assert.Equal(t, Toolchain{Version: "go1.21.0"}, b[0].tools.Toolchain)
+	assert.Equal(t, "go1.22.3 debug", b[1].name)
+	assert.Equal(t, "-debug-go1.22.3", b[1].tools.Suffix)
+	assert.Equal(t, Toolchain{Go: "/opt/go/bin/go"}, b[1].tools.Toolchain)
+}
+
+func TestBuilds_Packages(t *testing.T) {
+	now := time.Now()
+	conf := &configurations{
+		WorkDir: "/work",
+		Binaries: []Binary{
+			{Package: "./cmd/api", Name: "api-server", Variables: map[string]string{"GitCommit": "Commit"}},
+		},
+	}
+
+	b := builds(conf, now, []string{"go1.22.3"}, []MainPackage{
+		{ImportPath: "example.com/project/cmd/api", Dir: "/work/cmd/api"},
+		{ImportPath: "example.com/project/cmd/worker", Dir: "/work/cmd/worker"},
+	})
+
+	assert.Len(t, b, 2)
+	assert.Equal(t, "api-server", b[0].name)
+	assert.Equal(t, &GoTools{
+		WorkDir:   "/work",
+		Timestamp: now,
+		version:   "go1.22.3",
+		Package:   "example.com/project/cmd/api",
+		Name:      "api-server",
+		Mappings:  map[string]string{"GitCommit": "Commit"},
+		Variables: map[string]string{"GitTreeState": "clean"},
+	}, b[0].tools)
+	assert.Equal(t, ManifestBinary{Package: "example.com/project/cmd/api", Name: "api-server", GoVersion: "go1.22.3"}, b[0].binary)
+	assert.Equal(t, "worker", b[1].name)
+	assert.Equal(t, &GoTools{
+		WorkDir:   "/work",
+		Timestamp: now,
+		version:   "go1.22.3",
+		Package:   "example.com/project/cmd/worker",
+		Variables: map[string]string{"GitTreeState": "clean"},
+	}, b[1].tools)
+}
+
+func TestBuilds_Dirty(t *testing.T) {
+	conf := &configurations{WorkDir: "/work", TreeState: TreeState{Files: []string{"main.go"}, DiffHash: "abc"}}
+
+	b := builds(conf, time.Now(), []string{"go1.22.3"}, nil)
+
+	assert.Len(t, b, 1)
+	assert.Equal(t, map[string]string{"GitTreeState": "dirty"}, b[0].tools.Variables)
+}
+
+func TestBuilds_Reference(t *testing.T) {
+	conf := &configurations{WorkDir: "/work", Reference: Reference{Name: "origin/main", Kind: RemoteBranchReference}}
+
+	b := builds(conf, time.Now(), []string{"go1.22.3"}, nil)
+
+	assert.Len(t, b, 1)
+	assert.Equal(t, map[string]string{
+		"GitTreeState": "clean",
+		"GitRef":       "origin/main",
+		"GitRefKind":   "remote-branch",
+	}, b[0].tools.Variables)
+}
+
+var update = flag.Bool("update", false, "records the golden command sessions in 'testdata' by running the real git and go commands")
+
+var goldenTime = time.Date(2017, time.June, 1, 12, 0, 0, 0, time.UTC)
+
+// goldenConf returns the configurations of the golden release session of the fixture repository.
+func goldenConf(workDir, outDir string) *configurations {
+	return &configurations{
+		Command:         releaseCommand,
+		WorkDir:         workDir,
+		Tag:             "v1.0",
+		IgnoreTagPrefix: "v",
+		Package:         "main",
+		MainPattern:     defaultMainPattern,
+		NoModuleScope:   true,
+		Manifest:        filepath.Join(outDir, "manifest.json"),
+	}
+}
+
+// recordGolden records the release session of a fixture repository, tagged 'v1.0', into the specified golden file.
+func recordGolden(t *testing.T, golden string) {
+	dir, err := ioutil.TempDir("", "test_rego_golden_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GOBIN", filepath.Join(dir, "bin"))
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/fixture\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err = ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	} else if err = ioutil.WriteFile(filepath.Join(dir, ".gitignore"), []byte("/bin/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the fixed identity and dates keep the commit hash, so a regenerated golden file only differs by the actual changes.
+	date := goldenTime.Format(time.RFC3339)
+	git := NewCommand(CommandSpec{Name: "git", WorkDir: dir, Env: []string{
+		"GIT_AUTHOR_NAME=Rego", "GIT_AUTHOR_EMAIL=rego@example.com", "GIT_AUTHOR_DATE=" + date,
+		"GIT_COMMITTER_NAME=Rego", "GIT_COMMITTER_EMAIL=rego@example.com", "GIT_COMMITTER_DATE=" + date,
+	}})
+
+	for _, args := range [][]string{
+		{"init", "--initial-branch", "master"},
+		{"config", "commit.gpgsign", "false"},
+		{"add", "."},
+		{"commit", "-n", "-m", "Initial commit"},
+		{"tag", "v1.0"},
+	} {
+		if _, err = git.Execute(args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recorder := &RecordingCommander{}
+
+	if _, err = (&Runner{Conf: goldenConf(dir, filepath.Join(dir, "bin")), Commander: recorder,
+		Now: func() time.Time { return goldenTime }}).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = recorder.Write(golden); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// replayGolden runs the golden release session with the specified calls, it returns the configurations,
+// the output directory and the error of the run.
+func replayGolden(t *testing.T, calls []CommandCall) (*FakeCommander, *configurations, error) {
+	dir, err := ioutil.TempDir("", "test_rego_replay_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// the repository lock is taken in the git directory which is not touched by the replayed commands.
+	if err = os.MkdirAll(filepath.Join(dir, "work", ".git"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := NewFakeCommander(calls...)
+	conf := goldenConf(filepath.Join(dir, "work"), dir)
+
+	_, err = (&Runner{Conf: conf, Commander: fake, Now: func() time.Time { return goldenTime }}).Run()
+
+	return fake, conf, err
+}
+
+func TestRunner_Release_Golden(t *testing.T) {
+	golden := filepath.Join("testdata", "release_tag.json")
+
+	if *update {
+		recordGolden(t, golden)
+	}
+
+	calls, err := ReadCommandCalls(golden)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake, conf, err := replayGolden(t, calls)
+
+	assert.Nil(t, err)
+	assert.Empty(t, fake.Remaining())
+	assert.Empty(t, fake.Unexpected())
+	assert.Equal(t, "1.0", conf.Release)
+	assert.Equal(t, Reference{Name: "v1.0", Kind: TagReference, Commit: conf.Commit}, conf.Reference)
+
+	if content, err := ioutil.ReadFile(conf.Manifest); assert.Nil(t, err) {
+		assert.Contains(t, string(content), conf.Commit)
+		assert.Contains(t, string(content), goldenTime.Format(time.RFC3339))
+	}
+}
+
+func TestRunner_Release_InstallFailure(t *testing.T) {
+	calls, err := ReadCommandCalls(filepath.Join("testdata", "release_tag.json"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range calls {
+		if calls[i].Name == "go" && len(calls[i].Args) > 0 && calls[i].Args[0] == "install" {
+			calls[i].ExitCode, calls[i].Stderr = 1, "./main.go:3:1: syntax error"
+		}
+	}
+
+	fake, conf, err := replayGolden(t, calls)
+
+	assert.EqualError(t, err, "exit status 1: ./main.go:3:1: syntax error")
+	assert.Empty(t, fake.Remaining())
+
+	_, err = os.Stat(conf.Manifest)
+	assert.True(t, os.IsNotExist(err))
+}
+
+var gitStatusCall = CommandCall{Name: "git", Args: []string{"status", "--porcelain=v2", "-z", "-uall", "--"}}
+
+func TestRunner_Validate_Dirty(t *testing.T) {
+	dirty := gitStatusCall
+	dirty.Output = "? dirty.go\x00"
+
+	fake := NewFakeCommander(dirty)
+	err := (&Runner{Conf: &configurations{WorkDir: "/work", NoModuleScope: true}, Commander: fake}).Validate()
+
+	if assert.NotNil(t, err) {
+		assert.True(t, strings.HasPrefix(err.Error(), "Uncommitted/untracked files:"))
+		assert.Contains(t, err.Error(), "dirty.go")
+
+		category, code := Categorize(err)
+		assert.Equal(t, DirtyError, category)
+		assert.Equal(t, 5, code)
+		assert.Equal(t, []string{"dirty.go"}, errorOf(err).Details["files"])
+	}
+	assert.Empty(t, fake.Remaining())
+}
+
+func TestRunner_Validate_TagNotFound(t *testing.T) {
+	fake := NewFakeCommander(gitStatusCall, CommandCall{Name: "git", Args: []string{"for-each-ref", "refs/tags/v2.0", "--format='%(objectname)'"}})
+	err := (&Runner{Conf: &configurations{WorkDir: "/work", Tag: "v2.0", NoModuleScope: true}, Commander: fake}).Validate()
+
+	assert.EqualError(t, err, "tag 'v2.0' is not found")
+	assert.Empty(t, fake.Remaining())
+
+	category, _ := Categorize(err)
+	assert.Equal(t, ReferenceError, category)
+}
+
+func TestRunner_Validate_InvalidCommit(t *testing.T) {
+	fake := NewFakeCommander(gitStatusCall, CommandCall{
+		Name:     "git",
+		Args:     []string{"show", "-s", "--format=%H", "abc"},
+		ExitCode: 128,
+		Stderr:   "fatal: bad object abc",
+	})
+	err := (&Runner{Conf: &configurations{WorkDir: "/work", Commit: "abc", NoModuleScope: true}, Commander: fake}).Validate()
+
+	assert.EqualError(t, err, "invalid commit specified")
+	assert.Empty(t, fake.Remaining())
+
+	category, _ := Categorize(err)
+	assert.Equal(t, ReferenceError, category)
+}
+
+func TestRunner_Validate_GitFailure(t *testing.T) {
+	failure := gitStatusCall
+	failure.ExitCode, failure.Stderr = 128, "fatal: not a git repository (or any of the parent directories): .git"
+
+	fake := NewFakeCommander(failure)
+	err := (&Runner{Conf: &configurations{WorkDir: "/work", NoModuleScope: true}, Commander: fake}).Validate()
+
+	assert.EqualError(t, err, "exit status 128: fatal: not a git repository (or any of the parent directories): .git")
+	assert.Equal(t, 128, ExitCode(err))
+
+	category, code := Categorize(err)
+	assert.Equal(t, GitError, category)
+	assert.Equal(t, 4, code)
+}
+
+func TestRunner_Run_PolicyOnly(t *testing.T) {
+	fake := NewFakeCommander(gitStatusCall, CommandCall{
+		Name:   "git",
+		Args:   []string{"for-each-ref", "refs/tags/v1.2.0", "--format='%(objectname)'"},
+		Output: "'0123456789abcdef0123456789abcdef01234567'",
+	}, CommandCall{
+		Name:   "git",
+		Args:   []string{"rev-parse", "--verify", "--quiet", "refs/tags/v1.2.0^{commit}"},
+		Output: "0123456789abcdef0123456789abcdef01234567",
+	}, CommandCall{
+		Name:   "git",
+		Args:   []string{"rev-parse", "--symbolic-full-name", "refs/tags/v1.2.0"},
+		Output: "refs/tags/v1.2.0",
+	})
+	conf := &configurations{
+		Command:         releaseCommand,
+		WorkDir:         "/work",
+		Tag:             "v1.2.0",
+		IgnoreTagPrefix: "v",
+		NoModuleScope:   true,
+		PolicyOnly:      true,
+	}
+
+	out, err := (&Runner{Conf: conf, Commander: fake}).Run()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "release '1.2.0' from tag 'v1.2.0' complies with the policy", out)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", conf.Commit)
+	assert.Empty(t, fake.Remaining())
+}
+
+func TestRunner_Run_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(dir, ".git"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &configurations{Command: releaseCommand, WorkDir: dir, NoModuleScope: true, Context: ctx}
+
+	_, err := (&Runner{Conf: conf, Commander: NewFakeCommander(gitStatusCall)}).Run()
+
+	assert.EqualError(t, err, "context canceled: ")
+}
+
+func TestRunner_Run_NotRepository(t *testing.T) {
+	dir := t.TempDir()
+	conf := &configurations{Command: releaseCommand, WorkDir: dir, NoModuleScope: true}
+	fake := NewFakeCommander()
+
+	_, err := (&Runner{Conf: conf, Commander: fake}).Run()
+
+	assert.EqualError(t, err, "not a git repository (or any of the parent directories): "+dir)
+	assert.Empty(t, fake.Unexpected())
+
+	category, _ := Categorize(err)
+	assert.Equal(t, GitError, category)
+}
+
+func TestRunner_LintCommits_NoRules(t *testing.T) {
+	conf := &configurations{Command: lintCommitsCommand, WorkDir: "/work", ConfigFile: "/work/.rego"}
+
+	_, err := (&Runner{Conf: conf, Commander: NewFakeCommander()}).Run()
+
+	assert.EqualError(t, err, "no commit rules are found in the configuration file '/work/.rego'")
+}
+
+// nativeFixture creates a repository of two commits, the first one is tagged 'v1.0', it returns its directory.
+func nativeFixture(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "test_rego_native_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	// the fixed identity and dates keep the commit hash, so a regenerated golden file only differs by the actual changes.
+	date := goldenTime.Format(time.RFC3339)
+	git := NewCommand(CommandSpec{Name: "git", WorkDir: dir, Env: []string{
+		"GIT_AUTHOR_NAME=Rego", "GIT_AUTHOR_EMAIL=rego@example.com", "GIT_AUTHOR_DATE=" + date,
+		"GIT_COMMITTER_NAME=Rego", "GIT_COMMITTER_EMAIL=rego@example.com", "GIT_COMMITTER_DATE=" + date,
+	}})
+
+	for _, args := range [][]string{
+		{"init", "--initial-branch", "master"},
+		{"config", "commit.gpgsign", "false"},
+		{"commit", "--allow-empty", "-n", "-m", "Initial commit"},
+		{"tag", "v1.0"},
+		{"commit", "--allow-empty", "-n", "-m", "Second commit"},
+	} {
+		if _, err = git.Execute(args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestRunner_Validate_NativeBackend(t *testing.T) {
+	fake := NewFakeCommander()
+	conf := &configurations{WorkDir: nativeFixture(t), Ref: "v1.0", IgnoreTagPrefix: "v", GitBackend: nativeGitBackend}
+
+	var out bytes.Buffer
+
+	err := (&Runner{Conf: conf, Commander: fake, Logger: NewLogger(&out, textLogFormat, slog.LevelDebug, nil)}).Validate()
+
+	assert.Nil(t, err)
+	assert.Empty(t, fake.Unexpected())
+	assert.Equal(t, "1.0", conf.Release)
+	assert.Equal(t, TagReference, conf.Reference.Kind)
+	assert.Contains(t, out.String(), "target commit commit="+conf.Commit+" description=v1.0")
+}
+
+func TestRunner_Validate_AnnotatedTag(t *testing.T) {
+	dir := nativeFixture(t)
+
+	if _, err := NewNamedCommand("git", dir).Execute("tag", "-a", "v2.0", "-m", "Release 2.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	commit, err := NewNamedCommand("git", dir).Execute("rev-parse", "HEAD")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backend := range []string{execGitBackend, nativeGitBackend} {
+		conf := &configurations{WorkDir: dir, Tag: "v2.0", IgnoreTagPrefix: "v", NoModuleScope: true, GitBackend: backend}
+
+		err = (&Runner{Conf: conf}).Validate()
+
+		assert.Nil(t, err, backend)
+		assert.Equal(t, commit, conf.Commit, backend)
+		assert.Equal(t, Reference{Name: "v2.0", Kind: TagReference, Commit: commit}, conf.Reference, backend)
+		assert.Equal(t, "2.0", conf.Release, backend)
+	}
+}
+
+func TestRunner_Validate_Ignored(t *testing.T) {
+	dir := nativeFixture(t)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "CHANGELOG.md"), []byte("# Changes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := NewIgnoreRules("CHANGELOG.md")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, backend := range []string{execGitBackend, nativeGitBackend} {
+		conf := &configurations{WorkDir: dir, Ref: "v1.0", IgnoreTagPrefix: "v", NoModuleScope: true, Ignore: rules, GitBackend: backend}
+
+		err = (&Runner{Conf: conf}).Validate()
+
+		assert.Nil(t, err, backend)
+		assert.Equal(t, []string{"CHANGELOG.md"}, conf.TreeState.Ignored, backend)
+		assert.Equal(t, ignoredTreeState, conf.TreeState.String(), backend)
+		assert.Equal(t, "1.0", conf.Release, backend)
+	}
+}
+
+func TestRunner_Release_NativeBackendNotCheckedOut(t *testing.T) {
+	dir := nativeFixture(t)
+	commit, err := (&NativeGit{WorkDir: dir}).GetTagCommit("v1.0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := NewFakeCommander()
+	conf := &configurations{WorkDir: dir, Commit: commit, GitBackend: nativeGitBackend}
+
+	err = (&Runner{Conf: conf, Commander: fake}).Release()
+
+	assert.EqualError(t, err, fmt.Sprintf("commit '%v' is not checked out, it can not be checked out with the 'native' git backend", commit))
+	assert.Empty(t, fake.Unexpected())
+}
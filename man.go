@@ -0,0 +1,122 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	getopt "github.com/kesselborn/go-getopt"
+)
+
+// roffEscape escapes the specified text to be used in a roff document.
+func roffEscape(s string) string {
+	s = strings.NewReplacer(`\`, `\e`, `-`, `\-`).Replace(s)
+
+	// a line starting with a dot or an apostrophe would be a request.
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+
+	return s
+}
+
+// manPage returns the man page of rego in the roff format, documenting the commands, the options along with their
+// environment variables and the exit codes.
+func manPage(parser getopt.Options) string {
+	var b strings.Builder
+
+	version := ReleaseVersion
+
+	if len(version) == 0 {
+		version = "SNAPSHOT"
+	}
+
+	fmt.Fprintf(&b, ".TH REGO 1 \"\" \"rego %v\" \"User Commands\"\n", roffEscape(version))
+	b.WriteString(".SH NAME\nrego \\- builds a binary release of a Go module embedding its release information\n")
+	b.WriteString(".SH SYNOPSIS\n.B rego\n[\\fIOPTIONS\\fR] [\\fICOMMAND\\fR] [\\fIARGUMENTS\\fR]\n")
+	fmt.Fprintf(&b, ".SH DESCRIPTION\n%v\n", roffEscape(string(parser.Description)))
+
+	b.WriteString(".SH COMMANDS\n")
+
+	for _, c := range []struct{ name, description string }{
+		{releaseCommand, "Validates the working tree and builds the release, which is the default command."},
+		{lintCommitsCommand, "Only checks the messages of the commits since the previous tag against the commit rules of the configuration file."},
+		{doctorCommand, "Diagnoses the environment, e.g. a missing git, a Go installation mismatch or undeclared release variables, and prints how to fix the problems found."},
+		{initCommand, "Declares the release variables along with a '-version' flag helper in the '--package' package or every main package, and writes a starter configuration file matching the branching model and the tags of the repository, nothing is overwritten."},
+		{completionCommand + " bash|zsh|fish", "Prints the completion script of the specified shell, e.g. 'source <(rego completion bash)'."},
+		{manCommand, "Prints this man page."},
+	} {
+		fmt.Fprintf(&b, ".TP\n.B %v\n%v\n", roffEscape(c.name), roffEscape(c.description))
+	}
+
+	b.WriteString(".SH OPTIONS\n")
+
+	var env []getopt.Option
+
+	for _, o := range parser.Definitions {
+		if o.Flags&getopt.IsArg > 0 || !o.HasLongOpt() {
+			continue
+		}
+
+		var names []string
+
+		if o.HasShortOpt() {
+			names = append(names, `\fB\-`+o.ShortOpt()+`\fR`)
+		}
+
+		names = append(names, `\fB\-\-`+roffEscape(o.LongOpt())+`\fR`)
+		heading := strings.Join(names, ", ")
+
+		if o.Flags&getopt.Flag == 0 {
+			heading += ` \fI` + strings.ToUpper(strings.Replace(o.Key(), "-", "_", -1)) + `\fR`
+		}
+
+		description := o.Description
+
+		if values, found := optionValues[o.Key()]; found {
+			description += ", one of: " + strings.Join(values, ", ")
+		}
+
+		fmt.Fprintf(&b, ".TP\n%v\n%v.\n", heading, roffEscape(strings.TrimSuffix(description, ".")))
+
+		if o.HasEnvVar() {
+			env = append(env, o)
+		}
+	}
+
+	fmt.Fprintf(&b, ".TP\n\\fB\\-h\\fR, \\fB\\-\\-help\\fR\nPrints the usage (\\-h) or the detailed help (\\-\\-help).\n")
+
+	b.WriteString(".SH ENVIRONMENT\n")
+
+	sort.Slice(env, func(i, j int) bool { return env[i].EnvVar() < env[j].EnvVar() })
+
+	for _, o := range env {
+		fmt.Fprintf(&b, ".TP\n.B %v\nSame as \\fB\\-\\-%v\\fR.\n", roffEscape(o.EnvVar()), roffEscape(o.LongOpt()))
+	}
+
+	b.WriteString(".SH EXIT STATUS\n.TP\n.B 0\nThe run has succeeded.\n")
+
+	for _, category := range ErrorCategories() {
+		fmt.Fprintf(&b, ".TP\n.B %v\n%v: %v.\n", exitCodes[category], category, roffEscape(errorDescriptions[category]))
+	}
+
+	b.WriteString(".SH SEE ALSO\n.BR git (1),\n.BR go (1)\n")
+
+	return b.String()
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoffEscape(t *testing.T) {
+	assert.Equal(t, `\-\-tag`, roffEscape("--tag"))
+	assert.Equal(t, `C:\eGo`, roffEscape(`C:\Go`))
+	assert.Equal(t, `\&.rego is read`, roffEscape(".rego is read"))
+	assert.Equal(t, `\&'quoted'`, roffEscape("'quoted'"))
+}
+
+func TestManPage(t *testing.T) {
+	page := manPage(optionsParser("/work"))
+
+	for _, section := range []string{".SH NAME", ".SH SYNOPSIS", ".SH DESCRIPTION", ".SH COMMANDS", ".SH OPTIONS", ".SH ENVIRONMENT", ".SH EXIT STATUS"} {
+		assert.Contains(t, page, "\n"+section+"\n")
+	}
+
+	assert.Contains(t, page, ".TP\n\\fB\\-t\\fR, \\fB\\-\\-tag\\fR \\fITAG\\fR\nThe tag name of where")
+	assert.Contains(t, page, ".TP\n\\fB\\-\\-allow\\-dirty\\fR\n")
+	assert.Contains(t, page, "one of: error, warn, info, debug, trace.\n")
+	assert.Contains(t, page, ".TP\n.B REGO_TAG\nSame as \\fB\\-\\-tag\\fR.\n")
+	assert.Contains(t, page, ".TP\n.B 6\nreference: the branch, tag, commit or reference is not found or can not be released.\n")
+	assert.Contains(t, page, ".TP\n.B 126\ninternal: any other failure.\n")
+	assert.NotContains(t, page, completeCommand)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// Manifest describes a generated release, it is written in JSON format.
+type Manifest struct {
+	// Commit is the git commit hash the release is built from.
+	Commit string `json:"commit"`
+	// Release is the release version string.
+	Release string `json:"release"`
+	// Ref is the reference the release commit is resolved from.
+	Ref Reference `json:"ref"`
+	// Timestamp is the build timestamp formatted in RFC3339.
+	Timestamp string `json:"timestamp"`
+	// TreeState is either 'clean', 'ignored' if the release is built with uncommitted files matching the ignore rules only,
+	// or 'dirty' if the release is built with uncommitted changes.
+	TreeState string `json:"treeState"`
+	// IgnoredFiles is the list of the uncommitted files matching the ignore rules the release is built with.
+	IgnoredFiles []string `json:"ignoredFiles,omitempty"`
+	// DirtyFiles is the list of the uncommitted files the release is built with.
+	DirtyFiles []string `json:"dirtyFiles,omitempty"`
+	// DiffHash is the SHA-256 hex digest of the uncommitted changes the release is built with.
+	DiffHash string `json:"diffHash,omitempty"`
+	// Module describes the Go module the release is scoped to if it lives in a subdirectory of its repository.
+	Module *ManifestModule `json:"module,omitempty"`
+	// Signatures holds the verified signatures of the target commit and the released tag.
+	Signatures *VerifiedSignatures `json:"signatures,omitempty"`
+	// Submodules is the list of the submodules checked out while building the release.
+	Submodules []Submodule `json:"submodules,omitempty"`
+	// Gates is the list of the results of the quality gates run before building.
+	Gates []ManifestGate `json:"gates,omitempty"`
+	// Binaries is the list of the built binaries.
+	Binaries []ManifestBinary `json:"binaries"`
+}
+
+// ManifestGate describes the result of a quality gate.
+type ManifestGate struct {
+	// Name is the kind of the gate.
+	Name string `json:"name"`
+	// Status is either 'passed', 'failed' or 'failed (advisory)'.
+	Status string `json:"status"`
+	// Duration is the time the gate took to run (e.g. '1.25s').
+	Duration string `json:"duration"`
+}
+
+// ManifestModule describes the Go module a release is scoped to.
+type ManifestModule struct {
+	// Path is the module directory relative to the repository root.
+	Path string `json:"path"`
+	// Commit is the most recent commit touching the module directory or its locally replaced dependencies.
+	Commit string `json:"commit"`
+}
+
+// ManifestBinary describes a single binary of a generated release.
+type ManifestBinary struct {
+	// Package is the main package import path.
+	Package string `json:"package,omitempty"`
+	// Name is the binary name if overridden.
+	Name string `json:"name,omitempty"`
+	// GoVersion is the version of the Go toolchain the binary is built with.
+	GoVersion string `json:"goVersion"`
+	// Target is the operating system and architecture pair the binary is built for if cross-built.
+	Target string `json:"target,omitempty"`
+	// Variant is the variant name of the binary if any.
+	Variant string `json:"variant,omitempty"`
+}
+
+// Write writes this manifest in JSON format to the file of the specified path.
+func (m *Manifest) Write(path string) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
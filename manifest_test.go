@@ -0,0 +1,60 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_rego_manifest_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	m := &Manifest{
+		Commit:     "abc",
+		Release:    "1.0+dirty",
+		Timestamp:  "2017-09-04T19:07:57Z",
+		TreeState:  "dirty",
+		DirtyFiles: []string{"main.go"},
+		DiffHash:   "def",
+		Binaries:   []ManifestBinary{{Package: "project", GoVersion: "go1.22.3", Variant: "debug"}},
+	}
+
+	path := filepath.Join(dir, "manifest.json")
+
+	assert.Nil(t, m.Write(path))
+
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+
+	var read Manifest
+	assert.Nil(t, json.Unmarshal(content, &read))
+	assert.Equal(t, *m, read)
+
+	assert.NotNil(t, m.Write(filepath.Join(dir, "nonexistent", "manifest.json")))
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ModuleScope restricts the release to a Go module living in a subdirectory of its git repository (e.g. in a monorepo).
+type ModuleScope struct {
+	// Dir is the absolute path of the module root directory.
+	Dir string
+	// Prefix is the slash separated path of the module root relative to the repository root, empty if they are the same.
+	Prefix string
+	// Paths is the list of the module root and its locally replaced dependencies directories relative to the working
+	// directory, the replacements outside the repository are left out.
+	Paths []string
+}
+
+// Scoped returns true if the module lives in a subdirectory of its repository.
+func (m ModuleScope) Scoped() bool {
+	return len(m.Prefix) > 0
+}
+
+// TagNames returns the names to look up for the specified tag, which is the tag prefixed with the module path
+// (e.g. 'services/api/v1.3.0') followed by the tag itself.
+func (m ModuleScope) TagNames(tag string) []string {
+	if !m.Scoped() || strings.HasPrefix(tag, m.Prefix+"/") {
+		return []string{tag}
+	}
+	return []string{m.Prefix + "/" + tag, tag}
+}
+
+// TrimTag returns the specified tag without the module path prefix.
+func (m ModuleScope) TrimTag(tag string) string {
+	if !m.Scoped() {
+		return tag
+	}
+	return strings.TrimPrefix(tag, m.Prefix+"/")
+}
+
+// NewModuleScope finds the module root, which is the nearest directory containing a 'go.mod' file starting from
+// the working directory up to the repository root, and returns its scope, the scope is empty if there is no module
+// or if its root is the repository root.
+func NewModuleScope(repo Repository, workDir string) (ModuleScope, error) {
+	var scope ModuleScope
+	var top string
+	var err error
+
+	if top, err = repo.TopLevel(); err != nil {
+		return scope, err
+	} else if workDir, err = filepath.Abs(workDir); err != nil {
+		return scope, err
+	} else if top, err = filepath.EvalSymlinks(top); err != nil {
+		return scope, err
+	} else if workDir, err = filepath.EvalSymlinks(workDir); err != nil {
+		return scope, err
+	}
+
+	for dir := workDir; within(top, dir); dir = filepath.Dir(dir) {
+		if _, e := os.Stat(filepath.Join(dir, "go.mod")); e == nil {
+			scope.Dir = dir
+			break
+		} else if dir == top {
+			break
+		}
+	}
+
+	if len(scope.Dir) == 0 || scope.Dir == top {
+		return ModuleScope{}, nil
+	}
+
+	var rel string
+	var replaced []string
+
+	if rel, err = filepath.Rel(top, scope.Dir); err != nil {
+		return scope, err
+	}
+
+	scope.Prefix = filepath.ToSlash(rel)
+
+	if replaced, err = readLocalReplacements(scope.Dir); err != nil {
+		return scope, err
+	}
+
+	for _, dir := range append([]string{scope.Dir}, replaced...) {
+		// git does not track the replacements outside the repository, so they are out of the scope.
+		if !within(top, dir) {
+			continue
+		} else if rel, err = filepath.Rel(workDir, dir); err != nil {
+			return scope, err
+		}
+		scope.Paths = append(scope.Paths, rel)
+	}
+
+	return scope, nil
+}
+
+// within returns true if the specified path is the specified root directory or any path under it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// readLocalReplacements returns the absolute paths of the local directories the dependencies of the module in the
+// specified directory are replaced with through its 'go.mod' 'replace' directives.
+func readLocalReplacements(dir string) ([]string, error) {
+	var dirs []string
+
+	f, err := os.Open(filepath.Join(dir, "go.mod"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	block := false
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.SplitN(scanner.Text(), "//", 2)[0])
+
+		switch {
+		case line == "replace (":
+			block = true
+			continue
+		case block && line == ")":
+			block = false
+			continue
+		case block:
+		case strings.HasPrefix(line, "replace "):
+			line = strings.TrimPrefix(line, "replace ")
+		default:
+			continue
+		}
+
+		parts := strings.SplitN(line, "=>", 2)
+
+		if len(parts) != 2 {
+			continue
+		}
+
+		fields := strings.Fields(parts[1])
+
+		if len(fields) != 1 {
+			continue // a module version replacement
+		}
+
+		target := fields[0]
+
+		if strings.HasPrefix(target, "./") || strings.HasPrefix(target, "../") || filepath.IsAbs(target) {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(dir, target)
+			}
+			dirs = append(dirs, filepath.Clean(target))
+		}
+	}
+
+	return dirs, scanner.Err()
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const moduleGoMod = `module example.com/services/api
+
+go 1.21
+
+require example.com/lib v0.0.0
+
+replace example.com/lib => ../../lib // local library
+
+replace (
+	example.com/other v1.0.0 => example.com/fork v1.0.1
+	example.com/shared => ./../../shared
+)
+`
+
+type ModuleTestSuite struct {
+	suite.Suite
+	root string
+	git  *Git
+}
+
+func (suite *ModuleTestSuite) SetupTest() {
+	var err error
+
+	if suite.root, err = ioutil.TempDir("", "test_rego_module_"); err != nil {
+		suite.Fail("failed to create temporary directory before test setup", err.Error())
+		return
+	}
+
+	if suite.root, err = filepath.EvalSymlinks(suite.root); err != nil {
+		suite.Fail("failed to resolve temporary directory before test setup", err.Error())
+		return
+	}
+
+	api := filepath.Join(suite.root, "services", "api")
+
+	for _, dir := range []string{filepath.Join(api, "cmd"), filepath.Join(suite.root, "lib"), filepath.Join(suite.root, "shared"), filepath.Join(suite.root, "web")} {
+		if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+			suite.Fail("failed to create directory before test setup", err.Error())
+		}
+	}
+
+	for file, content := range map[string]string{
+		filepath.Join(api, "go.mod"):                 moduleGoMod,
+		filepath.Join(suite.root, "lib", "lib.go"):   "package lib\n",
+		filepath.Join(suite.root, "web", "index.js"): "\n",
+	} {
+		if err = ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			suite.Fail("failed to write file before test setup", err.Error())
+		}
+	}
+
+	git := NewNamedCommand("git", suite.root)
+	git.Execute("init")
+	git.Execute("config", "commit.gpgsign", "false")
+
+	if _, err = git.Execute("add", "."); err != nil {
+		suite.Fail("failed to add files before test setup", err.Error())
+	}
+
+	if _, err = git.Execute("commit", "-n", "-m", "'Initial commit'"); err != nil {
+		suite.Fail("failed to commit 'Initial commit' before test setup", err.Error())
+	}
+
+	suite.git = &Git{WorkDir: filepath.Join(api, "cmd")}
+}
+
+func (suite *ModuleTestSuite) TearDownTest() {
+	if len(suite.root) > 0 {
+		if err := os.RemoveAll(suite.root); err != nil {
+			suite.Fail("failed to remove temporary directory after test teardown", err.Error())
+		}
+	}
+}
+
+func TestModuleTestSuite(t *testing.T) {
+	suite.Run(t, new(ModuleTestSuite))
+}
+
+func (suite *ModuleTestSuite) TestNewModuleScope_Scoped() {
+	scope, err := NewModuleScope(suite.git, suite.git.WorkDir)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), ModuleScope{
+		Dir:    filepath.Join(suite.root, "services", "api"),
+		Prefix: "services/api",
+		Paths:  []string{"..", "../../../lib", "../../../shared"},
+	}, scope)
+	assert.True(suite.T(), scope.Scoped())
+}
+
+func (suite *ModuleTestSuite) TestNewModuleScope_Native() {
+	scope, err := NewModuleScope(&NativeGit{WorkDir: suite.git.WorkDir}, suite.git.WorkDir)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), "services/api", scope.Prefix)
+	assert.Equal(suite.T(), []string{"..", "../../../lib", "../../../shared"}, scope.Paths)
+}
+
+func (suite *ModuleTestSuite) TestNewModuleScope_NotScoped() {
+	suite.git.WorkDir = filepath.Join(suite.root, "web")
+
+	scope, err := NewModuleScope(suite.git, suite.git.WorkDir)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), ModuleScope{}, scope)
+	assert.False(suite.T(), scope.Scoped())
+}
+
+func (suite *ModuleTestSuite) TestNewModuleScope_Failure() {
+	suite.git.WorkDir = os.TempDir()
+
+	_, err := NewModuleScope(suite.git, suite.git.WorkDir)
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *ModuleTestSuite) TestGit_Status_Scoped() {
+	scope, _ := NewModuleScope(suite.git, suite.git.WorkDir)
+
+	for _, file := range []string{"web/index.js", "lib/lib.go", "services/api/new.go"} {
+		if err := ioutil.WriteFile(filepath.Join(suite.root, file), []byte("changed"), 0600); err != nil {
+			suite.Fail("failed to change file", err.Error())
+		}
+	}
+
+	status, err := suite.git.Status(scope.Paths...)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), Status{
+		{Path: "lib/lib.go", Staged: '.', Unstaged: 'M'},
+		{Path: "services/api/new.go", Untracked: true},
+	}, status)
+
+	state, err := suite.git.TreeState(scope.Paths...)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"lib/lib.go", "services/api/new.go"}, state.Files)
+}
+
+func (suite *ModuleTestSuite) TestGit_LastCommit_Scoped() {
+	git := NewNamedCommand("git", suite.root)
+	first, _ := git.Execute("rev-parse", "HEAD")
+
+	if err := ioutil.WriteFile(filepath.Join(suite.root, "web", "index.js"), []byte("changed"), 0600); err != nil {
+		suite.Fail("failed to change file", err.Error())
+	}
+
+	if _, err := git.Execute("commit", "-n", "-a", "-m", "'Change web'"); err != nil {
+		suite.Fail("failed to commit", err.Error())
+	}
+
+	head, _ := git.Execute("rev-parse", "HEAD")
+	scope, _ := NewModuleScope(suite.git, suite.git.WorkDir)
+
+	commit, err := suite.git.LastCommit(head, scope.Paths...)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), first, commit)
+
+	commit, err = suite.git.LastCommit(head)
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), head, commit)
+
+	_, err = suite.git.LastCommit(head, "nonexistent")
+	assert.NotNil(suite.T(), err)
+}
+
+func TestModuleScope_Tags(t *testing.T) {
+	scope := ModuleScope{Prefix: "services/api"}
+
+	assert.Equal(t, []string{"services/api/v1.3.0", "v1.3.0"}, scope.TagNames("v1.3.0"))
+	assert.Equal(t, []string{"services/api/v1.3.0"}, scope.TagNames("services/api/v1.3.0"))
+	assert.Equal(t, "v1.3.0", scope.TrimTag("services/api/v1.3.0"))
+	assert.Equal(t, []string{"v1.3.0"}, ModuleScope{}.TagNames("v1.3.0"))
+	assert.Equal(t, "services/api/v1.3.0", ModuleScope{}.TrimTag("services/api/v1.3.0"))
+}
+
+func (suite *ModuleTestSuite) TestNewModuleScope_Sibling() {
+	sibling := suite.root + "-sibling"
+
+	if err := os.MkdirAll(sibling, os.ModePerm); err != nil {
+		suite.Fail("failed to create directory", err.Error())
+	}
+
+	defer os.RemoveAll(sibling)
+
+	if err := ioutil.WriteFile(filepath.Join(sibling, "go.mod"), []byte("module example.com/sibling\n"), 0600); err != nil {
+		suite.Fail("failed to write file", err.Error())
+	}
+
+	scope, err := NewModuleScope(suite.git, sibling)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), ModuleScope{}, scope)
+}
+
+func (suite *ModuleTestSuite) TestNewModuleScope_ReplacementOutsideRepository() {
+	goMod := filepath.Join(suite.root, "services", "api", "go.mod")
+
+	if err := ioutil.WriteFile(goMod, []byte(moduleGoMod+"\nreplace example.com/far => "+os.TempDir()+"\nreplace example.com/near => ../../../outside\n"), 0600); err != nil {
+		suite.Fail("failed to write file", err.Error())
+	}
+
+	scope, err := NewModuleScope(suite.git, suite.git.WorkDir)
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), []string{"..", "../../../lib", "../../../shared"}, scope.Paths)
+
+	_, err = suite.git.Status(scope.Paths...)
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *ModuleTestSuite) TestGit_Commits_Scoped() {
+	git := NewNamedCommand("git", suite.root)
+
+	for _, file := range []string{"web/index.js", "services/api/api.go", "lib/lib.go"} {
+		if err := ioutil.WriteFile(filepath.Join(suite.root, file), []byte("package changed\n"), 0600); err != nil {
+			suite.Fail("failed to change file", err.Error())
+		}
+
+		if _, err := git.Execute("add", file); err != nil {
+			suite.Fail("failed to add file", err.Error())
+		}
+
+		if _, err := git.Execute("commit", "-n", "-m", "Change "+file); err != nil {
+			suite.Fail("failed to commit", err.Error())
+		}
+	}
+
+	scope, _ := NewModuleScope(suite.git, suite.git.WorkDir)
+
+	commits, err := suite.git.Commits("HEAD~3", "HEAD", scope.Paths...)
+	assert.Nil(suite.T(), err)
+
+	var messages []string
+
+	for _, c := range commits {
+		messages = append(messages, c.Message)
+	}
+
+	assert.Equal(suite.T(), []string{"Change lib/lib.go", "Change services/api/api.go"}, messages)
+}
@@ -0,0 +1,1300 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NativeGit is a read-only Repository reading the '.git' directory directly instead of running the git binary,
+// for environments where git is not available. It reads the loose and packed references and objects, including
+// the deltified ones, and the index, but does not support the clean/smudge filters, the sparse checkouts and the
+// split indexes, and does not detect renames.
+type NativeGit struct {
+	// WorkDir is the working directory, the repository is looked up from it through its parent directories.
+	WorkDir string
+}
+
+// nativeRepository is an opened repository, it is opened on every call so that no state is kept between calls.
+type nativeRepository struct {
+	top       string
+	gitDir    string
+	commonDir string
+	objects   *objectStore
+	packed    map[string]string
+}
+
+// open looks up the repository of the working directory, it returns an error if it is not found or on failure.
+func (g *NativeGit) open() (*nativeRepository, error) {
+	r, err := g.discover()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r, r.init()
+}
+
+// discover looks up the top level and the git directory of the repository of the working directory without reading
+// it, it returns an error if it is not found.
+func (g *NativeGit) discover() (*nativeRepository, error) {
+	dir, err := filepath.Abs(g.WorkDir)
+
+	if err != nil {
+		return nil, err
+	} else if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return nil, err
+	}
+
+	for {
+		dotGit := filepath.Join(dir, ".git")
+
+		if info, e := os.Stat(dotGit); e == nil {
+			var r *nativeRepository
+
+			if info.IsDir() {
+				r = &nativeRepository{top: dir, gitDir: dotGit}
+			} else if r, err = openGitFile(dir, dotGit); err != nil {
+				return nil, err
+			}
+
+			return r, nil
+		}
+
+		parent := filepath.Dir(dir)
+
+		if parent == dir {
+			return nil, newError(GitError, "not a git repository (or any of the parent directories): %v", g.WorkDir)
+		}
+
+		dir = parent
+	}
+}
+
+// openGitFile opens the repository whose git directory is pointed to by a '.git' file, e.g. of a linked worktree
+// or a submodule.
+func openGitFile(top, file string) (*nativeRepository, error) {
+	content, err := ioutil.ReadFile(file)
+
+	if err != nil {
+		return nil, err
+	}
+
+	line := strings.TrimSpace(string(content))
+
+	if !strings.HasPrefix(line, "gitdir: ") {
+		return nil, fmt.Errorf("invalid gitfile format: %v", file)
+	}
+
+	gitDir := strings.TrimPrefix(line, "gitdir: ")
+
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(top, gitDir)
+	}
+
+	return &nativeRepository{top: top, gitDir: filepath.Clean(gitDir)}, nil
+}
+
+func (r *nativeRepository) init() error {
+	var err error
+
+	r.commonDir = r.gitDir
+
+	if content, e := ioutil.ReadFile(filepath.Join(r.gitDir, "commondir")); e == nil {
+		if common := strings.TrimSpace(string(content)); filepath.IsAbs(common) {
+			r.commonDir = common
+		} else {
+			r.commonDir = filepath.Join(r.gitDir, common)
+		}
+	}
+
+	if err = r.supported(); err != nil {
+		return err
+	} else if r.objects, err = newObjectStore(filepath.Join(r.commonDir, "objects")); err != nil {
+		return err
+	}
+
+	return r.readPackedRefs()
+}
+
+// supported returns an error if the repository uses a format this reader does not read: the 'sha256' object format,
+// the 'reftable' references storage or objects borrowed from alternate object directories.
+func (r *nativeRepository) supported() error {
+	if format := strings.ToLower(r.config("extensions", "objectformat")); len(format) > 0 && format != "sha1" {
+		return newError(GitError, "the '%v' object format of the repository is not supported by the '%v' git backend",
+			format, nativeGitBackend)
+	}
+
+	if storage := strings.ToLower(r.config("extensions", "refstorage")); len(storage) > 0 && storage != "files" {
+		return newError(GitError, "the '%v' references storage of the repository is not supported by the '%v' git backend",
+			storage, nativeGitBackend)
+	}
+
+	if _, err := os.Stat(filepath.Join(r.commonDir, "objects", "info", "alternates")); err == nil {
+		return newError(GitError, "the alternate object directories of the repository are not supported by the '%v' git backend",
+			nativeGitBackend)
+	}
+
+	return nil
+}
+
+// readPackedRefs reads the 'packed-refs' file, the peeled lines are not needed since the tags are peeled on demand.
+func (r *nativeRepository) readPackedRefs() error {
+	r.packed = make(map[string]string)
+
+	f, err := os.Open(filepath.Join(r.commonDir, "packed-refs"))
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if len(line) == 0 || line[0] == '#' || line[0] == '^' {
+			continue
+		}
+
+		if fields := strings.SplitN(line, " ", 2); len(fields) == 2 {
+			r.packed[fields[1]] = fields[0]
+		}
+	}
+
+	return scanner.Err()
+}
+
+// refPath returns the path of the loose reference of the specified full name, the pseudo references like 'HEAD'
+// belong to the worktree while the others are shared.
+func (r *nativeRepository) refPath(name string) string {
+	if strings.HasPrefix(name, "refs/") {
+		return filepath.Join(r.commonDir, filepath.FromSlash(name))
+	}
+	return filepath.Join(r.gitDir, name)
+}
+
+// readRef returns the content of the reference of the specified full name, either a hash or the target of a
+// symbolic reference, it returns false if the reference does not exist.
+func (r *nativeRepository) readRef(name string) (value string, symbolic bool, found bool, err error) {
+	var content []byte
+
+	if content, err = ioutil.ReadFile(r.refPath(name)); err == nil {
+		line := strings.TrimSpace(string(content))
+
+		if strings.HasPrefix(line, "ref: ") {
+			return strings.TrimPrefix(line, "ref: "), true, true, nil
+		}
+
+		return line, false, true, nil
+	} else if !os.IsNotExist(err) && !isDirError(err) {
+		return "", false, false, err
+	}
+
+	if hash, ok := r.packed[name]; ok {
+		return hash, false, true, nil
+	}
+
+	return "", false, false, nil
+}
+
+// isDirError returns true if the error is caused by reading a directory, e.g. 'refs/heads' as a reference.
+func isDirError(err error) bool {
+	if e, ok := err.(*os.PathError); ok {
+		if info, statErr := os.Stat(e.Path); statErr == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRef follows the specified reference through the symbolic references, it returns the hash it points to along
+// with the full name of the last reference, or false if it does not exist or is unborn.
+func (r *nativeRepository) resolveRef(name string) (hash string, full string, found bool, err error) {
+	full = name
+
+	for depth := 0; depth < 5; depth++ {
+		var value string
+		var symbolic bool
+
+		if value, symbolic, found, err = r.readRef(full); err != nil || !found {
+			return "", full, false, err
+		} else if !symbolic {
+			return value, full, true, nil
+		}
+
+		full = value
+	}
+
+	return "", full, false, fmt.Errorf("too deep symbolic reference '%v'", name)
+}
+
+// refs returns the references under the specified prefix (e.g. 'refs/tags/') mapped by full name to hash,
+// the symbolic references are skipped.
+func (r *nativeRepository) refs(prefix string) (map[string]string, error) {
+	result := make(map[string]string)
+
+	for name, hash := range r.packed {
+		if strings.HasPrefix(name, prefix) {
+			result[name] = hash
+		}
+	}
+
+	root := r.refPath(strings.TrimSuffix(prefix, "/"))
+
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		} else if info.IsDir() {
+			return nil
+		}
+
+		rel, e := filepath.Rel(r.commonDir, p)
+
+		if e != nil {
+			return e
+		}
+
+		name := filepath.ToSlash(rel)
+
+		if value, symbolic, found, e := r.readRef(name); e != nil {
+			return e
+		} else if found && !symbolic {
+			result[name] = value
+		} else if symbolic {
+			delete(result, name)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// dwimRules are the rules looking up a short reference name, in order, as documented in 'git help revisions'.
+var dwimRules = []string{"%v", "refs/%v", "refs/tags/%v", "refs/heads/%v", "refs/remotes/%v", "refs/remotes/%v/HEAD"}
+
+var pseudoRefPattern = regexp.MustCompile(`^[A-Z_]+$`)
+
+// dwim looks up the reference of the specified short name, it returns the hash it points to along with its full
+// name, following the symbolic references like 'HEAD' to the branch they point to.
+func (r *nativeRepository) dwim(name string) (hash string, full string, found bool, err error) {
+	for i, rule := range dwimRules {
+		ref := fmt.Sprintf(rule, name)
+
+		// only the pseudo references and the full names are looked up as they are.
+		if i == 0 && !pseudoRefPattern.MatchString(name) && !strings.HasPrefix(name, "refs/") {
+			continue
+		}
+
+		if hash, full, found, err = r.resolveRef(ref); err != nil || found {
+			return hash, full, found, err
+		}
+	}
+
+	return "", "", false, nil
+}
+
+var hexPattern = regexp.MustCompile(`^[0-9a-fA-F]{4,40}$`)
+
+// object returns the object of the specified hash, it returns an error if its type is not the expected one if any.
+func (r *nativeRepository) object(hash, kind string) (gitObject, error) {
+	obj, err := r.objects.read(hash)
+
+	if err != nil {
+		return obj, err
+	} else if len(kind) > 0 && obj.kind != kind {
+		return obj, fmt.Errorf("object '%v' is a %v, not a %v", hash, obj.kind, kind)
+	}
+
+	return obj, nil
+}
+
+// header returns the value of the first header of the specified name of a commit or a tag object.
+func header(data []byte, name string) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if len(line) == 0 {
+			break
+		} else if strings.HasPrefix(line, name+" ") {
+			return strings.TrimPrefix(line, name+" ")
+		}
+	}
+	return ""
+}
+
+// nativeCommit is the part of a commit object needed to walk the history.
+type nativeCommit struct {
+	tree    string
+	parents []string
+	time    int64
+}
+
+func (r *nativeRepository) commit(hash string) (nativeCommit, error) {
+	var c nativeCommit
+
+	obj, err := r.object(hash, commitObject)
+
+	if err != nil {
+		return c, err
+	}
+
+	for _, line := range strings.Split(string(obj.data), "\n") {
+		if len(line) == 0 {
+			break
+		}
+
+		switch fields := strings.Fields(line); fields[0] {
+		case "tree":
+			c.tree = fields[1]
+		case "parent":
+			c.parents = append(c.parents, fields[1])
+		case "committer":
+			if len(fields) >= 3 {
+				c.time, _ = strconv.ParseInt(fields[len(fields)-2], 10, 64)
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// peel follows the tags the specified object points to, it returns the first object that is not a tag along with
+// its type.
+func (r *nativeRepository) peel(hash string) (string, string, error) {
+	for depth := 0; depth < 100; depth++ {
+		obj, err := r.object(hash, "")
+
+		if err != nil {
+			return "", "", err
+		} else if obj.kind != tagObject {
+			return hash, obj.kind, nil
+		}
+
+		hash = header(obj.data, "object")
+	}
+
+	return "", "", fmt.Errorf("too deep tag chain at '%v'", hash)
+}
+
+// peelCommit follows the tags the specified object points to, it returns an error if it is not a commit.
+func (r *nativeRepository) peelCommit(hash string) (string, error) {
+	peeled, kind, err := r.peel(hash)
+
+	if err != nil {
+		return "", err
+	} else if kind != commitObject {
+		return "", fmt.Errorf("object '%v' is a %v, not a commit", hash, kind)
+	}
+
+	return peeled, nil
+}
+
+var revisionSuffixPattern = regexp.MustCompile(`^(?:~[0-9]*|\^\{[a-z]*\}|\^[0-9]*)`)
+
+// resolve resolves the specified revision expression, made of a reference name or a possibly abbreviated hash
+// followed by any of the '~<n>', '^<n>', '^{}' and '^{commit}' suffixes, it returns the hash of the object it refers to
+// along with the full name of the reference it is based on if it has no suffix, or false if it does not resolve.
+func (r *nativeRepository) resolve(expr string) (hash string, full string, found bool, err error) {
+	base, suffixes := expr, ""
+
+	if i := strings.IndexAny(expr, "~^"); i >= 0 {
+		base, suffixes = expr[:i], expr[i:]
+	}
+
+	if base == "@" {
+		base = "HEAD"
+	}
+
+	if len(base) == 40 && hexPattern.MatchString(base) {
+		if hash = strings.ToLower(base); !r.objects.has(hash) {
+			return "", "", false, nil
+		}
+	} else if hash, full, found, err = r.dwim(base); err != nil {
+		return "", "", false, err
+	} else if !found {
+		full = ""
+
+		if len(base) == 0 || !hexPattern.MatchString(base) {
+			return "", "", false, nil
+		}
+
+		var hashes []string
+
+		if hashes, err = r.objects.expand(strings.ToLower(base)); err != nil {
+			return "", "", false, err
+		} else if len(hashes) > 1 {
+			return "", "", false, fmt.Errorf("short object ID %v is ambiguous", base)
+		} else if len(hashes) == 0 {
+			return "", "", false, nil
+		}
+
+		hash = hashes[0]
+	}
+
+	if len(suffixes) > 0 {
+		full = ""
+	}
+
+	for len(suffixes) > 0 {
+		suffix := revisionSuffixPattern.FindString(suffixes)
+
+		if len(suffix) == 0 {
+			return "", "", false, fmt.Errorf("unsupported revision expression '%v'", expr)
+		}
+
+		suffixes = suffixes[len(suffix):]
+
+		if hash, found, err = r.applySuffix(hash, suffix); err != nil || !found {
+			return "", "", false, err
+		}
+	}
+
+	return hash, full, true, nil
+}
+
+// applySuffix applies a single revision suffix to the specified object, it returns false if it does not resolve.
+func (r *nativeRepository) applySuffix(hash, suffix string) (string, bool, error) {
+	switch suffix {
+	case "^{}":
+		peeled, _, err := r.peel(hash)
+		return peeled, err == nil, nil
+	case "^{commit}":
+		peeled, err := r.peelCommit(hash)
+		return peeled, err == nil, nil
+	}
+
+	if strings.HasPrefix(suffix, "^{") {
+		return "", false, fmt.Errorf("unsupported revision suffix '%v'", suffix)
+	}
+
+	n := 1
+
+	if len(suffix) > 1 {
+		n, _ = strconv.Atoi(suffix[1:])
+	}
+
+	commit, err := r.peelCommit(hash)
+
+	if err != nil {
+		return "", false, nil
+	}
+
+	if suffix[0] == '^' {
+		if n == 0 {
+			return commit, true, nil
+		}
+
+		c, err := r.commit(commit)
+
+		if err != nil {
+			return "", false, err
+		} else if n > len(c.parents) {
+			return "", false, nil
+		}
+
+		return c.parents[n-1], true, nil
+	}
+
+	for i := 0; i < n; i++ {
+		c, err := r.commit(commit)
+
+		if err != nil {
+			return "", false, err
+		} else if len(c.parents) == 0 {
+			return "", false, nil
+		}
+
+		commit = c.parents[0]
+	}
+
+	return commit, true, nil
+}
+
+// ancestors returns the set of the commits reachable from the specified one, including itself.
+func (r *nativeRepository) ancestors(commit string) (map[string]bool, error) {
+	seen := map[string]bool{commit: true}
+	queue := []string{commit}
+
+	for len(queue) > 0 {
+		c, err := r.commit(queue[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		queue = queue[1:]
+
+		for _, p := range c.parents {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+
+	return seen, nil
+}
+
+// abbreviate returns the shortest unique prefix of the specified hash, of at least 7 characters.
+func (r *nativeRepository) abbreviate(hash string) (string, error) {
+	for n := 7; n < len(hash); n++ {
+		hashes, err := r.objects.expand(hash[:n])
+
+		if err != nil {
+			return "", err
+		} else if len(hashes) <= 1 {
+			return hash[:n], nil
+		}
+	}
+
+	return hash, nil
+}
+
+// IsCommitExists takes a git commit hash and returns true if it's found, false otherwise.
+// It returns error if something goes wrong while checking.
+func (g *NativeGit) IsCommitExists(hash string) (bool, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return false, err
+	} else if len(hash) != 40 || strings.ToLower(hash) != hash || !hexPattern.MatchString(hash) || !r.objects.has(hash) {
+		return false, nil
+	}
+
+	obj, err := r.objects.read(hash)
+
+	if err != nil {
+		return false, err
+	}
+
+	return obj.kind == commitObject, nil
+}
+
+// GetTagCommit returns the hash of the object the specified tag points to, which is the tag object itself for an
+// annotated tag, it returns an error if the tag is not found or on failure.
+func (g *NativeGit) GetTagCommit(tag string) (string, error) {
+	return g.refHash("refs/tags/"+tag, newError(ReferenceError, "tag '%v' is not found", tag))
+}
+
+// GetBranchCommit returns the git commit hash of the specified git branch, it returns an error on failure.
+func (g *NativeGit) GetBranchCommit(branch string) (string, error) {
+	return g.refHash("refs/heads/"+branch, newError(ReferenceError, "branch '%v' is not found", branch))
+}
+
+func (g *NativeGit) refHash(name string, notFound error) (string, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return "", err
+	}
+
+	hash, _, found, err := r.resolveRef(name)
+
+	if err != nil {
+		return "", err
+	} else if !found {
+		return "", notFound
+	}
+
+	return hash, nil
+}
+
+// HeadCommit returns the git commit hash currently checked out, it returns an error on failure.
+func (g *NativeGit) HeadCommit() (string, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return "", err
+	}
+
+	hash, _, found, err := r.resolve("HEAD^{commit}")
+
+	if err != nil {
+		return "", err
+	} else if !found {
+		return "", fmt.Errorf("HEAD does not point to a commit")
+	}
+
+	return hash, nil
+}
+
+// TopLevel returns the absolute path of the repository root directory, it returns an error on failure.
+func (g *NativeGit) TopLevel() (string, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return "", err
+	}
+
+	return r.top, nil
+}
+
+// ResolveReference resolves the specified revision expression to a full commit hash along with the kind and the name
+// of the reference it is based on, it returns an error if the expression does not resolve to a commit or on failure.
+func (g *NativeGit) ResolveReference(expr string) (Reference, error) {
+	ref := Reference{Name: expr, Kind: ExpressionReference}
+
+	r, err := g.open()
+
+	if err != nil {
+		return ref, err
+	}
+
+	hash, _, found, err := r.resolve(expr + "^{commit}")
+
+	if err != nil {
+		return ref, err
+	} else if !found {
+		return ref, newError(ReferenceError, "reference '%v' is not found", expr)
+	}
+
+	ref.Commit = hash
+
+	// a peeled tag (e.g. 'v1.2^{}') still refers to the tag.
+	base := expr
+
+	if i := strings.Index(expr, "^{"); i > 0 && strings.HasSuffix(expr, "}") {
+		base = expr[:i]
+	}
+
+	_, full, _, _ := r.resolve(base)
+
+	switch {
+	case strings.HasPrefix(full, "refs/heads/"):
+		ref.Name, ref.Kind = strings.TrimPrefix(full, "refs/heads/"), BranchReference
+	case strings.HasPrefix(full, "refs/remotes/"):
+		ref.Name, ref.Kind = strings.TrimPrefix(full, "refs/remotes/"), RemoteBranchReference
+	case strings.HasPrefix(full, "refs/tags/"):
+		ref.Name, ref.Kind = strings.TrimPrefix(full, "refs/tags/"), TagReference
+	case len(full) == 0 && strings.HasPrefix(ref.Commit, strings.ToLower(expr)):
+		ref.Kind = CommitReference
+	}
+
+	return ref, nil
+}
+
+// pathsKey returns a key identifying the content of the specified pathspecs in the specified tree, along with
+// whether any of them exists.
+func (r *nativeRepository) pathsKey(tree string, specs []string) (string, bool, error) {
+	var hashes []string
+	exists := false
+
+	for _, s := range specs {
+		hash, err := r.treePath(tree, s)
+
+		if err != nil {
+			return "", false, err
+		}
+
+		hashes = append(hashes, hash)
+		exists = exists || len(hash) > 0
+	}
+
+	return strings.Join(hashes, " "), exists, nil
+}
+
+// LastCommit returns the most recent commit reachable from the specified one that changed any of the specified
+// paths, following the history the same way 'git log' simplifies it: a commit that has the same content of the
+// paths as one of its parents is skipped in favor of that parent.
+func (g *NativeGit) LastCommit(commit string, paths ...string) (string, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return "", err
+	}
+
+	specs, err := g.pathspecs(r, paths)
+
+	if err != nil {
+		return "", err
+	}
+
+	hash, _, found, err := r.resolve(commit + "^{commit}")
+
+	if err != nil {
+		return "", err
+	} else if !found {
+		return "", newError(ReferenceError, "reference '%v' is not found", commit)
+	} else if len(specs) == 0 {
+		return hash, nil
+	}
+
+	for {
+		c, err := r.commit(hash)
+
+		if err != nil {
+			return "", err
+		}
+
+		key, exists, err := r.pathsKey(c.tree, specs)
+
+		if err != nil {
+			return "", err
+		} else if len(c.parents) == 0 && !exists {
+			return "", newError(ReferenceError, "no commits reachable from '%v' touch '%v'", commit, strings.Join(paths, ", "))
+		}
+
+		next := ""
+
+		for _, p := range c.parents {
+			var parent nativeCommit
+			var parentKey string
+
+			if parent, err = r.commit(p); err != nil {
+				return "", err
+			} else if parentKey, _, err = r.pathsKey(parent.tree, specs); err != nil {
+				return "", err
+			} else if parentKey == key {
+				next = p
+				break
+			}
+		}
+
+		if len(next) == 0 {
+			return hash, nil
+		}
+
+		hash = next
+	}
+}
+
+// DefaultBranch detects the branch to release from the same way as Git.DefaultBranch does, the 'init.defaultBranch'
+// configuration is read from the repository, the global and the system configuration files.
+func (g *NativeGit) DefaultBranch() (string, string, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return "", "", err
+	}
+
+	rules := []struct {
+		description string
+		branch      func() string
+	}{
+		{"as pointed to by 'origin/HEAD'", func() string {
+			if value, symbolic, _, _ := r.readRef("refs/remotes/origin/HEAD"); symbolic {
+				return strings.TrimPrefix(value, "refs/remotes/origin/")
+			}
+			return ""
+		}},
+		{"by the 'init.defaultBranch' configuration", func() string {
+			return r.config("init", "defaultbranch")
+		}},
+		{"as the currently checked out branch", func() string {
+			if value, symbolic, _, _ := r.readRef("HEAD"); symbolic {
+				return strings.TrimPrefix(value, "refs/heads/")
+			}
+			return ""
+		}},
+		{"as the conventional default branch", func() string { return "main" }},
+		{"as the conventional default branch", func() string { return "master" }},
+	}
+
+	for _, rule := range rules {
+		if branch := rule.branch(); len(branch) > 0 {
+			if _, _, found, _ := r.resolveRef("refs/heads/" + branch); found {
+				return branch, rule.description, nil
+			}
+		}
+	}
+
+	return "", "", newError(ReferenceError, "no default branch is found, please specify one")
+}
+
+// configFiles returns the git configuration files in the increasing order of precedence.
+func (r *nativeRepository) configFiles() []string {
+	system := os.Getenv("GIT_CONFIG_SYSTEM")
+
+	if len(system) == 0 {
+		system = "/etc/gitconfig"
+	}
+
+	files := []string{system}
+
+	if global := os.Getenv("GIT_CONFIG_GLOBAL"); len(global) > 0 {
+		files = append(files, global)
+	} else {
+		xdg := os.Getenv("XDG_CONFIG_HOME")
+
+		if home, err := os.UserHomeDir(); err == nil {
+			if len(xdg) == 0 {
+				xdg = filepath.Join(home, ".config")
+			}
+			files = append(files, filepath.Join(xdg, "git", "config"), filepath.Join(home, ".gitconfig"))
+		}
+	}
+
+	return append(files, filepath.Join(r.commonDir, "config"))
+}
+
+// config returns the value of the specified key of the specified section of the git configuration, the last defined
+// value wins, neither the subsections nor the included files are supported.
+func (r *nativeRepository) config(section, key string) string {
+	var value string
+
+	for _, file := range r.configFiles() {
+		content, err := ioutil.ReadFile(file)
+
+		if err != nil {
+			continue
+		}
+
+		current := ""
+
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+
+			if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+				current = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			} else if kv := strings.SplitN(line, "=", 2); current == section && len(kv) == 2 &&
+				strings.ToLower(strings.TrimSpace(kv[0])) == key {
+				value = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+			}
+		}
+	}
+
+	return value
+}
+
+// Tags returns the names of all the tags, it returns an error on failure.
+func (g *NativeGit) Tags() ([]string, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return nil, err
+	}
+
+	refs, err := r.refs("refs/tags/")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+
+	for name := range refs {
+		tags = append(tags, strings.TrimPrefix(name, "refs/tags/"))
+	}
+
+	sort.Strings(tags)
+
+	return tags, nil
+}
+
+// IsAncestor returns true if the specified commit is reachable from the specified reference,
+// it returns an error on failure.
+func (g *NativeGit) IsAncestor(commit, ref string) (bool, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return false, err
+	}
+
+	var from, to string
+	var found bool
+
+	if from, _, found, err = r.resolve(commit + "^{commit}"); err != nil {
+		return false, err
+	} else if !found {
+		return false, fmt.Errorf("not a valid commit name %v", commit)
+	} else if to, _, found, err = r.resolve(ref + "^{commit}"); err != nil {
+		return false, err
+	} else if !found {
+		return false, fmt.Errorf("not a valid commit name %v", ref)
+	}
+
+	ancestors, err := r.ancestors(to)
+
+	if err != nil {
+		return false, err
+	}
+
+	return ancestors[from], nil
+}
+
+// describeName is the tag naming a commit while describing, when many tags point to the same commit the annotated ones
+// win, then the most recently created ones, then the first ones in the order of their names.
+type describeName struct {
+	name      string
+	annotated bool
+	date      int64
+}
+
+// describeCandidate is a tag describing a commit.
+type describeCandidate struct {
+	name  string
+	depth int
+	flag  uint64
+	order int
+}
+
+// maxDescribeCandidates is the number of candidate tags considered, like the default of 'git describe --candidates'.
+const maxDescribeCandidates = 10
+
+// describeWalk walks the history in commit date order as 'git describe' does, so that the same tag and number of
+// commits since are picked, the numbers of commits are counted along the walk and are not always the exact numbers
+// of commits reachable from the described one but not from the tag.
+type describeWalk struct {
+	r       *nativeRepository
+	commits map[string]nativeCommit
+	flags   map[string]uint64
+	list    []string
+}
+
+// describeSeen flags the commits that are already queued.
+const describeSeen = 1
+
+func (w *describeWalk) commit(hash string) (nativeCommit, error) {
+	if c, ok := w.commits[hash]; ok {
+		return c, nil
+	}
+
+	c, err := w.r.commit(hash)
+
+	if err == nil {
+		w.commits[hash] = c
+	}
+
+	return c, err
+}
+
+// insert queues the specified commit after the ones of the same or a more recent date.
+func (w *describeWalk) insert(hash string) error {
+	c, err := w.commit(hash)
+
+	if err != nil {
+		return err
+	}
+
+	i := 0
+
+	for ; i < len(w.list); i++ {
+		if other, _ := w.commit(w.list[i]); other.time < c.time {
+			break
+		}
+	}
+
+	w.list = append(w.list, "")
+	copy(w.list[i+1:], w.list[i:])
+	w.list[i] = hash
+
+	return nil
+}
+
+func (w *describeWalk) pop() string {
+	hash := w.list[0]
+	w.list = w.list[1:]
+	return hash
+}
+
+// propagate queues the parents of the specified commit that are not already queued and flags them as reachable from
+// the same candidates.
+func (w *describeWalk) propagate(hash string) error {
+	c, err := w.commit(hash)
+
+	if err != nil {
+		return err
+	}
+
+	for _, p := range c.parents {
+		if w.flags[p]&describeSeen == 0 {
+			if err = w.insert(p); err != nil {
+				return err
+			}
+		}
+
+		w.flags[p] |= w.flags[hash]
+	}
+
+	return nil
+}
+
+// describeNames returns the tags matching the specified pattern, if any, mapped by the commits they point to.
+func (r *nativeRepository) describeNames(pattern string) (map[string]describeName, error) {
+	var match *regexp.Regexp
+	var err error
+
+	if len(pattern) > 0 {
+		if match, err = regexp.Compile(describePatternToRegexp(pattern)); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%v'", pattern)
+		}
+	}
+
+	refs, err := r.refs("refs/tags/")
+
+	if err != nil {
+		return nil, err
+	}
+
+	var fulls []string
+
+	for full := range refs {
+		fulls = append(fulls, full)
+	}
+
+	sort.Strings(fulls)
+
+	names := make(map[string]describeName)
+
+	for _, full := range fulls {
+		n := describeName{name: strings.TrimPrefix(full, "refs/tags/")}
+
+		if match != nil && !match.MatchString(n.name) {
+			continue
+		}
+
+		hash := refs[full]
+		tagged, kind, err := r.peel(hash)
+
+		if err != nil || kind != commitObject {
+			continue
+		}
+
+		if n.annotated = tagged != hash; n.annotated {
+			var tag gitObject
+
+			if tag, err = r.object(hash, tagObject); err != nil {
+				return nil, err
+			}
+
+			if fields := strings.Fields(header(tag.data, "tagger")); len(fields) >= 2 {
+				n.date, _ = strconv.ParseInt(fields[len(fields)-2], 10, 64)
+			}
+		}
+
+		if e, found := names[tagged]; !found || (!e.annotated && n.annotated) || (e.annotated && n.annotated && e.date < n.date) {
+			names[tagged] = n
+		}
+	}
+
+	return names, nil
+}
+
+// describe returns the tag matching the specified pattern that describes the specified commit, along with the number
+// of commits since, the same way 'git describe --tags' picks it, or false if there is none.
+func (r *nativeRepository) describe(commit, pattern string) (describeCandidate, bool, error) {
+	names, err := r.describeNames(pattern)
+
+	if err != nil {
+		return describeCandidate{}, false, err
+	} else if n, found := names[commit]; found {
+		return describeCandidate{name: n.name}, true, nil
+	}
+
+	w := &describeWalk{r: r, commits: make(map[string]nativeCommit), flags: map[string]uint64{commit: describeSeen}}
+	w.list = []string{commit}
+
+	var matches []describeCandidate
+	var gaveUpOn string
+	seen, annotated := 0, 0
+
+	for len(w.list) > 0 {
+		c := w.pop()
+		seen++
+
+		if n, found := names[c]; found {
+			if len(matches) == maxDescribeCandidates {
+				gaveUpOn = c
+				break
+			}
+
+			t := describeCandidate{name: n.name, depth: seen - 1, flag: 1 << uint(len(matches)+1), order: len(matches) + 1}
+			matches = append(matches, t)
+			w.flags[c] |= t.flag
+
+			if n.annotated {
+				annotated++
+			}
+		}
+
+		for i := range matches {
+			if w.flags[c]&matches[i].flag == 0 {
+				matches[i].depth++
+			}
+		}
+
+		// the walk stops once the last remaining path is reachable from all the candidates with the fewest commits.
+		if annotated > 0 && len(w.list) == 0 {
+			depth, within := -1, uint64(0)
+
+			for _, t := range matches {
+				if depth < 0 || t.depth < depth {
+					depth, within = t.depth, t.flag
+				} else if t.depth == depth {
+					within |= t.flag
+				}
+			}
+
+			if w.flags[c]&within == within {
+				break
+			}
+		}
+
+		if err = w.propagate(c); err != nil {
+			return describeCandidate{}, false, err
+		}
+	}
+
+	if len(matches) == 0 {
+		return describeCandidate{}, false, nil
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].depth != matches[j].depth {
+			return matches[i].depth < matches[j].depth
+		}
+		return matches[i].order < matches[j].order
+	})
+
+	best := &matches[0]
+
+	if len(gaveUpOn) > 0 {
+		if err = w.insert(gaveUpOn); err != nil {
+			return describeCandidate{}, false, err
+		}
+	}
+
+	// the walk goes on until all the remaining commits are reachable from the best candidate.
+	for len(w.list) > 0 {
+		c := w.pop()
+
+		if w.flags[c]&best.flag != 0 {
+			remaining := false
+
+			for _, other := range w.list {
+				if w.flags[other]&best.flag == 0 {
+					remaining = true
+					break
+				}
+			}
+
+			if !remaining {
+				break
+			}
+		} else {
+			best.depth++
+		}
+
+		if err = w.propagate(c); err != nil {
+			return describeCandidate{}, false, err
+		}
+	}
+
+	return *best, true, nil
+}
+
+// describePatternToRegexp converts a 'git describe --match' glob pattern, where '*' also matches '/', to a regular
+// expression.
+func describePatternToRegexp(pattern string) string {
+	var b bytes.Buffer
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				b.WriteString(pattern[i : i+end+1])
+				i += end
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return "^" + b.String() + "$"
+}
+
+// PreviousTag returns the most recent tag matching the specified glob pattern, any if empty, reachable from the
+// specified commit, or an empty string if there is none, it returns an error on failure.
+func (g *NativeGit) PreviousTag(commit, pattern string) (string, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return "", err
+	}
+
+	hash, _, found, err := r.resolve(commit + "^{commit}")
+
+	if err != nil {
+		return "", err
+	} else if !found {
+		return "", fmt.Errorf("not a valid object name %v", commit)
+	}
+
+	c, found, err := r.describe(hash, pattern)
+
+	return c.name, err
+}
+
+// Describe returns the nearest tag the specified commit is reachable from followed by the number of commits since
+// and the abbreviated commit hash (e.g. 'v1.2-3-g1a2b3c4') if it is not the tagged commit itself, like
+// 'git describe --tags' does, it returns an error if no tag describes the commit or on failure.
+func (g *NativeGit) Describe(commit string) (string, error) {
+	r, err := g.open()
+
+	if err != nil {
+		return "", err
+	}
+
+	hash, _, found, err := r.resolve(commit + "^{commit}")
+
+	if err != nil {
+		return "", err
+	} else if !found {
+		return "", fmt.Errorf("not a valid object name %v", commit)
+	}
+
+	c, found, err := r.describe(hash, "")
+
+	if err != nil {
+		return "", err
+	} else if !found {
+		return "", fmt.Errorf("no tags can describe '%v'", hash)
+	} else if c.depth == 0 {
+		return c.name, nil
+	}
+
+	abbrev, err := r.abbreviate(hash)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%v-%v-g%v", c.name, c.depth, abbrev), nil
+}
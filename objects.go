@@ -0,0 +1,530 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The git object types.
+const (
+	commitObject = "commit"
+	treeObject   = "tree"
+	blobObject   = "blob"
+	tagObject    = "tag"
+)
+
+// The types of the entries of a pack file.
+const (
+	packCommit   = 1
+	packTree     = 2
+	packBlob     = 3
+	packTag      = 4
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+var packObjectTypes = map[int]string{packCommit: commitObject, packTree: treeObject, packBlob: blobObject, packTag: tagObject}
+
+// maxDeltaChain limits the length of the delta chains of the pack files, to stop on corrupted packs.
+const maxDeltaChain = 10000
+
+// gitObject is a decompressed git object.
+type gitObject struct {
+	kind string
+	data []byte
+}
+
+// objectStore reads the loose and packed objects of a git objects directory.
+type objectStore struct {
+	dir   string
+	packs []*packFile
+}
+
+// newObjectStore opens the objects directory at the specified path along with the indexes of its pack files.
+func newObjectStore(dir string) (*objectStore, error) {
+	s := &objectStore{dir: dir}
+
+	indexes, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(indexes)
+
+	for _, idx := range indexes {
+		var p *packFile
+
+		if p, err = openPackIndex(idx); err != nil {
+			return nil, err
+		}
+
+		s.packs = append(s.packs, p)
+	}
+
+	return s, nil
+}
+
+// read returns the object of the specified full hash, it returns an error if it is not found or on failure.
+func (s *objectStore) read(hash string) (gitObject, error) {
+	var raw []byte
+	var err error
+
+	if raw, err = hex.DecodeString(hash); err != nil || len(raw) != 20 {
+		return gitObject{}, fmt.Errorf("invalid object name '%v'", hash)
+	}
+
+	if obj, err := s.readLoose(hash); err == nil || !os.IsNotExist(err) {
+		return obj, err
+	}
+
+	for _, p := range s.packs {
+		if offset, found := p.find(raw); found {
+			return p.read(s, offset, 0)
+		}
+	}
+
+	return gitObject{}, fmt.Errorf("object '%v' is not found", hash)
+}
+
+// has returns true if the object of the specified full hash exists.
+func (s *objectStore) has(hash string) bool {
+	raw, err := hex.DecodeString(hash)
+
+	if err != nil || len(raw) != 20 {
+		return false
+	}
+
+	if _, err = os.Stat(filepath.Join(s.dir, hash[:2], hash[2:])); err == nil {
+		return true
+	}
+
+	for _, p := range s.packs {
+		if _, found := p.find(raw); found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expand returns the full hashes of the objects whose names start with the specified lower case hexadecimal prefix.
+func (s *objectStore) expand(prefix string) ([]string, error) {
+	found := make(map[string]bool)
+
+	if len(prefix) < 2 {
+		return nil, fmt.Errorf("object name prefix '%v' is too short", prefix)
+	}
+
+	if entries, err := ioutil.ReadDir(filepath.Join(s.dir, prefix[:2])); err == nil {
+		for _, e := range entries {
+			if name := prefix[:2] + e.Name(); len(name) == 40 && strings.HasPrefix(name, prefix) {
+				found[name] = true
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, p := range s.packs {
+		for _, name := range p.withPrefix(prefix) {
+			found[name] = true
+		}
+	}
+
+	var hashes []string
+
+	for h := range found {
+		hashes = append(hashes, h)
+	}
+
+	sort.Strings(hashes)
+
+	return hashes, nil
+}
+
+func (s *objectStore) readLoose(hash string) (gitObject, error) {
+	f, err := os.Open(filepath.Join(s.dir, hash[:2], hash[2:]))
+
+	if err != nil {
+		return gitObject{}, err
+	}
+
+	defer f.Close()
+
+	var z io.ReadCloser
+	var content []byte
+
+	if z, err = zlib.NewReader(f); err != nil {
+		return gitObject{}, fmt.Errorf("corrupted loose object '%v': %v", hash, err)
+	}
+
+	defer z.Close()
+
+	if content, err = ioutil.ReadAll(z); err != nil {
+		return gitObject{}, fmt.Errorf("corrupted loose object '%v': %v", hash, err)
+	}
+
+	nul := bytes.IndexByte(content, 0)
+
+	if nul < 0 {
+		return gitObject{}, fmt.Errorf("corrupted loose object '%v'", hash)
+	}
+
+	header := strings.SplitN(string(content[:nul]), " ", 2)
+
+	if len(header) != 2 {
+		return gitObject{}, fmt.Errorf("corrupted loose object '%v'", hash)
+	} else if size, e := strconv.Atoi(header[1]); e != nil || size != len(content)-nul-1 {
+		return gitObject{}, fmt.Errorf("corrupted loose object '%v'", hash)
+	}
+
+	return gitObject{kind: header[0], data: content[nul+1:]}, nil
+}
+
+// packFile is a pack file along with its version 1 or 2 index.
+type packFile struct {
+	path    string
+	fanout  [256]uint32
+	names   []byte
+	offsets []uint64
+}
+
+func openPackIndex(idx string) (*packFile, error) {
+	content, err := ioutil.ReadFile(idx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &packFile{path: strings.TrimSuffix(idx, ".idx") + ".pack"}
+	corrupted := fmt.Errorf("corrupted pack index '%v'", idx)
+
+	version2 := bytes.HasPrefix(content, []byte{0xff, 't', 'O', 'c'})
+	table := content
+
+	if version2 {
+		if len(content) < 8 || binary.BigEndian.Uint32(content[4:8]) != 2 {
+			return nil, fmt.Errorf("unsupported pack index version in '%v'", idx)
+		}
+		table = content[8:]
+	}
+
+	if len(table) < 256*4 {
+		return nil, corrupted
+	}
+
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(table[i*4:])
+	}
+
+	n := int(p.fanout[255])
+	table = table[256*4:]
+
+	if !version2 {
+		if len(table) < n*24 {
+			return nil, corrupted
+		}
+
+		p.names = make([]byte, 0, n*20)
+		p.offsets = make([]uint64, n)
+
+		for i := 0; i < n; i++ {
+			p.offsets[i] = uint64(binary.BigEndian.Uint32(table[i*24:]))
+			p.names = append(p.names, table[i*24+4:i*24+24]...)
+		}
+
+		return p, nil
+	}
+
+	if len(table) < n*28 {
+		return nil, corrupted
+	}
+
+	p.names = table[:n*20]
+	small := table[n*24 : n*28]
+	large := table[n*28:]
+	p.offsets = make([]uint64, n)
+
+	for i := 0; i < n; i++ {
+		offset := binary.BigEndian.Uint32(small[i*4:])
+
+		if offset&0x80000000 == 0 {
+			p.offsets[i] = uint64(offset)
+			continue
+		}
+
+		j := int(offset & 0x7fffffff)
+
+		if len(large) < (j+1)*8 {
+			return nil, corrupted
+		}
+
+		p.offsets[i] = binary.BigEndian.Uint64(large[j*8:])
+	}
+
+	return p, nil
+}
+
+// bounds returns the range of the names starting with the specified byte.
+func (p *packFile) bounds(first byte) (int, int) {
+	lo := 0
+
+	if first > 0 {
+		lo = int(p.fanout[first-1])
+	}
+
+	return lo, int(p.fanout[first])
+}
+
+func (p *packFile) name(i int) []byte {
+	return p.names[i*20 : i*20+20]
+}
+
+func (p *packFile) find(hash []byte) (uint64, bool) {
+	lo, hi := p.bounds(hash[0])
+	i := lo + sort.Search(hi-lo, func(i int) bool { return bytes.Compare(p.name(lo+i), hash) >= 0 })
+
+	if i < hi && bytes.Equal(p.name(i), hash) {
+		return p.offsets[i], true
+	}
+
+	return 0, false
+}
+
+func (p *packFile) withPrefix(prefix string) []string {
+	var result []string
+
+	first, err := strconv.ParseUint(prefix[:2], 16, 8)
+
+	if err != nil {
+		return nil
+	}
+
+	lo, hi := p.bounds(byte(first))
+
+	for i := lo; i < hi; i++ {
+		if name := hex.EncodeToString(p.name(i)); strings.HasPrefix(name, prefix) {
+			result = append(result, name)
+		}
+	}
+
+	return result
+}
+
+// read reads the object at the specified offset of the pack file, resolving its delta chain if any.
+func (p *packFile) read(s *objectStore, offset uint64, depth int) (gitObject, error) {
+	if depth > maxDeltaChain {
+		return gitObject{}, fmt.Errorf("too long delta chain in pack '%v'", p.path)
+	}
+
+	f, err := os.Open(p.path)
+
+	if err != nil {
+		return gitObject{}, err
+	}
+
+	defer f.Close()
+
+	r := bufio.NewReader(io.NewSectionReader(f, int64(offset), 1<<62))
+	corrupted := fmt.Errorf("corrupted pack '%v' at offset %v", p.path, offset)
+
+	b, err := r.ReadByte()
+
+	if err != nil {
+		return gitObject{}, corrupted
+	}
+
+	kind := int(b>>4) & 7
+
+	// the size of the object is not needed since the decompressed data is read until its end.
+	for b&0x80 != 0 {
+		if b, err = r.ReadByte(); err != nil {
+			return gitObject{}, corrupted
+		}
+	}
+
+	var base gitObject
+
+	switch kind {
+	case packOfsDelta:
+		var distance uint64
+
+		if b, err = r.ReadByte(); err != nil {
+			return gitObject{}, corrupted
+		}
+
+		distance = uint64(b & 0x7f)
+
+		for b&0x80 != 0 {
+			if b, err = r.ReadByte(); err != nil {
+				return gitObject{}, corrupted
+			}
+			distance = ((distance + 1) << 7) | uint64(b&0x7f)
+		}
+
+		if distance == 0 || distance > offset {
+			return gitObject{}, corrupted
+		}
+
+		if base, err = p.read(s, offset-distance, depth+1); err != nil {
+			return gitObject{}, err
+		}
+	case packRefDelta:
+		hash := make([]byte, 20)
+
+		if _, err = io.ReadFull(r, hash); err != nil {
+			return gitObject{}, corrupted
+		}
+
+		if base, err = s.read(hex.EncodeToString(hash)); err != nil {
+			return gitObject{}, err
+		}
+	default:
+		if _, known := packObjectTypes[kind]; !known {
+			return gitObject{}, corrupted
+		}
+	}
+
+	var z io.ReadCloser
+	var data []byte
+
+	if z, err = zlib.NewReader(r); err != nil {
+		return gitObject{}, corrupted
+	}
+
+	defer z.Close()
+
+	if data, err = ioutil.ReadAll(z); err != nil {
+		return gitObject{}, corrupted
+	}
+
+	if kind != packOfsDelta && kind != packRefDelta {
+		return gitObject{kind: packObjectTypes[kind], data: data}, nil
+	}
+
+	if data, err = applyDelta(base.data, data); err != nil {
+		return gitObject{}, fmt.Errorf("%v: %v", corrupted.Error(), err)
+	}
+
+	return gitObject{kind: base.kind, data: data}, nil
+}
+
+// deltaSize reads a size of the header of a delta, it returns the size along with the rest of the delta.
+func deltaSize(delta []byte) (int, []byte, error) {
+	size, shift := 0, uint(0)
+
+	for i, b := range delta {
+		size |= int(b&0x7f) << shift
+		shift += 7
+
+		if b&0x80 == 0 {
+			return size, delta[i+1:], nil
+		}
+	}
+
+	return 0, nil, fmt.Errorf("truncated delta header")
+}
+
+// applyDelta applies the specified git delta to the specified base, it returns an error on a malformed delta.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	var baseSize, targetSize int
+	var err error
+
+	if baseSize, delta, err = deltaSize(delta); err != nil {
+		return nil, err
+	} else if targetSize, delta, err = deltaSize(delta); err != nil {
+		return nil, err
+	} else if baseSize != len(base) {
+		return nil, fmt.Errorf("delta base size %v does not match %v", baseSize, len(base))
+	}
+
+	target := make([]byte, 0, targetSize)
+
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case op&0x80 != 0:
+			offset, size := 0, 0
+
+			for i := uint(0); i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				} else if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta copy instruction")
+				}
+
+				if i < 4 {
+					offset |= int(delta[0]) << (8 * i)
+				} else {
+					size |= int(delta[0]) << (8 * (i - 4))
+				}
+
+				delta = delta[1:]
+			}
+
+			if size == 0 {
+				size = 0x10000
+			}
+
+			if offset+size > len(base) {
+				return nil, fmt.Errorf("delta copy out of the base bounds")
+			}
+
+			target = append(target, base[offset:offset+size]...)
+		case op != 0:
+			if int(op) > len(delta) {
+				return nil, fmt.Errorf("truncated delta insert instruction")
+			}
+
+			target = append(target, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, fmt.Errorf("invalid delta instruction")
+		}
+	}
+
+	if len(target) != targetSize {
+		return nil, fmt.Errorf("delta target size %v does not match %v", targetSize, len(target))
+	}
+
+	return target, nil
+}
+
+// hashObject returns the hash of the object of the specified type and content.
+func hashObject(kind string, data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%v %v\x00", kind, len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("the quick brown fox")
+
+	// base size 19, target size 17, copy 10 bytes at offset 0, insert "red", copy 4 bytes at offset 15.
+	delta := []byte{19, 17, 0x90, 10, 3, 'r', 'e', 'd', 0x91, 15, 4}
+
+	target, err := applyDelta(base, delta)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "the quick red fox", string(target))
+}
+
+func TestApplyDelta_Invalid(t *testing.T) {
+	base := []byte("base")
+
+	for name, delta := range map[string][]byte{
+		"base size mismatch": {5, 1, 1, 'x'},
+		"copy out of bounds": {4, 4, 0x91, 2, 4},
+		"truncated insert":   {4, 3, 3, 'x'},
+		"reserved opcode":    {4, 0, 0},
+		"target mismatch":    {4, 2, 1, 'x'},
+	} {
+		_, err := applyDelta(base, delta)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestHashObject(t *testing.T) {
+	assert.Equal(t, "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391", hashObject(blobObject, nil))
+	assert.Equal(t, "ce013625030ba8dba906f756967f9e9ca394464a", hashObject(blobObject, []byte("hello\n")))
+}
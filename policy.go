@@ -0,0 +1,199 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// The names of the policy rules.
+const (
+	AllowedBranchesRule    = "allowed-branches"
+	TagPatternRule         = "tag-pattern"
+	ReleaseBranchesRule    = "release-branches"
+	PrereleaseBranchesRule = "prerelease-branches"
+	IncreasingVersionsRule = "increasing-versions"
+)
+
+// Policy is the set of the rules a release must comply with, a rule is not evaluated if it is not configured,
+// branch names are matched against glob patterns (e.g. 'release/*').
+type Policy struct {
+	// AllowedBranches is the list of the patterns of the branches a release may be taken from, it only applies to the
+	// releases taken from a local or remote-tracking branch, not to the tags, commits or revision expressions.
+	AllowedBranches []string `json:"allowedBranches"`
+	// TagPattern is the regular expression released tags must match (e.g. '^v\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$'),
+	// without the module path prefix.
+	TagPattern string `json:"tagPattern"`
+	// ReleaseBranches is the list of the patterns of the branches, either local or remote-tracking, at least one of
+	// them must contain the commit of a released tag that is not a pre-release.
+	ReleaseBranches []string `json:"releaseBranches"`
+	// PrereleaseBranches is the list of the patterns of the branches pre-releases may only be taken from.
+	PrereleaseBranches []string `json:"prereleaseBranches"`
+	// IncreasingVersions requires a released tag version to be strictly greater than the versions of all the other
+	// tags, which are compared as semantic versions, it only applies to the released tags.
+	IncreasingVersions bool `json:"increasingVersions"`
+}
+
+// PolicyTarget is the release evaluated against a policy.
+type PolicyTarget struct {
+	// Ref is the reference the release is taken from.
+	Ref Reference
+	// Release is the release version string.
+	Release string
+	// Module is the module scope, only the tags prefixed with its path are considered if scoped.
+	Module ModuleScope
+	// IgnoreTagPrefix is the prefix trimmed off the tags names while calculating their versions.
+	IgnoreTagPrefix string
+}
+
+// PolicyViolation is a policy rule a release does not comply with.
+type PolicyViolation struct {
+	// Rule is the name of the violated rule.
+	Rule string
+	// Message describes the violation.
+	Message string
+}
+
+// String returns the rule name followed by the violation description.
+func (v PolicyViolation) String() string {
+	return fmt.Sprintf("%v: %v", v.Rule, v.Message)
+}
+
+// Enabled returns true if any of the rules is configured.
+func (p Policy) Enabled() bool {
+	return len(p.AllowedBranches) > 0 || len(p.TagPattern) > 0 || len(p.ReleaseBranches) > 0 ||
+		len(p.PrereleaseBranches) > 0 || p.IncreasingVersions
+}
+
+// Evaluate returns the violations of the configured rules by the specified release,
+// it returns an error if a rule could not be evaluated.
+func (p Policy) Evaluate(g *Git, target PolicyTarget) ([]PolicyViolation, error) {
+	var violations []PolicyViolation
+
+	violate := func(rule, format string, args ...interface{}) {
+		violations = append(violations, PolicyViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	isTag := target.Ref.Kind == TagReference
+	isBranch := target.Ref.Kind == BranchReference || target.Ref.Kind == RemoteBranchReference
+	branch := target.Ref.Name
+
+	if target.Ref.Kind == RemoteBranchReference {
+		branch = branch[strings.Index(branch, "/")+1:]
+	}
+
+	version, versionErr := ParseSemanticVersion(target.Release)
+	prerelease := versionErr == nil && version.Prerelease()
+
+	// a release not taken from a branch belongs to all the branches containing its commit.
+	var branches []string
+	var err error
+
+	if isBranch {
+		branches = []string{branch}
+	} else if isTag && len(p.ReleaseBranches) > 0 || prerelease && len(p.PrereleaseBranches) > 0 {
+		if branches, err = g.BranchesContaining(target.Ref.Commit); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.AllowedBranches) > 0 && isBranch && !matchesBranch(p.AllowedBranches, branch) {
+		violate(AllowedBranchesRule, "branch '%v' is not allowed, releases may only be taken from: %v",
+			branch, strings.Join(p.AllowedBranches, ", "))
+	}
+
+	if len(p.TagPattern) > 0 && isTag {
+		var pattern *regexp.Regexp
+
+		if pattern, err = regexp.Compile(p.TagPattern); err != nil {
+			return nil, fmt.Errorf("invalid tag pattern '%v': %v", p.TagPattern, err.Error())
+		} else if tag := target.Module.TrimTag(target.Ref.Name); !pattern.MatchString(tag) {
+			violate(TagPatternRule, "tag '%v' does not match '%v'", tag, p.TagPattern)
+		}
+	}
+
+	if len(p.ReleaseBranches) > 0 && isTag && !prerelease && !matchesAnyBranch(p.ReleaseBranches, branches) {
+		violate(ReleaseBranchesRule, "tag '%v' commit '%v' is not found in any of the branches: %v",
+			target.Ref.Name, target.Ref.Commit, strings.Join(p.ReleaseBranches, ", "))
+	}
+
+	if len(p.PrereleaseBranches) > 0 && prerelease && !matchesAnyBranch(p.PrereleaseBranches, branches) {
+		violate(PrereleaseBranchesRule, "pre-release '%v' is not taken from any of the branches: %v",
+			target.Release, strings.Join(p.PrereleaseBranches, ", "))
+	}
+
+	if p.IncreasingVersions && isTag {
+		if versionErr != nil {
+			violate(IncreasingVersionsRule, "release version '%v' is not a semantic version", target.Release)
+		} else if greatest, tag, e := p.greatestVersion(g, target); e != nil {
+			return nil, e
+		} else if len(tag) > 0 && version.Compare(greatest) <= 0 {
+			violate(IncreasingVersionsRule, "release version '%v' is not greater than the version '%v' of tag '%v'",
+				target.Release, greatest, tag)
+		}
+	}
+
+	return violations, nil
+}
+
+// greatestVersion returns the greatest semantic version of the tags other than the target one along with its tag name,
+// or an empty tag name if none of them is a semantic version.
+func (p Policy) greatestVersion(g *Git, target PolicyTarget) (SemanticVersion, string, error) {
+	var greatest SemanticVersion
+	var greatestTag string
+
+	tags, err := g.Tags()
+
+	if err != nil {
+		return greatest, "", err
+	}
+
+	for _, tag := range tags {
+		if tag == target.Ref.Name || target.Module.Scoped() && !strings.HasPrefix(tag, target.Module.Prefix+"/") {
+			continue
+		}
+
+		v, e := ParseSemanticVersion(strings.TrimPrefix(target.Module.TrimTag(tag), target.IgnoreTagPrefix))
+
+		if e == nil && (len(greatestTag) == 0 || v.Compare(greatest) > 0) {
+			greatest, greatestTag = v, tag
+		}
+	}
+
+	return greatest, greatestTag, nil
+}
+
+func matchesBranch(patterns []string, branch string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyBranch(patterns, branches []string) bool {
+	for _, branch := range branches {
+		if matchesBranch(patterns, branch) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+var testPolicy = Policy{
+	AllowedBranches:    []string{"main", "release/*"},
+	TagPattern:         `^v\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`,
+	ReleaseBranches:    []string{"main"},
+	PrereleaseBranches: []string{"release/*"},
+	IncreasingVersions: true,
+}
+
+type PolicyTestSuite struct {
+	suite.Suite
+	git *Git
+	cmd Command
+}
+
+// SetupTest creates a repository with a 'main' branch tagged 'v1.0.0', a 'release/1.1' branch one commit ahead
+// and a 'feature' branch one commit ahead of 'main' as well.
+func (suite *PolicyTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "test_rego_policy_")
+
+	if err != nil {
+		suite.Fail("failed to create temporary directory before test setup", err.Error())
+		return
+	}
+
+	suite.git = &Git{WorkDir: dir}
+	suite.cmd = NewNamedCommand("git", dir)
+
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "commit.gpgsign", "false"},
+		{"checkout", "-b", "main"},
+		{"commit", "-n", "--allow-empty", "-m", "'Initial commit'"},
+		{"tag", "v1.0.0"},
+		{"checkout", "-b", "release/1.1"},
+		{"commit", "-n", "--allow-empty", "-m", "'Release candidate'"},
+		{"checkout", "-b", "feature", "main"},
+		{"commit", "-n", "--allow-empty", "-m", "'Feature'"},
+		{"checkout", "main"},
+	} {
+		if _, err = suite.cmd.Execute(args...); err != nil {
+			suite.Fail("failed to setup the repository", err.Error())
+		}
+	}
+}
+
+func (suite *PolicyTestSuite) TearDownTest() {
+	if suite.git != nil && len(suite.git.WorkDir) > 0 {
+		if err := os.RemoveAll(suite.git.WorkDir); err != nil {
+			suite.Fail("failed to remove temporary directory after test teardown", err.Error())
+		}
+	}
+}
+
+func TestPolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(PolicyTestSuite))
+}
+
+func (suite *PolicyTestSuite) evaluate(expr, release string) []string {
+	ref, err := suite.git.ResolveReference(expr)
+
+	if err != nil {
+		suite.Fail("failed to resolve reference", err.Error())
+	}
+
+	violations, err := testPolicy.Evaluate(suite.git, PolicyTarget{Ref: ref, Release: release, IgnoreTagPrefix: "v"})
+	assert.Nil(suite.T(), err)
+
+	var result []string
+
+	for _, v := range violations {
+		result = append(result, v.String())
+	}
+
+	return result
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_AllowedBranch() {
+	assert.Empty(suite.T(), suite.evaluate("main", "SNAPSHOT"))
+	assert.Empty(suite.T(), suite.evaluate("release/1.1", "1.1.0-rc.1"))
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_NotAllowedBranch() {
+	assert.Equal(suite.T(), []string{
+		"allowed-branches: branch 'feature' is not allowed, releases may only be taken from: main, release/*",
+	}, suite.evaluate("feature", "SNAPSHOT"))
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_PrereleaseBranch() {
+	assert.Equal(suite.T(), []string{
+		"prerelease-branches: pre-release '1.1.0-rc.1' is not taken from any of the branches: release/*",
+	}, suite.evaluate("main", "1.1.0-rc.1"))
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_ReleaseTag() {
+	suite.cmd.Execute("tag", "v1.1.0", "main")
+	suite.cmd.Execute("commit", "-n", "--allow-empty", "-m", "'Fix'")
+
+	assert.Empty(suite.T(), suite.evaluate("v1.1.0", "1.1.0"))
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_PrereleaseTag() {
+	suite.cmd.Execute("tag", "v1.1.0-rc.1", "release/1.1")
+
+	assert.Empty(suite.T(), suite.evaluate("v1.1.0-rc.1", "1.1.0-rc.1"))
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_TagViolations() {
+	suite.cmd.Execute("tag", "release-0.9", "feature")
+
+	assert.Equal(suite.T(), []string{
+		"tag-pattern: tag 'release-0.9' does not match '" + testPolicy.TagPattern + "'",
+		"release-branches: tag 'release-0.9' commit '" + suite.commit("feature") + "' is not found in any of the branches: main",
+		"increasing-versions: release version 'release-0.9' is not a semantic version",
+	}, suite.evaluate("release-0.9", "release-0.9"))
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_NotIncreasing() {
+	suite.cmd.Execute("tag", "v1.2.0", "release/1.1")
+	suite.cmd.Execute("tag", "v1.1.0", "main")
+
+	assert.Equal(suite.T(), []string{
+		"increasing-versions: release version '1.1.0' is not greater than the version '1.2.0' of tag 'v1.2.0'",
+	}, suite.evaluate("v1.1.0", "1.1.0"))
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_BranchAndTagOnlyRules() {
+	// neither 'allowed-branches' nor 'increasing-versions' apply to a commit release.
+	assert.Empty(suite.T(), suite.evaluate(suite.commit("feature"), "0.1.0"))
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_RemoteTrackingBranch() {
+	suite.cmd.Execute("update-ref", "refs/remotes/origin/main", "feature")
+	suite.cmd.Execute("tag", "v1.1.0", "feature")
+
+	assert.Empty(suite.T(), suite.evaluate("v1.1.0", "1.1.0"))
+}
+
+func (suite *PolicyTestSuite) TestEvaluate_InvalidTagPattern() {
+	ref, _ := suite.git.ResolveReference("v1.0.0")
+	_, err := Policy{TagPattern: "("}.Evaluate(suite.git, PolicyTarget{Ref: ref, Release: "1.0.0"})
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *PolicyTestSuite) commit(expr string) string {
+	commit, _ := suite.cmd.Execute("rev-parse", expr)
+	return commit
+}
+
+func TestPolicy_Enabled(t *testing.T) {
+	assert.False(t, Policy{}.Enabled())
+	assert.True(t, Policy{IncreasingVersions: true}.Enabled())
+	assert.True(t, testPolicy.Enabled())
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// The ways the release information is embedded into the binaries, either through the '-X' linker flags or through
+// a generated Go file.
+const (
+	ldflagsReleaseInfo = "ldflags"
+	sourceReleaseInfo  = "source"
+)
+
+// releaseInfoModes is the set of the accepted ways of embedding the release information.
+var releaseInfoModes = map[string]bool{
+	"":                 true,
+	ldflagsReleaseInfo: true,
+	sourceReleaseInfo:  true,
+}
+
+const (
+	// releaseInfoFile is the name of the Go file generated into the packages declaring the release variables, it is
+	// named to be the first file of its package since the variables declared first are initialized first.
+	releaseInfoFile = "0_rego_release_info.go"
+	// releaseInfoTag is the build tag the generated file is constrained by, so that it is never compiled by any other
+	// build even if it is left behind.
+	releaseInfoTag = "rego_release_info"
+)
+
+// releaseInfoTemplate is the Go file setting the release variables of a package. Each variable is set by its own
+// package variable initializer, which depends on it only, so that it is set right after being initialized and before
+// any other package variable initialized from it, e.g. 'var Version = "v" + ReleaseVersion'.
+var releaseInfoTemplate = template.Must(template.New(releaseInfoFile).Parse(`// Code generated by rego. DO NOT EDIT.
+
+//go:build ` + releaseInfoTag + `
+
+package {{.Package}}
+{{range .Variables}}
+var _ = func() bool { {{index . 0}} = {{printf "%q" (index . 1)}}; return true }()
+{{- end}}
+`))
+
+// ValidReleaseInfo returns an error if the specified way of embedding the release information is not supported.
+func ValidReleaseInfo(mode string) error {
+	if !releaseInfoModes[mode] {
+		return newError(UsageError, "unknown release information mode '%v', either '%v' or '%v' is expected", mode, ldflagsReleaseInfo, sourceReleaseInfo)
+	}
+	return nil
+}
+
+// writeReleaseInfo generates a '0_rego_release_info.go' file into each package declaring any of the specified
+// qualified variables in the files selected by the build, only the declared variables are set the same way the '-X'
+// linker flags ignore the other ones, while a declared variable that is not a string fails the build. It returns the
+// paths of the written files, which are returned along with an error as well so that they can be removed.
+func (g *GoTools) writeReleaseInfo(variables [][2]string) ([]string, error) {
+	var packages []string
+	var written []string
+
+	values := make(map[string][][2]string)
+
+	for _, v := range variables {
+		i := strings.LastIndex(v[0], ".")
+		pkg := v[0][:i]
+
+		if _, found := values[pkg]; !found {
+			packages = append(packages, pkg)
+		}
+
+		values[pkg] = append(values[pkg], [2]string{v[0][i+1:], v[1]})
+	}
+
+	sort.Strings(packages)
+
+	for _, pkg := range packages {
+		path := pkg
+
+		// the main package is the one being built.
+		if path == "main" {
+			if path = g.Package; len(path) == 0 {
+				path = "."
+			}
+		}
+
+		files, err := g.packageFiles(path)
+
+		if err != nil {
+			return written, err
+		} else if len(files) == 0 {
+			continue
+		}
+
+		name, declarations, err := fileDeclarations(files)
+
+		if err != nil {
+			return written, err
+		}
+
+		var declared [][2]string
+
+		for _, v := range values[pkg] {
+			if kind, found := declarations[v[0]]; found && kind != token.VAR {
+				return written, newError(BuildError, "release variable '%v.%v' is declared as a %v and can not be set", pkg, v[0], kind)
+			} else if found {
+				declared = append(declared, v)
+			}
+		}
+
+		if len(declared) == 0 {
+			continue
+		}
+
+		dir := filepath.Dir(files[0])
+
+		for _, f := range files {
+			if filepath.Base(f) < releaseInfoFile {
+				return written, newError(BuildError, "'%v' is compiled before the generated '%v', rename it so that the release variables can be set first", f, releaseInfoFile)
+			}
+		}
+
+		var b bytes.Buffer
+
+		err = releaseInfoTemplate.Execute(&b, struct {
+			Package   string
+			Variables [][2]string
+		}{name, declared})
+
+		if err != nil {
+			return written, err
+		}
+
+		content, err := format.Source(b.Bytes())
+
+		if err != nil {
+			return written, err
+		}
+
+		file := filepath.Join(dir, releaseInfoFile)
+
+		if err = writeNewFile(file, content); os.IsExist(err) {
+			return written, newError(BuildError, "'%v' already exists, it is generated while building and must not be committed", file)
+		} else if err != nil {
+			return written, err
+		}
+
+		loggerOrDiscard(g.Logger).Debug("release information generated", "file", file)
+
+		written = append(written, file)
+	}
+
+	return written, nil
+}
+
+// removeReleaseInfo removes the generated files, it returns the first error encountered.
+func removeReleaseInfo(files []string) error {
+	var err error
+
+	for _, f := range files {
+		if e := os.Remove(f); e != nil && !os.IsNotExist(e) && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// fileDeclarations parses the specified Go files of a package, it returns the package name along with the kinds of
+// the package level constants and variables (i.e. token.CONST or token.VAR) by their names.
+func fileDeclarations(files []string) (string, map[string]token.Token, error) {
+	var name string
+
+	declarations := make(map[string]token.Token)
+	fset := token.NewFileSet()
+
+	for _, f := range files {
+		file, err := parser.ParseFile(fset, f, nil, parser.SkipObjectResolution)
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		name = file.Name.Name
+
+		for _, decl := range file.Decls {
+			if d, ok := decl.(*ast.GenDecl); ok && (d.Tok == token.VAR || d.Tok == token.CONST) {
+				for _, spec := range d.Specs {
+					for _, n := range spec.(*ast.ValueSpec).Names {
+						declarations[n.Name] = d.Tok
+					}
+				}
+			}
+		}
+	}
+
+	return name, declarations, nil
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func releaseInfoPackage(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+const packageFilesFormat = "{{.Dir}}{{range .GoFiles}}\t{{.}}{{end}}{{range .CgoFiles}}\t{{.}}{{end}}"
+
+func TestGoTools_WriteReleaseInfo(t *testing.T) {
+	main := releaseInfoPackage(t, map[string]string{
+		"main.go":   "package main\n\nvar GitCommit, ReleaseVersion = defaultCommit, \"\"\n\nfunc main() {}\n",
+		"server.go": "package main\n\nconst defaultCommit = \"none\"\n",
+	})
+	version := releaseInfoPackage(t, map[string]string{"version.go": "package version\n\nvar Commit string\n"})
+
+	fake := NewFakeCommander(
+		CommandCall{Name: "go", Args: []string{"list", "-f", packageFilesFormat, "example.com/project/version"}, Output: version + "\tversion.go"},
+		CommandCall{Name: "go", Args: []string{"list", "-f", packageFilesFormat, "example.com/project/cmd/api"}, Output: main + "\tmain.go\tserver.go"},
+	)
+
+	g := &GoTools{WorkDir: "/work", Commander: fake, Package: "example.com/project/cmd/api"}
+
+	files, err := g.writeReleaseInfo([][2]string{
+		{"example.com/project/version.Commit", "abc"},
+		{"main.ReleaseVersion", `1.0 "quoted"`},
+		{"main.BuildTimestamp", "now"},
+		{"main.GitCommit", "abc"},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(version, releaseInfoFile), filepath.Join(main, releaseInfoFile)}, files)
+
+	assert.Empty(t, fake.Remaining())
+	assert.Empty(t, fake.Unexpected())
+
+	content, err := ioutil.ReadFile(filepath.Join(main, releaseInfoFile))
+	assert.Nil(t, err)
+	assert.Equal(t, `// Code generated by rego. DO NOT EDIT.
+
+//go:build rego_release_info
+
+package main
+
+var _ = func() bool { ReleaseVersion = "1.0 \"quoted\""; return true }()
+var _ = func() bool { GitCommit = "abc"; return true }()
+`, string(content))
+
+	content, err = ioutil.ReadFile(filepath.Join(version, releaseInfoFile))
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), "package version\n")
+	assert.Contains(t, string(content), "Commit = \"abc\"")
+
+	// a file that already exists is neither overwritten nor removed.
+	fake = NewFakeCommander(CommandCall{Name: "go", Args: []string{"list", "-f", packageFilesFormat, "."}, Output: main + "\tmain.go"})
+	g = &GoTools{WorkDir: "/work", Commander: fake}
+
+	files, err = g.writeReleaseInfo([][2]string{{"main.GitCommit", "def"}})
+	assert.Empty(t, files)
+
+	category, _ := Categorize(err)
+	assert.Equal(t, BuildError, category)
+
+	assert.Nil(t, removeReleaseInfo([]string{filepath.Join(main, releaseInfoFile), filepath.Join(version, releaseInfoFile)}))
+
+	_, err = os.Stat(filepath.Join(main, releaseInfoFile))
+	assert.True(t, os.IsNotExist(err))
+	assert.Nil(t, removeReleaseInfo([]string{filepath.Join(main, releaseInfoFile)}))
+}
+
+func TestGoTools_WriteReleaseInfo_Failure(t *testing.T) {
+	dir := releaseInfoPackage(t, map[string]string{
+		"00_first.go": "package main\n\nvar GitCommit string\n",
+		"main.go":     "package main\n\nconst ReleaseVersion = \"dev\"\n\nfunc main() {}\n",
+	})
+
+	for _, c := range []struct {
+		files    string
+		variable string
+		message  string
+	}{
+		{"\tmain.go", "main.ReleaseVersion", "release variable 'main.ReleaseVersion' is declared as a const and can not be set"},
+		{"\t00_first.go\tmain.go", "main.GitCommit", "'" + filepath.Join(dir, "00_first.go") + "' is compiled before the generated '" + releaseInfoFile + "', rename it so that the release variables can be set first"},
+	} {
+		fake := NewFakeCommander(CommandCall{Name: "go", Args: []string{"list", "-f", packageFilesFormat, "."}, Output: dir + c.files})
+
+		files, err := (&GoTools{WorkDir: dir, Commander: fake}).writeReleaseInfo([][2]string{{c.variable, "1.0"}})
+
+		assert.Empty(t, files)
+		assert.EqualError(t, err, c.message)
+
+		category, _ := Categorize(err)
+		assert.Equal(t, BuildError, category)
+	}
+
+	_, err := os.Stat(filepath.Join(dir, releaseInfoFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGoTools_Install_SourceReleaseInfo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	main := `package main
+
+import "fmt"
+
+type commit string
+
+const defaultCommit = "unknown"
+
+var (
+	Version             = "v" + ReleaseVersion
+	GitCommit    commit = defaultCommit
+	GoVersion, _        = toolchain()
+)
+
+func toolchain() (string, error) { return "go", nil }
+
+func main() {
+	fmt.Println(Version, GitCommit, GoVersion)
+}
+`
+	dir := releaseInfoPackage(t, map[string]string{
+		"go.mod":     "module example.com/fixture\n\ngo 1.16\n",
+		"main.go":    main,
+		"version.go": "package main\n\nvar ReleaseVersion string\n",
+		// a file left out of the build does not declare any variable of the build.
+		"legacy.go": "//go:build ignore\n\npackage main\n\nvar BuildTimestamp int\n",
+	})
+	bin := t.TempDir()
+	recorder := &RecordingCommander{}
+
+	g := &GoTools{
+		WorkDir:   dir,
+		Commander: recorder,
+		Env:       []string{"GOBIN=" + bin},
+		Options:   BuildOptions{ReleaseInfo: sourceReleaseInfo},
+		Timestamp: time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC),
+		version:   "go1.27.1",
+	}
+
+	if err := g.Install("abc", "1.0", "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := NewNamedCommand(filepath.Join(bin, "fixture"), dir).Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, "v1.0 abc go1.27.1", out)
+
+	// the release information is only passed through the generated file.
+	calls := recorder.Calls()
+
+	if assert.NotEmpty(t, calls) {
+		assert.Equal(t, []string{"install", "-tags", releaseInfoTag}, calls[len(calls)-1].Args)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, releaseInfoFile))
+	assert.True(t, os.IsNotExist(err))
+
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Equal(t, main, string(content))
+
+	// the generated file is removed even though the build has failed, a declared variable that is not a string can
+	// not be set.
+	if err = ioutil.WriteFile(filepath.Join(dir, "timestamp.go"), []byte("package main\n\nvar BuildTimestamp int\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.Install("abc", "1.0", "main")
+
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), releaseInfoFile)
+	}
+
+	category, _ := Categorize(err)
+	assert.Equal(t, BuildError, category)
+
+	_, err = os.Stat(filepath.Join(dir, releaseInfoFile))
+	assert.True(t, os.IsNotExist(err))
+
+	g.Options.ReleaseInfo = "linker"
+
+	category, _ = Categorize(g.Install("abc", "1.0", "main"))
+	assert.Equal(t, UsageError, category)
+}
+
+func TestValidReleaseInfo(t *testing.T) {
+	assert.Nil(t, ValidReleaseInfo(""))
+	assert.Nil(t, ValidReleaseInfo(ldflagsReleaseInfo))
+	assert.Nil(t, ValidReleaseInfo(sourceReleaseInfo))
+	assert.NotNil(t, ValidReleaseInfo("embed"))
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const defaultRemote = "origin"
+
+// RemoteCheck verifies that a release is taken from commits already pushed to a remote repository.
+type RemoteCheck struct {
+	// Remote is the name or the URL of the remote repository.
+	Remote string
+	// Offline skips fetching and relies on the existing remote-tracking branches, the tags can not be verified then.
+	Offline bool
+	// Logger receives the progress of the checks at the debug level, nothing is logged if not set.
+	Logger *slog.Logger
+}
+
+// Check verifies that the specified reference is published, which is that its commit is reachable from the
+// upstream branch if it's tracked from the remote, otherwise from the remote-tracking branch of the same name (or from any remote-tracking branch if it's not a branch), that a local
+// branch is not behind its upstream branch, and that a tag exists in the remote repository pointing to the same commit.
+// It fetches the remote unless offline and returns an error describing every failed check.
+func (r RemoteCheck) Check(g *Git, ref Reference) error {
+	var problems []string
+	var err error
+
+	if !r.Offline {
+		if err = g.Fetch(r.Remote); err != nil {
+			return err
+		}
+	}
+
+	switch ref.Kind {
+	case BranchReference:
+		upstream := r.Remote + "/" + ref.Name
+
+		// the upstream branch is only relevant if it's tracked from the checked remote.
+		if remote, e := g.UpstreamRemote(ref.Name); e == nil && remote == r.Remote {
+			if u, e := g.Upstream(ref.Name); e == nil {
+				upstream = u
+			}
+		}
+
+		var behind int
+
+		if problem, e := r.reachable(g, ref.Commit, upstream); e != nil {
+			return e
+		} else if len(problem) > 0 {
+			problems = append(problems, problem)
+		} else if behind, err = g.CountCommits(ref.Commit, upstream); err != nil {
+			return err
+		} else if behind > 0 {
+			problems = append(problems, fmt.Sprintf("branch '%v' is behind '%v' by %v commits", ref.Name, upstream, behind))
+		}
+	case RemoteBranchReference:
+		if problem, e := r.reachable(g, ref.Commit, ref.Name); e != nil {
+			return e
+		} else if len(problem) > 0 {
+			problems = append(problems, problem)
+		}
+	default:
+		var branches []string
+
+		if branches, err = g.RemoteBranchesContaining(r.Remote, ref.Commit); err != nil {
+			return err
+		} else if len(branches) == 0 {
+			problems = append(problems, fmt.Sprintf("commit '%v' is not found in any branch of remote '%v'", ref.Commit, r.Remote))
+		} else {
+			loggerOrDiscard(r.Logger).Debug("commit is found in remote branches", "commit", ref.Commit, "branches", branches)
+		}
+	}
+
+	if ref.Kind == TagReference {
+		if r.Offline {
+			loggerOrDiscard(r.Logger).Debug("skipping the tag verification while offline", "tag", ref.Name, "remote", r.Remote)
+		} else if commit, e := g.RemoteTagCommit(r.Remote, ref.Name); e != nil {
+			problems = append(problems, e.Error())
+		} else if commit != ref.Commit {
+			problems = append(problems, fmt.Sprintf("tag '%v' points to commit '%v' in remote '%v' instead of '%v'", ref.Name, commit, r.Remote, ref.Commit))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("the release is not in sync with remote '%v':%v %v", r.Remote, NewLine(), strings.Join(problems, NewLine()+" "))
+	}
+
+	return nil
+}
+
+// reachable returns a problem description if the specified commit is not reachable from the specified remote-tracking
+// branch, or an empty string if it is.
+func (r RemoteCheck) reachable(g *Git, commit, branch string) (string, error) {
+	if _, err := g.ResolveReference(branch); err != nil {
+		return fmt.Sprintf("branch '%v' is not found", branch), nil
+	} else if ok, err := g.IsAncestor(commit, branch); err != nil {
+		return "", err
+	} else if !ok {
+		return fmt.Sprintf("commit '%v' is not found in '%v'", commit, branch), nil
+	}
+
+	loggerOrDiscard(r.Logger).Debug("commit is found in branch", "commit", commit, "branch", branch)
+
+	return "", nil
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RemoteTestSuite struct {
+	suite.Suite
+	root  string
+	git   *Git
+	local Command
+	other Command
+}
+
+func (suite *RemoteTestSuite) SetupTest() {
+	var err error
+
+	if suite.root, err = ioutil.TempDir("", "test_rego_remote_"); err != nil {
+		suite.Fail("failed to create temporary directory before test setup", err.Error())
+		return
+	}
+
+	origin := filepath.Join(suite.root, "origin.git")
+
+	if _, err = NewNamedCommand("git", suite.root).Execute("init", "--bare", origin); err != nil {
+		suite.Fail("failed to create the bare repository before test setup", err.Error())
+	}
+
+	for _, name := range []string{"local", "other"} {
+		if _, err = NewNamedCommand("git", suite.root).Execute("clone", "--quiet", origin, name); err != nil {
+			suite.Fail("failed to clone the bare repository before test setup", err.Error())
+		}
+
+		NewNamedCommand("git", filepath.Join(suite.root, name)).Execute("config", "commit.gpgsign", "false")
+	}
+
+	suite.git = &Git{WorkDir: filepath.Join(suite.root, "local")}
+	suite.local = NewNamedCommand("git", suite.git.WorkDir)
+	suite.other = NewNamedCommand("git", filepath.Join(suite.root, "other"))
+
+	suite.commit(suite.local, "'Initial commit'")
+
+	if _, err = suite.local.Execute("push", "--quiet", "origin", "HEAD:master"); err != nil {
+		suite.Fail("failed to push before test setup", err.Error())
+	}
+
+	if _, err = suite.other.Execute("pull", "--quiet", "origin", "master"); err != nil {
+		suite.Fail("failed to pull before test setup", err.Error())
+	}
+}
+
+func (suite *RemoteTestSuite) TearDownTest() {
+	if len(suite.root) > 0 {
+		if err := os.RemoveAll(suite.root); err != nil {
+			suite.Fail("failed to remove temporary directory after test teardown", err.Error())
+		}
+	}
+}
+
+func TestRemoteTestSuite(t *testing.T) {
+	suite.Run(t, new(RemoteTestSuite))
+}
+
+func (suite *RemoteTestSuite) commit(git Command, message string) {
+	if _, err := git.Execute("commit", "-n", "--allow-empty", "-m", message); err != nil {
+		suite.Fail("failed to commit", err.Error())
+	}
+}
+
+func (suite *RemoteTestSuite) reference(expr string) Reference {
+	ref, err := suite.git.ResolveReference(expr)
+
+	if err != nil {
+		suite.Fail("failed to resolve reference", err.Error())
+	}
+
+	return ref
+}
+
+func (suite *RemoteTestSuite) TestCheck_BranchPushed() {
+	err := RemoteCheck{Remote: "origin"}.Check(suite.git, suite.reference("master"))
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *RemoteTestSuite) TestCheck_BranchNotPushed() {
+	suite.commit(suite.local, "'Unpushed'")
+
+	ref := suite.reference("master")
+	err := RemoteCheck{Remote: "origin"}.Check(suite.git, ref)
+	assert.EqualError(suite.T(), err, "the release is not in sync with remote 'origin':"+NewLine()+
+		" commit '"+ref.Commit+"' is not found in 'origin/master'")
+}
+
+func (suite *RemoteTestSuite) TestCheck_BranchUpstreamOtherRemote() {
+	mirror := filepath.Join(suite.root, "mirror.git")
+
+	if _, err := NewNamedCommand("git", suite.root).Execute("init", "--bare", mirror); err != nil {
+		suite.Fail("failed to create the mirror repository", err.Error())
+	}
+
+	suite.commit(suite.local, "'Pushed to the mirror'")
+	suite.local.Execute("remote", "add", "mirror", mirror)
+	suite.local.Execute("push", "--quiet", "--set-upstream", "mirror", "master")
+
+	err := RemoteCheck{Remote: "mirror"}.Check(suite.git, suite.reference("master"))
+	assert.Nil(suite.T(), err)
+
+	ref := suite.reference("master")
+	err = RemoteCheck{Remote: "origin"}.Check(suite.git, ref)
+	assert.EqualError(suite.T(), err, "the release is not in sync with remote 'origin':"+NewLine()+
+		" commit '"+ref.Commit+"' is not found in 'origin/master'")
+}
+
+func (suite *RemoteTestSuite) TestCheck_BranchBehind() {
+	suite.commit(suite.other, "'Pushed elsewhere'")
+	suite.other.Execute("push", "--quiet", "origin", "master")
+
+	err := RemoteCheck{Remote: "origin", Offline: true}.Check(suite.git, suite.reference("master"))
+	assert.Nil(suite.T(), err)
+
+	err = RemoteCheck{Remote: "origin"}.Check(suite.git, suite.reference("master"))
+	assert.EqualError(suite.T(), err, "the release is not in sync with remote 'origin':"+NewLine()+
+		" branch 'master' is behind 'origin/master' by 1 commits")
+}
+
+func (suite *RemoteTestSuite) TestCheck_RemoteBranch() {
+	suite.commit(suite.other, "'Pushed elsewhere'")
+	suite.other.Execute("push", "--quiet", "origin", "master")
+	suite.local.Execute("fetch", "--quiet", "origin")
+
+	err := RemoteCheck{Remote: "origin", Offline: true}.Check(suite.git, suite.reference("origin/master"))
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *RemoteTestSuite) TestCheck_CommitNotPushed() {
+	suite.commit(suite.local, "'Unpushed'")
+	suite.local.Execute("checkout", "--quiet", "-b", "feature")
+
+	ref := suite.reference("feature")
+	ref.Kind = CommitReference
+
+	err := RemoteCheck{Remote: "origin"}.Check(suite.git, ref)
+	assert.EqualError(suite.T(), err, "the release is not in sync with remote 'origin':"+NewLine()+
+		" commit '"+ref.Commit+"' is not found in any branch of remote 'origin'")
+}
+
+func (suite *RemoteTestSuite) TestCheck_TagPushed() {
+	suite.local.Execute("tag", "-a", "-m", "'Annotated'", "v1.0")
+	suite.local.Execute("push", "--quiet", "origin", "v1.0")
+
+	err := RemoteCheck{Remote: "origin"}.Check(suite.git, suite.reference("v1.0"))
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *RemoteTestSuite) TestCheck_TagNotPushed() {
+	suite.local.Execute("tag", "v1.0")
+
+	err := RemoteCheck{Remote: "origin"}.Check(suite.git, suite.reference("v1.0"))
+	assert.EqualError(suite.T(), err, "the release is not in sync with remote 'origin':"+NewLine()+
+		" tag 'v1.0' is not found in remote 'origin'")
+
+	err = RemoteCheck{Remote: "origin", Offline: true}.Check(suite.git, suite.reference("v1.0"))
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *RemoteTestSuite) TestCheck_TagMismatch() {
+	suite.commit(suite.other, "'Pushed elsewhere'")
+	suite.other.Execute("tag", "v1.0")
+	suite.other.Execute("push", "--quiet", "origin", "master", "v1.0")
+
+	suite.local.Execute("tag", "v1.0")
+
+	ref := suite.reference("v1.0")
+	remote, _ := suite.other.Execute("rev-parse", "v1.0")
+
+	err := RemoteCheck{Remote: "origin", Offline: true}.Check(suite.git, ref)
+	assert.Nil(suite.T(), err)
+
+	err = RemoteCheck{Remote: "origin"}.Check(suite.git, ref)
+	assert.EqualError(suite.T(), err, "the release is not in sync with remote 'origin':"+NewLine()+
+		" tag 'v1.0' points to commit '"+remote+"' in remote 'origin' instead of '"+ref.Commit+"'")
+}
+
+func (suite *RemoteTestSuite) TestCheck_FailureUnknownRemote() {
+	err := RemoteCheck{Remote: "unknown"}.Check(suite.git, suite.reference("master"))
+	assert.NotNil(suite.T(), err)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+// The git backends.
+const (
+	execGitBackend   = "exec"
+	nativeGitBackend = "native"
+)
+
+// gitBackends is the set of the supported git backends.
+var gitBackends = map[string]bool{execGitBackend: true, nativeGitBackend: true}
+
+// Repository is the read-only view of a git repository used to pick and validate a release, implemented by Git which
+// runs the git binary, and by NativeGit which reads the '.git' directory directly.
+type Repository interface {
+	// IsCommitExists takes a git commit hash and returns true if it's found, false otherwise.
+	IsCommitExists(hash string) (bool, error)
+	// GetTagCommit returns the hash of the object the specified tag points to.
+	GetTagCommit(tag string) (string, error)
+	// GetBranchCommit returns the git commit hash of the specified git branch.
+	GetBranchCommit(branch string) (string, error)
+	// HeadCommit returns the git commit hash currently checked out.
+	HeadCommit() (string, error)
+	// TopLevel returns the absolute path of the repository root directory.
+	TopLevel() (string, error)
+	// ResolveReference resolves a git revision expression to a full commit hash along with the kind and the name of
+	// the reference it is based on.
+	ResolveReference(expr string) (Reference, error)
+	// DefaultBranch detects the branch to release from when none is specified, along with the rule that picked it.
+	DefaultBranch() (string, string, error)
+	// Tags returns the names of all the tags.
+	Tags() ([]string, error)
+	// IsAncestor returns true if the specified commit is reachable from the specified reference.
+	IsAncestor(commit, ref string) (bool, error)
+	// PreviousTag returns the most recent tag matching the specified glob pattern reachable from the specified commit.
+	PreviousTag(commit, pattern string) (string, error)
+	// Describe returns the nearest tag the specified commit is reachable from, along with the number of commits since
+	// and the abbreviated commit hash if it is not the tagged commit itself.
+	Describe(commit string) (string, error)
+	// LastCommit returns the most recent commit reachable from the specified one that changed any of the specified
+	// paths.
+	LastCommit(commit string, paths ...string) (string, error)
+	// Status returns the changed paths of the working tree, under the specified paths if any.
+	Status(paths ...string) (Status, error)
+}
@@ -0,0 +1,472 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	_ Repository = &Git{}
+	_ Repository = &NativeGit{}
+)
+
+// repositoryFixture is a repository both backends are run against, the working directory may be a subdirectory
+// or a linked worktree of the repository.
+type repositoryFixture struct {
+	name    string
+	workDir string
+}
+
+type RepositoryConformanceSuite struct {
+	suite.Suite
+	root     string
+	fixtures []repositoryFixture
+}
+
+func TestRepositoryConformanceSuite(t *testing.T) {
+	suite.Run(t, new(RepositoryConformanceSuite))
+}
+
+// run runs git in the specified directory and fails the suite on failure.
+func (suite *RepositoryConformanceSuite) run(dir string, args ...string) string {
+	out, err := NewNamedCommand("git", dir).Execute(args...)
+
+	if err != nil {
+		suite.FailNow(fmt.Sprintf("git %v", strings.Join(args, " ")), err.Error())
+	}
+
+	return out
+}
+
+// write writes the specified content to the specified file of the specified directory, creating its parents.
+func (suite *RepositoryConformanceSuite) write(dir, file, content string, mode os.FileMode) {
+	p := filepath.Join(dir, filepath.FromSlash(file))
+
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		suite.FailNow("failed to create the parent directories", err.Error())
+	} else if err = ioutil.WriteFile(p, []byte(content), mode); err != nil {
+		suite.FailNow("failed to write file", err.Error())
+	} else if err = os.Chmod(p, mode); err != nil {
+		suite.FailNow("failed to change the file mode", err.Error())
+	}
+}
+
+// fixtureContent returns a text of many lines where only the specified line differs between the revisions,
+// so that the revisions are stored as deltas once packed.
+func fixtureContent(revision, line int) string {
+	var b strings.Builder
+
+	for i := 0; i < 200; i++ {
+		if i == line {
+			fmt.Fprintf(&b, "revision %v of line %v\n", revision, i)
+		} else {
+			fmt.Fprintf(&b, "the unchanged line number %v of the fixture file\n", i)
+		}
+	}
+
+	return b.String()
+}
+
+// base creates a repository with lightweight and annotated tags, branches, remote-tracking references,
+// an executable file and ignore rules.
+func (suite *RepositoryConformanceSuite) base(name string) string {
+	dir := filepath.Join(suite.root, name)
+
+	suite.run(suite.root, "init", "-q", "-b", "master", dir)
+	suite.run(dir, "config", "commit.gpgsign", "false")
+	suite.run(dir, "config", "tag.gpgsign", "false")
+
+	suite.write(dir, "a.txt", fixtureContent(0, 0), 0644)
+	suite.write(dir, ".gitignore", "*.log\nbuild/\n!keep.log\n/root-only.txt\n", 0644)
+	suite.run(dir, "add", ".")
+	suite.run(dir, "commit", "-q", "-m", "first")
+	suite.run(dir, "tag", "v1.0")
+	suite.run(dir, "branch", "develop")
+
+	for i := 1; i <= 5; i++ {
+		suite.write(dir, "a.txt", fixtureContent(i, i*10), 0644)
+		suite.run(dir, "commit", "-q", "-am", fmt.Sprintf("revision %v", i))
+	}
+
+	suite.run(dir, "tag", "-a", "-m", "version 2.0", "v2.0")
+
+	suite.write(dir, "sub/b.txt", "b\n", 0644)
+	suite.write(dir, "sub/.gitignore", "*.tmp\n", 0644)
+	suite.write(dir, "run.sh", "#!/bin/sh\necho run\n", 0755)
+	suite.run(dir, "add", ".")
+	suite.run(dir, "commit", "-q", "-m", "third")
+	suite.run(dir, "tag", "-a", "-m", "nested", "v2.0-nested", "v2.0")
+
+	suite.run(dir, "checkout", "-q", "develop")
+	suite.write(dir, "c.txt", "c\n", 0644)
+	suite.run(dir, "add", ".")
+	suite.run(dir, "commit", "-q", "-m", "develop")
+	suite.run(dir, "tag", "-a", "-m", "release candidate", "v2.1-rc1")
+	suite.run(dir, "checkout", "-q", "master")
+
+	suite.run(dir, "update-ref", "refs/remotes/origin/main", "HEAD~1")
+	suite.run(dir, "symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/main")
+
+	return dir
+}
+
+// dirty makes every kind of change to the working tree and the index of the specified repository.
+func (suite *RepositoryConformanceSuite) dirty(dir string) {
+	suite.write(dir, "a.txt", fixtureContent(9, 100), 0644)
+	suite.write(dir, "sub/b.txt", "staged\n", 0644)
+	suite.run(dir, "add", "sub/b.txt")
+	suite.write(dir, "sub/b.txt", "staged then modified\n", 0755)
+	suite.write(dir, "d.txt", "added\n", 0644)
+	suite.run(dir, "add", "d.txt")
+
+	if err := os.Remove(filepath.Join(dir, "run.sh")); err != nil {
+		suite.FailNow("failed to remove 'run.sh'", err.Error())
+	}
+
+	suite.write(dir, "untracked.txt", "u\n", 0644)
+	suite.write(dir, "sub/deep/untracked.txt", "u\n", 0644)
+	suite.write(dir, "ignored.log", "i\n", 0644)
+	suite.write(dir, "keep.log", "k\n", 0644)
+	suite.write(dir, "build/out.bin", "o\n", 0644)
+	suite.write(dir, "sub/ignored.tmp", "t\n", 0644)
+	suite.write(dir, "root-only.txt", "r\n", 0644)
+	suite.write(dir, "sub/root-only.txt", "r\n", 0644)
+}
+
+func (suite *RepositoryConformanceSuite) SetupSuite() {
+	var err error
+
+	if suite.root, err = ioutil.TempDir("", "test_rego_conformance_"); err != nil {
+		suite.FailNow("failed to create temporary directory", err.Error())
+	} else if suite.root, err = filepath.EvalSymlinks(suite.root); err != nil {
+		suite.FailNow("failed to resolve temporary directory", err.Error())
+	}
+
+	loose := suite.base("loose")
+
+	packed := suite.base("packed")
+	suite.run(packed, "merge", "-q", "--no-ff", "-m", "merge develop", "develop")
+	suite.run(packed, "gc", "-q", "--aggressive")
+	suite.write(packed, "after-gc.txt", "loose\n", 0644)
+	suite.run(packed, "add", ".")
+	suite.run(packed, "commit", "-q", "-m", "after gc")
+	suite.run(packed, "tag", "v3.0")
+
+	dirty := suite.base("dirty")
+	suite.dirty(dirty)
+
+	indexV4 := suite.base("index-v4")
+	suite.dirty(indexV4)
+	suite.run(indexV4, "update-index", "--index-version", "4")
+
+	conflicted := suite.base("conflicted")
+	suite.run(conflicted, "checkout", "-q", "-b", "topic", "HEAD~1")
+	suite.write(conflicted, "a.txt", fixtureContent(7, 0), 0644)
+	suite.run(conflicted, "commit", "-q", "-am", "topic")
+	suite.run(conflicted, "checkout", "-q", "master")
+	suite.write(conflicted, "a.txt", fixtureContent(8, 0), 0644)
+	suite.run(conflicted, "commit", "-q", "-am", "master")
+	NewNamedCommand("git", conflicted).Execute("merge", "-q", "topic")
+
+	detached := suite.base("detached")
+	suite.run(detached, "checkout", "-q", "--detach", "HEAD~2")
+
+	worktree := filepath.Join(suite.root, "worktree")
+	suite.run(loose, "worktree", "add", "-q", worktree, "develop")
+
+	suite.fixtures = []repositoryFixture{
+		{"loose", loose},
+		{"packed", packed},
+		{"dirty", dirty},
+		{"dirty subdirectory", filepath.Join(dirty, "sub")},
+		{"index version 4", indexV4},
+		{"conflicted", conflicted},
+		{"detached", detached},
+		{"linked worktree", worktree},
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TearDownSuite() {
+	if len(suite.root) > 0 {
+		os.RemoveAll(suite.root)
+	}
+}
+
+// conform runs the specified query against both backends for every fixture and asserts that they either both fail
+// or both return the same result.
+func (suite *RepositoryConformanceSuite) conform(query string, f func(r Repository) (interface{}, error)) {
+	for _, fixture := range suite.fixtures {
+		expected, expectedErr := f(&Git{WorkDir: fixture.workDir})
+		actual, actualErr := f(&NativeGit{WorkDir: fixture.workDir})
+
+		message := fmt.Sprintf("%v on the %v fixture", query, fixture.name)
+
+		if expectedErr != nil {
+			assert.NotNil(suite.T(), actualErr, "%v: git failed with '%v'", message, expectedErr)
+		} else if assert.Nil(suite.T(), actualErr, message) {
+			assert.Equal(suite.T(), expected, actual, message)
+		}
+	}
+}
+
+var conformanceExpressions = []string{
+	"HEAD", "@", "HEAD~1", "HEAD~3", "HEAD^", "HEAD^1", "HEAD^2", "HEAD^0", "HEAD~100", "master", "develop",
+	"master~2^", "v1.0", "v2.0", "v2.0^{}", "v2.0^{commit}", "v2.0-nested", "v2.0-nested^{}", "v2.1-rc1",
+	"origin/main", "origin", "refs/heads/develop", "heads/develop", "tags/v1.0", "missing", "v9.9^{}", "",
+}
+
+func (suite *RepositoryConformanceSuite) TestResolveReference() {
+	for _, expr := range conformanceExpressions {
+		expr := expr
+		suite.conform(fmt.Sprintf("ResolveReference(%q)", expr), func(r Repository) (interface{}, error) {
+			return r.ResolveReference(expr)
+		})
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestResolveReference_Hashes() {
+	for _, fixture := range suite.fixtures {
+		head, err := (&Git{WorkDir: fixture.workDir}).HeadCommit()
+
+		if err != nil {
+			suite.FailNow("failed to get the head commit", err.Error())
+		}
+
+		for _, expr := range []string{head, head[:7], strings.ToUpper(head[:10]), head[:12] + "~1", "0000000"} {
+			expected, expectedErr := (&Git{WorkDir: fixture.workDir}).ResolveReference(expr)
+			actual, actualErr := (&NativeGit{WorkDir: fixture.workDir}).ResolveReference(expr)
+
+			assert.Equal(suite.T(), expectedErr == nil, actualErr == nil, "%v on %v", expr, fixture.name)
+			assert.Equal(suite.T(), expected, actual, "%v on %v", expr, fixture.name)
+		}
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestGetTagCommit() {
+	for _, tag := range []string{"v1.0", "v2.0", "v2.0-nested", "v2.1-rc1", "missing"} {
+		tag := tag
+		suite.conform(fmt.Sprintf("GetTagCommit(%q)", tag), func(r Repository) (interface{}, error) {
+			return r.GetTagCommit(tag)
+		})
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestGetBranchCommit() {
+	for _, branch := range []string{"master", "develop", "topic", "missing"} {
+		branch := branch
+		suite.conform(fmt.Sprintf("GetBranchCommit(%q)", branch), func(r Repository) (interface{}, error) {
+			return r.GetBranchCommit(branch)
+		})
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestIsCommitExists() {
+	for _, fixture := range suite.fixtures {
+		g := &Git{WorkDir: fixture.workDir}
+		head, _ := g.HeadCommit()
+		tag, _ := g.GetTagCommit("v2.0")
+		tree, _ := g.withGit().Execute("rev-parse", "HEAD^{tree}")
+
+		for _, hash := range []string{head, tag, tree, head[:7], strings.ToUpper(head), "0123456789abcdef0123456789abcdef01234567"} {
+			expected, expectedErr := g.IsCommitExists(hash)
+			actual, actualErr := (&NativeGit{WorkDir: fixture.workDir}).IsCommitExists(hash)
+
+			assert.Nil(suite.T(), expectedErr)
+			assert.Nil(suite.T(), actualErr)
+			assert.Equal(suite.T(), expected, actual, "IsCommitExists(%q) on %v", hash, fixture.name)
+		}
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestHeadCommit() {
+	suite.conform("HeadCommit()", func(r Repository) (interface{}, error) {
+		return r.HeadCommit()
+	})
+}
+
+func (suite *RepositoryConformanceSuite) TestTopLevel() {
+	suite.conform("TopLevel()", func(r Repository) (interface{}, error) {
+		return r.TopLevel()
+	})
+}
+
+func (suite *RepositoryConformanceSuite) TestDefaultBranch() {
+	suite.conform("DefaultBranch()", func(r Repository) (interface{}, error) {
+		branch, rule, err := r.DefaultBranch()
+		return []string{branch, rule}, err
+	})
+}
+
+func (suite *RepositoryConformanceSuite) TestTags() {
+	suite.conform("Tags()", func(r Repository) (interface{}, error) {
+		return r.Tags()
+	})
+}
+
+func (suite *RepositoryConformanceSuite) TestIsAncestor() {
+	pairs := [][2]string{
+		{"v1.0", "HEAD"}, {"HEAD", "v1.0"}, {"develop", "master"}, {"v2.0", "master"}, {"HEAD~1", "origin/main"},
+		{"v2.1-rc1", "develop"}, {"missing", "HEAD"},
+	}
+
+	for _, pair := range pairs {
+		pair := pair
+		suite.conform(fmt.Sprintf("IsAncestor(%q, %q)", pair[0], pair[1]), func(r Repository) (interface{}, error) {
+			return r.IsAncestor(pair[0], pair[1])
+		})
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestPreviousTag() {
+	queries := [][2]string{
+		{"HEAD", ""}, {"HEAD^", ""}, {"v2.0^", ""}, {"develop", ""}, {"HEAD", "v1.*"}, {"HEAD", "v2.0-*"}, {"HEAD", "none*"},
+	}
+
+	for _, q := range queries {
+		q := q
+		suite.conform(fmt.Sprintf("PreviousTag(%q, %q)", q[0], q[1]), func(r Repository) (interface{}, error) {
+			return r.PreviousTag(q[0], q[1])
+		})
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestDescribe() {
+	for _, commit := range []string{"HEAD", "HEAD~1", "HEAD~2", "v1.0", "develop", "v2.0^"} {
+		commit := commit
+		suite.conform(fmt.Sprintf("Describe(%q)", commit), func(r Repository) (interface{}, error) {
+			return r.Describe(commit)
+		})
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestLastCommit() {
+	queries := [][]string{
+		{"HEAD"}, {"HEAD", "."}, {"HEAD", "sub"}, {"HEAD", "a.txt"}, {"HEAD", "c.txt"}, {"HEAD", "sub/b.txt", "c.txt"},
+		{"develop", "a.txt"}, {"v2.0", "sub"}, {"HEAD", "missing"}, {"missing", "a.txt"},
+	}
+
+	for _, q := range queries {
+		q := q
+		suite.conform(fmt.Sprintf("LastCommit(%q, %q)", q[0], q[1:]), func(r Repository) (interface{}, error) {
+			return r.LastCommit(q[0], q[1:]...)
+		})
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestStatus() {
+	for _, paths := range [][]string{nil, {"."}, {"sub"}, {"a.txt", "d.txt"}, {"missing"}} {
+		paths := paths
+		suite.conform(fmt.Sprintf("Status(%q)", paths), func(r Repository) (interface{}, error) {
+			return r.Status(paths...)
+		})
+	}
+}
+
+func (suite *RepositoryConformanceSuite) TestStatus_Dirty() {
+	status, err := (&NativeGit{WorkDir: suite.fixtures[2].workDir}).Status()
+
+	assert.Nil(suite.T(), err)
+	assert.Equal(suite.T(), Status{
+		{Path: "a.txt", Staged: '.', Unstaged: 'M'},
+		{Path: "d.txt", Staged: 'A', Unstaged: '.'},
+		{Path: "run.sh", Staged: '.', Unstaged: 'D'},
+		{Path: "sub/b.txt", Staged: 'M', Unstaged: 'M'},
+		{Path: "keep.log", Untracked: true},
+		{Path: "sub/deep/untracked.txt", Untracked: true},
+		{Path: "sub/root-only.txt", Untracked: true},
+		{Path: "untracked.txt", Untracked: true},
+	}, status)
+}
+
+func (suite *RepositoryConformanceSuite) TestPacked() {
+	packs, err := filepath.Glob(filepath.Join(suite.fixtures[1].workDir, ".git", "objects", "pack", "*.idx"))
+
+	if err != nil || len(packs) == 0 {
+		suite.FailNow("no pack is found")
+	}
+
+	out := suite.run(suite.fixtures[1].workDir, "verify-pack", "-v", packs[0])
+
+	// the deltified objects are listed along with their delta depth and base.
+	assert.Contains(suite.T(), out, "chain length")
+
+	_, err = os.Stat(filepath.Join(suite.fixtures[1].workDir, ".git", "packed-refs"))
+	assert.Nil(suite.T(), err)
+}
+
+func (suite *RepositoryConformanceSuite) TestNotRepository() {
+	dir, err := ioutil.TempDir("", "test_rego_not_repository_")
+
+	if err != nil {
+		suite.FailNow("failed to create temporary directory", err.Error())
+	}
+
+	defer os.RemoveAll(dir)
+
+	_, err = (&NativeGit{WorkDir: dir}).HeadCommit()
+
+	assert.NotNil(suite.T(), err)
+}
+
+func TestNativeGit_UnsupportedFormats(t *testing.T) {
+	for _, c := range []struct {
+		setup   func(dir string) error
+		message string
+	}{
+		{
+			setup: func(dir string) error {
+				_, err := NewNamedCommand("git", dir).Execute("config", "extensions.objectFormat", "sha256")
+				return err
+			},
+			message: "the 'sha256' object format of the repository is not supported by the 'native' git backend",
+		},
+		{
+			setup: func(dir string) error {
+				_, err := NewNamedCommand("git", dir).Execute("config", "extensions.refStorage", "reftable")
+				return err
+			},
+			message: "the 'reftable' references storage of the repository is not supported by the 'native' git backend",
+		},
+		{
+			setup: func(dir string) error {
+				return ioutil.WriteFile(filepath.Join(dir, ".git", "objects", "info", "alternates"), []byte("/elsewhere/objects\n"), 0600)
+			},
+			message: "the alternate object directories of the repository are not supported by the 'native' git backend",
+		},
+	} {
+		dir := nativeFixture(t)
+
+		if err := c.setup(dir); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := (&NativeGit{WorkDir: dir}).HeadCommit()
+		assert.EqualError(t, err, c.message)
+
+		category, _ := Categorize(err)
+		assert.Equal(t, GitError, category)
+	}
+}
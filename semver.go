@@ -0,0 +1,131 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var semanticVersionPattern = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// SemanticVersion is a version following the semantic versioning specification (https://semver.org),
+// optionally prefixed with 'v'.
+type SemanticVersion struct {
+	Major, Minor, Patch int
+	// Pre is the list of the dot separated pre-release identifiers, empty if it is not a pre-release.
+	Pre []string
+	// Build is the build metadata, which is ignored while comparing versions.
+	Build string
+}
+
+// ParseSemanticVersion parses the specified version, it returns an error if it is not a semantic version.
+func ParseSemanticVersion(version string) (SemanticVersion, error) {
+	var v SemanticVersion
+
+	m := semanticVersionPattern.FindStringSubmatch(strings.TrimSpace(version))
+
+	if m == nil {
+		return v, fmt.Errorf("invalid semantic version '%v'", version)
+	}
+
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+
+	if len(m[4]) > 0 {
+		v.Pre = strings.Split(m[4], ".")
+	}
+
+	v.Build = m[5]
+
+	return v, nil
+}
+
+// Prerelease returns true if this version is a pre-release.
+func (v SemanticVersion) Prerelease() bool {
+	return len(v.Pre) > 0
+}
+
+// String returns this version formatted without the 'v' prefix.
+func (v SemanticVersion) String() string {
+	s := fmt.Sprintf("%v.%v.%v", v.Major, v.Minor, v.Patch)
+
+	if v.Prerelease() {
+		s += "-" + strings.Join(v.Pre, ".")
+	}
+
+	if len(v.Build) > 0 {
+		s += "+" + v.Build
+	}
+
+	return s
+}
+
+// Compare returns -1, 0 or 1 if this version has a lower, the same or a higher precedence than the other one.
+func (v SemanticVersion) Compare(other SemanticVersion) int {
+	for _, d := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+
+	// a release has a higher precedence than its pre-releases.
+	if !v.Prerelease() || !other.Prerelease() {
+		return sign(len(other.Pre) - len(v.Pre))
+	}
+
+	for i := 0; i < len(v.Pre) && i < len(other.Pre); i++ {
+		if c := comparePrereleaseIdentifiers(v.Pre[i], other.Pre[i]); c != 0 {
+			return c
+		}
+	}
+
+	return sign(len(v.Pre) - len(other.Pre))
+}
+
+// comparePrereleaseIdentifiers compares numeric identifiers numerically, which have a lower precedence than the
+// alphanumeric ones that are compared lexically.
+func comparePrereleaseIdentifiers(a, b string) int {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+
+	switch {
+	case errA == nil && errB == nil:
+		return sign(x - y)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+
+	return strings.Compare(a, b)
+}
+
+func sign(d int) int {
+	switch {
+	case d < 0:
+		return -1
+	case d > 0:
+		return 1
+	}
+	return 0
+}
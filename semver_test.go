@@ -0,0 +1,61 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSemanticVersion(t *testing.T) {
+	v, err := ParseSemanticVersion("v1.2.3-rc.1+build.5")
+	assert.Nil(t, err)
+	assert.Equal(t, SemanticVersion{Major: 1, Minor: 2, Patch: 3, Pre: []string{"rc", "1"}, Build: "build.5"}, v)
+	assert.True(t, v.Prerelease())
+	assert.Equal(t, "1.2.3-rc.1+build.5", v.String())
+
+	v, err = ParseSemanticVersion("0.10.0")
+	assert.Nil(t, err)
+	assert.Equal(t, SemanticVersion{Minor: 10}, v)
+	assert.False(t, v.Prerelease())
+
+	for _, invalid := range []string{"", "SNAPSHOT", "1.2", "01.2.3", "1.2.3-", "1.2.3-01", "v1.2.3.4"} {
+		_, err = ParseSemanticVersion(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestSemanticVersion_Compare(t *testing.T) {
+	// ordered as in the semantic versioning specification.
+	versions := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11",
+		"1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0",
+	}
+
+	for i := range versions {
+		for j := range versions {
+			a, _ := ParseSemanticVersion(versions[i])
+			b, _ := ParseSemanticVersion(versions[j])
+			assert.Equal(t, sign(i-j), a.Compare(b), "%v <=> %v", versions[i], versions[j])
+		}
+	}
+
+	a, _ := ParseSemanticVersion("1.0.0+one")
+	b, _ := ParseSemanticVersion("v1.0.0+two")
+	assert.Equal(t, 0, a.Compare(b))
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The formats of the signatures, named after the 'gpg.format' git configuration values.
+const (
+	GPGSignatureFormat = "openpgp"
+	SSHSignatureFormat = "ssh"
+)
+
+// trustedLevels are the GPG trust levels of the keys whose user IDs are trusted to identify their owners.
+var trustedLevels = map[string]bool{"FULLY": true, "ULTIMATE": true}
+
+// sshSignaturePattern matches the output of 'ssh-keygen -Y verify' reported by git for a good SSH signature.
+var sshSignaturePattern = regexp.MustCompile(`Good "git" signature for (.+) with \S+ key (\S+)`)
+
+// SignatureRequirements configures the signatures a release must carry.
+type SignatureRequirements struct {
+	// Commit requires the target commit to carry a valid signature.
+	Commit bool `json:"commit"`
+	// Tag requires the released tag to carry a valid signature.
+	Tag bool `json:"tag"`
+	// AllowedSignersFile is the SSH allowed signers file used to verify SSH signatures ('gpg.ssh.allowedSignersFile').
+	AllowedSignersFile string `json:"allowedSignersFile"`
+	// Signers is the list of the allowed signers, each is either a signer identity (e.g. an email address)
+	// or a key fingerprint, any signer with a valid signature is allowed if empty. A GPG signature matches
+	// an identity only if its key is fully or ultimately trusted, otherwise its fingerprint (or the fingerprint
+	// of its primary key) must be listed, while an SSH signature matches the principal it is verified for
+	// through the allowed signers file.
+	Signers []string `json:"signers"`
+}
+
+// Signature is the verified signature of a git commit or tag.
+type Signature struct {
+	// Format is either 'openpgp' or 'ssh'.
+	Format string `json:"format"`
+	// Signer is the signer identity, the GPG user ID or the SSH principal.
+	Signer string `json:"signer"`
+	// Key is the GPG key fingerprint or the SSH key fingerprint.
+	Key string `json:"key"`
+	// PrimaryKey is the fingerprint of the GPG primary key if the signature is made by a subkey.
+	PrimaryKey string `json:"primaryKey,omitempty"`
+	// Trust is the GPG trust level of the key (e.g. 'FULLY' or 'ULTIMATE'), empty for an SSH signature.
+	Trust string `json:"trust,omitempty"`
+}
+
+// VerifiedSignatures holds the signatures verified for a release.
+type VerifiedSignatures struct {
+	// Commit is the signature of the target commit if verified.
+	Commit *Signature `json:"commit,omitempty"`
+	// Tag is the signature of the released tag if verified.
+	Tag *Signature `json:"tag,omitempty"`
+}
+
+// Enabled returns true if any signature is required.
+func (r SignatureRequirements) Enabled() bool {
+	return r.Commit || r.Tag
+}
+
+// Allows returns true if the specified signature is made by one of the allowed signers.
+func (r SignatureRequirements) Allows(s Signature) bool {
+	if len(r.Signers) == 0 {
+		return true
+	}
+
+	for _, signer := range r.Signers {
+		if s.Format == SSHSignatureFormat {
+			// the principal is only reported if the key is listed for it in the allowed signers file.
+			if signer == s.Signer || signer == s.Key {
+				return true
+			}
+		} else if matchesFingerprint(signer, s.Key) || matchesFingerprint(signer, s.PrimaryKey) {
+			return true
+		} else if trustedLevels[s.Trust] && (signer == s.Signer || signer == userIDEmail(s.Signer)) {
+			// a user ID is claimed by the key owner, it only identifies a key validated by the web of trust.
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesFingerprint returns true if the specified signer is either the GPG fingerprint or its long key ID suffix.
+func matchesFingerprint(signer, fingerprint string) bool {
+	return len(fingerprint) > 0 && len(signer) >= 16 && strings.HasSuffix(strings.ToUpper(fingerprint), strings.ToUpper(signer))
+}
+
+// userIDEmail returns the email address of a GPG user ID formatted as 'Name <email>', or an empty string.
+func userIDEmail(uid string) string {
+	if start := strings.LastIndex(uid, "<"); start >= 0 && strings.HasSuffix(uid, ">") {
+		return uid[start+1 : len(uid)-1]
+	}
+	return ""
+}
+
+// ParseSignature parses the output of 'git verify-commit --raw' or 'git verify-tag --raw', which is either the GPG
+// status lines or the output of 'ssh-keygen', it returns false if no good signature is found.
+func ParseSignature(out string) (Signature, bool) {
+	var s Signature
+	var good bool
+
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) > 2 && fields[0] == "[GNUPG:]" && fields[1] == "GOODSIG" {
+			s.Format, s.Signer, good = GPGSignatureFormat, strings.Join(fields[3:], " "), true
+		} else if len(fields) > 2 && fields[0] == "[GNUPG:]" && fields[1] == "VALIDSIG" {
+			s.Key = fields[2]
+
+			if len(fields) > 11 && fields[11] != fields[2] {
+				s.PrimaryKey = fields[11]
+			}
+		} else if len(fields) > 1 && fields[0] == "[GNUPG:]" && strings.HasPrefix(fields[1], "TRUST_") {
+			s.Trust = strings.TrimPrefix(fields[1], "TRUST_")
+		} else if m := sshSignaturePattern.FindStringSubmatch(line); m != nil {
+			s.Format, s.Signer, s.Key, good = SSHSignatureFormat, m[1], m[2], true
+		}
+	}
+
+	return s, good
+}
+
+// Verify verifies the signatures of the specified reference as required, the tag is only verified if the reference
+// is a tag, it returns an error if a required signature is missing, not valid or not made by an allowed signer.
+func (r SignatureRequirements) Verify(g *Git, ref Reference) (VerifiedSignatures, error) {
+	var verified VerifiedSignatures
+
+	if r.Commit {
+		s, err := g.VerifyCommit(ref.Commit, r.AllowedSignersFile)
+
+		if err != nil {
+			return verified, err
+		} else if !r.Allows(s) {
+			return verified, fmt.Errorf("commit '%v' is signed by '%v' with key '%v' who is not an allowed signer", ref.Commit, s.Signer, s.Key)
+		}
+
+		verified.Commit = &s
+	}
+
+	if r.Tag && ref.Kind == TagReference {
+		s, err := g.VerifyTag(ref.Name, r.AllowedSignersFile)
+
+		if err != nil {
+			return verified, err
+		} else if !r.Allows(s) {
+			return verified, fmt.Errorf("tag '%v' is signed by '%v' with key '%v' who is not an allowed signer", ref.Name, s.Signer, s.Key)
+		}
+
+		verified.Tag = &s
+	}
+
+	return verified, nil
+}
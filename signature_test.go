@@ -0,0 +1,217 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const gpgStatus = `[GNUPG:] NEWSIG
+[GNUPG:] KEY_CONSIDERED 4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A 0
+[GNUPG:] SIG_ID 6v3t0bQmXv5Hh1k3n0K2pA 2024-05-01 1714560000
+[GNUPG:] GOODSIG 8F1A3C5E7B9D0F2A Jane Doe <jane@example.com>
+[GNUPG:] VALIDSIG 4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A 2024-05-01 1714560000 0 4 0 22 10 00 4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A
+[GNUPG:] TRUST_ULTIMATE 0 pgp`
+
+func TestParseSignature_GPG(t *testing.T) {
+	s, good := ParseSignature(gpgStatus)
+	assert.True(t, good)
+	assert.Equal(t, Signature{Format: GPGSignatureFormat, Signer: "Jane Doe <jane@example.com>", Key: "4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A", Trust: "ULTIMATE"}, s)
+}
+
+func TestParseSignature_GPGSubkey(t *testing.T) {
+	s, good := ParseSignature(`[GNUPG:] GOODSIG 1B2C3D4E5F6A7B8C Jane Doe <jane@example.com>
+[GNUPG:] VALIDSIG 9A8B7C6D5E4F3A2B1C0D9E8F7A6B5C4D1B2C3D4E5F6A7B8C 2024-05-01 1714560000 0 4 0 22 10 00 4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A
+[GNUPG:] TRUST_UNDEFINED 0 pgp`)
+	assert.True(t, good)
+	assert.Equal(t, Signature{Format: GPGSignatureFormat, Signer: "Jane Doe <jane@example.com>", Key: "9A8B7C6D5E4F3A2B1C0D9E8F7A6B5C4D1B2C3D4E5F6A7B8C",
+		PrimaryKey: "4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A", Trust: "UNDEFINED"}, s)
+}
+
+func TestParseSignature_SSH(t *testing.T) {
+	s, good := ParseSignature(`Good "git" signature for jane@example.com with ED25519 key SHA256:ni3ilVlSsboIrvD/G0zXbUdO3MiniZ8NhzzLzyGGP1w`)
+	assert.True(t, good)
+	assert.Equal(t, Signature{Format: SSHSignatureFormat, Signer: "jane@example.com", Key: "SHA256:ni3ilVlSsboIrvD/G0zXbUdO3MiniZ8NhzzLzyGGP1w"}, s)
+}
+
+func TestParseSignature_Bad(t *testing.T) {
+	_, good := ParseSignature("[GNUPG:] BADSIG 8F1A3C5E7B9D0F2A Jane Doe <jane@example.com>")
+	assert.False(t, good)
+
+	_, good = ParseSignature("")
+	assert.False(t, good)
+}
+
+func TestSignatureRequirements_Allows(t *testing.T) {
+	s := Signature{Format: GPGSignatureFormat, Signer: "Jane Doe <jane@example.com>", Key: "4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A", Trust: "FULLY"}
+
+	assert.True(t, SignatureRequirements{}.Allows(s))
+	assert.True(t, SignatureRequirements{Signers: []string{"jane@example.com"}}.Allows(s))
+	assert.True(t, SignatureRequirements{Signers: []string{"Jane Doe <jane@example.com>"}}.Allows(s))
+	assert.True(t, SignatureRequirements{Signers: []string{"4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A"}}.Allows(s))
+	assert.True(t, SignatureRequirements{Signers: []string{"8f1a3c5e7b9d0f2a"}}.Allows(s))
+	assert.False(t, SignatureRequirements{Signers: []string{"john@example.com", "0F2A", "example.com"}}.Allows(s))
+}
+
+func TestSignatureRequirements_Allows_Untrusted(t *testing.T) {
+	s := Signature{Format: GPGSignatureFormat, Signer: "Jane Doe <jane@example.com>", Key: "9A8B7C6D5E4F3A2B1C0D9E8F7A6B5C4D1B2C3D4E5F6A7B8C",
+		PrimaryKey: "4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A", Trust: "UNDEFINED"}
+
+	// anyone can create a key claiming the user ID, only the fingerprints identify an untrusted key.
+	assert.False(t, SignatureRequirements{Signers: []string{"jane@example.com"}}.Allows(s))
+	assert.False(t, SignatureRequirements{Signers: []string{"Jane Doe <jane@example.com>"}}.Allows(s))
+	assert.True(t, SignatureRequirements{Signers: []string{"4F8A2C5E1B7D9F3A6C0E2B4D8F1A3C5E7B9D0F2A"}}.Allows(s))
+	assert.True(t, SignatureRequirements{Signers: []string{"1B2C3D4E5F6A7B8C"}}.Allows(s))
+}
+
+func TestSignatureRequirements_Allows_SSH(t *testing.T) {
+	s := Signature{Format: SSHSignatureFormat, Signer: "jane@example.com", Key: "SHA256:ni3ilVlSsboIrvD/G0zXbUdO3MiniZ8NhzzLzyGGP1w"}
+
+	assert.True(t, SignatureRequirements{Signers: []string{"jane@example.com"}}.Allows(s))
+	assert.True(t, SignatureRequirements{Signers: []string{"SHA256:ni3ilVlSsboIrvD/G0zXbUdO3MiniZ8NhzzLzyGGP1w"}}.Allows(s))
+	assert.False(t, SignatureRequirements{Signers: []string{"example.com", "SHA256:NI3ILVLSSBOIRVD/G0ZXBUDO3MINIZ8NHZZLZYGGP1W"}}.Allows(s))
+}
+
+type SignatureTestSuite struct {
+	suite.Suite
+	dir     string
+	git     *Git
+	cmd     Command
+	allowed string
+}
+
+// SetupTest creates a repository with an SSH signed commit tagged with the signed tag 'v1.0' and the lightweight tag
+// 'v1.0-light', followed by an unsigned commit, all signed by 'dev@example.com' which is the only allowed signer.
+func (suite *SignatureTestSuite) SetupTest() {
+	var err error
+
+	if _, err = exec.LookPath("ssh-keygen"); err != nil {
+		suite.T().Skip("ssh-keygen is not found")
+	}
+
+	if suite.dir, err = ioutil.TempDir("", "test_rego_signature_"); err != nil {
+		suite.Fail("failed to create temporary directory before test setup", err.Error())
+		return
+	}
+
+	key := filepath.Join(suite.dir, "key")
+	repo := filepath.Join(suite.dir, "repo")
+	suite.allowed = filepath.Join(suite.dir, "allowed_signers")
+
+	if _, err = NewNamedCommand("ssh-keygen", suite.dir).Execute("-q", "-t", "ed25519", "-N", "", "-C", "dev@example.com", "-f", key); err != nil {
+		suite.Fail("failed to generate the signing key before test setup", err.Error())
+		return
+	}
+
+	var public []byte
+
+	if public, err = ioutil.ReadFile(key + ".pub"); err != nil {
+		suite.Fail("failed to read the signing key before test setup", err.Error())
+	} else if err = ioutil.WriteFile(suite.allowed, []byte("dev@example.com "+string(public)), 0600); err != nil {
+		suite.Fail("failed to write the allowed signers before test setup", err.Error())
+	}
+
+	os.Mkdir(repo, os.ModePerm)
+
+	suite.git = &Git{WorkDir: repo}
+	suite.cmd = NewNamedCommand("git", repo)
+
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "gpg.format", "ssh"},
+		{"config", "user.signingkey", key},
+		{"commit", "-n", "-S", "--allow-empty", "-m", "'Signed'"},
+		{"tag", "-s", "-m", "'Signed'", "v1.0"},
+		{"tag", "v1.0-light"},
+		{"commit", "-n", "--no-gpg-sign", "--allow-empty", "-m", "'Unsigned'"},
+	} {
+		if _, err = suite.cmd.Execute(args...); err != nil {
+			suite.T().Skip("git does not support SSH signing: " + err.Error())
+		}
+	}
+}
+
+func (suite *SignatureTestSuite) TearDownTest() {
+	if len(suite.dir) > 0 {
+		if err := os.RemoveAll(suite.dir); err != nil {
+			suite.Fail("failed to remove temporary directory after test teardown", err.Error())
+		}
+	}
+}
+
+func TestSignatureTestSuite(t *testing.T) {
+	suite.Run(t, new(SignatureTestSuite))
+}
+
+func (suite *SignatureTestSuite) reference(expr string) Reference {
+	ref, err := suite.git.ResolveReference(expr)
+
+	if err != nil {
+		suite.Fail("failed to resolve reference", err.Error())
+	}
+
+	return ref
+}
+
+func (suite *SignatureTestSuite) TestVerify_SignedTag() {
+	signed, err := SignatureRequirements{Commit: true, Tag: true, AllowedSignersFile: suite.allowed}.Verify(suite.git, suite.reference("v1.0"))
+	assert.Nil(suite.T(), err)
+	assert.NotNil(suite.T(), signed.Commit)
+	assert.NotNil(suite.T(), signed.Tag)
+	assert.Equal(suite.T(), "dev@example.com", signed.Commit.Signer)
+	assert.Equal(suite.T(), "dev@example.com", signed.Tag.Signer)
+	assert.True(suite.T(), strings.HasPrefix(signed.Tag.Key, "SHA256:"))
+}
+
+func (suite *SignatureTestSuite) TestVerify_CommitOnly() {
+	signed, err := SignatureRequirements{Commit: true, Tag: true, AllowedSignersFile: suite.allowed}.Verify(suite.git, suite.reference("HEAD~1"))
+	assert.Nil(suite.T(), err)
+	assert.NotNil(suite.T(), signed.Commit)
+	assert.Nil(suite.T(), signed.Tag)
+}
+
+func (suite *SignatureTestSuite) TestVerify_UnsignedCommit() {
+	ref := suite.reference("HEAD")
+	_, err := SignatureRequirements{Commit: true, AllowedSignersFile: suite.allowed}.Verify(suite.git, ref)
+	assert.EqualError(suite.T(), err, "commit '"+ref.Commit+"' is not signed")
+}
+
+func (suite *SignatureTestSuite) TestVerify_LightweightTag() {
+	_, err := SignatureRequirements{Tag: true, AllowedSignersFile: suite.allowed}.Verify(suite.git, suite.reference("v1.0-light"))
+	assert.NotNil(suite.T(), err)
+	assert.True(suite.T(), strings.HasPrefix(err.Error(), "tag 'v1.0-light' does not carry a valid signature: "), err.Error())
+}
+
+func (suite *SignatureTestSuite) TestVerify_NotAllowedSigner() {
+	ref := suite.reference("v1.0")
+	_, err := SignatureRequirements{Tag: true, AllowedSignersFile: suite.allowed, Signers: []string{"ops@example.com"}}.Verify(suite.git, ref)
+	assert.NotNil(suite.T(), err)
+	assert.True(suite.T(), strings.HasPrefix(err.Error(), "tag 'v1.0' is signed by 'dev@example.com' with key 'SHA256:"), err.Error())
+}
+
+func (suite *SignatureTestSuite) TestVerify_MissingAllowedSigners() {
+	_, err := SignatureRequirements{Commit: true, AllowedSignersFile: filepath.Join(suite.dir, "missing")}.Verify(suite.git, suite.reference("v1.0"))
+	assert.NotNil(suite.T(), err)
+}
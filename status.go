@@ -0,0 +1,325 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	ignoreFile = ".regoignore"
+)
+
+// The categories of the status entries, in the order they are reported.
+const (
+	ConflictedCategory = "conflicted"
+	SubmoduleCategory  = "submodule"
+	RenamedCategory    = "renamed"
+	StagedCategory     = "staged"
+	UnstagedCategory   = "unstaged"
+	UntrackedCategory  = "untracked"
+)
+
+var statusCategories = []string{
+	ConflictedCategory, SubmoduleCategory, RenamedCategory, StagedCategory, UnstagedCategory, UntrackedCategory,
+}
+
+// StatusEntry is a single changed path as reported by 'git status --porcelain=v2'.
+type StatusEntry struct {
+	// Path is the path relative to the repository root.
+	Path string
+	// OrigPath is the path the entry is renamed or copied from if any.
+	OrigPath string
+	// Staged is the status code of the index (e.g. 'M', 'A', 'D', 'R' or '.' if unchanged).
+	Staged byte
+	// Unstaged is the status code of the working tree (e.g. 'M', 'D' or '.' if unchanged).
+	Unstaged byte
+	// Untracked is true if the path is not tracked.
+	Untracked bool
+	// Conflicted is true if the path is unmerged.
+	Conflicted bool
+	// Submodule is true if the path is a submodule.
+	Submodule bool
+	// SubmoduleCommit is true if the submodule checked out commit differs from the recorded one.
+	SubmoduleCommit bool
+	// SubmoduleModified is true if the submodule has tracked changes.
+	SubmoduleModified bool
+	// SubmoduleUntracked is true if the submodule has untracked files.
+	SubmoduleUntracked bool
+}
+
+// Categories returns the categories this entry belongs to.
+func (e StatusEntry) Categories() []string {
+	var categories []string
+
+	switch {
+	case e.Untracked:
+		return []string{UntrackedCategory}
+	case e.Conflicted:
+		return []string{ConflictedCategory}
+	case e.Submodule:
+		categories = append(categories, SubmoduleCategory)
+	}
+
+	if len(e.OrigPath) > 0 {
+		categories = append(categories, RenamedCategory)
+	}
+
+	if e.Staged != '.' && e.Staged != 0 {
+		categories = append(categories, StagedCategory)
+	}
+
+	if e.Unstaged != '.' && e.Unstaged != 0 {
+		categories = append(categories, UnstagedCategory)
+	}
+
+	return categories
+}
+
+// String returns the entry path, along with the original path if renamed or copied.
+func (e StatusEntry) String() string {
+	if len(e.OrigPath) > 0 {
+		return fmt.Sprintf("%v -> %v", e.OrigPath, e.Path)
+	}
+	return e.Path
+}
+
+// Status is the list of the changed paths of a git working tree.
+type Status []StatusEntry
+
+// ParseStatus parses the output of 'git status --porcelain=v2 -z', it returns an error on malformed output.
+func ParseStatus(out string) (Status, error) {
+	var status Status
+
+	records := strings.Split(out, "\x00")
+
+	for i := 0; i < len(records); i++ {
+		record := records[i]
+
+		if len(record) == 0 || record[0] == '#' || record[0] == '!' {
+			continue
+		}
+
+		var fields []string
+		var entry StatusEntry
+
+		switch record[0] {
+		case '?':
+			entry = StatusEntry{Path: strings.TrimPrefix(record, "? "), Untracked: true}
+		case '1':
+			if fields = strings.SplitN(record, " ", 9); len(fields) != 9 {
+				return nil, fmt.Errorf("malformed status entry '%v'", record)
+			}
+			entry.Path = fields[8]
+		case '2':
+			if fields = strings.SplitN(record, " ", 10); len(fields) != 10 || i+1 >= len(records) {
+				return nil, fmt.Errorf("malformed status entry '%v'", record)
+			}
+			i++
+			entry.Path, entry.OrigPath = fields[9], records[i]
+		case 'u':
+			if fields = strings.SplitN(record, " ", 11); len(fields) != 11 {
+				return nil, fmt.Errorf("malformed status entry '%v'", record)
+			}
+			entry.Path, entry.Conflicted = fields[10], true
+		default:
+			return nil, fmt.Errorf("unknown status entry '%v'", record)
+		}
+
+		if fields != nil {
+			if len(fields[1]) != 2 || len(fields[2]) != 4 {
+				return nil, fmt.Errorf("malformed status entry '%v'", record)
+			}
+
+			entry.Staged, entry.Unstaged = fields[1][0], fields[1][1]
+
+			if sub := fields[2]; sub[0] == 'S' {
+				entry.Submodule = true
+				entry.SubmoduleCommit = sub[1] == 'C'
+				entry.SubmoduleModified = sub[2] == 'M'
+				entry.SubmoduleUntracked = sub[3] == 'U'
+			}
+		}
+
+		status = append(status, entry)
+	}
+
+	return status, nil
+}
+
+// Ignored returns true if the entry matches the specified ignore rules,
+// a renamed or copied entry only matches if both of its paths match.
+func (e StatusEntry) Ignored(rules IgnoreRules) bool {
+	return rules.Match(e.Path) && (len(e.OrigPath) == 0 || rules.Match(e.OrigPath))
+}
+
+// Filter returns the entries whose paths do not match any of the specified ignore rules,
+// a renamed or copied entry is only dropped if both of its paths match.
+func (s Status) Filter(rules IgnoreRules) Status {
+	var result Status
+
+	for _, e := range s {
+		if !e.Ignored(rules) {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+// Report returns the entries paths grouped by category, an entry is listed under every category it belongs to.
+func (s Status) Report() string {
+	groups := make(map[string][]string)
+
+	for _, e := range s {
+		for _, c := range e.Categories() {
+			groups[c] = append(groups[c], e.String())
+		}
+	}
+
+	var report []string
+
+	for _, c := range statusCategories {
+		if len(groups[c]) > 0 {
+			report = append(report, fmt.Sprintf(" %v:", c))
+
+			for _, p := range groups[c] {
+				report = append(report, "  "+p)
+			}
+		}
+	}
+
+	return strings.Join(report, NewLine())
+}
+
+// IgnoreRules is a list of glob patterns matching the paths that are allowed to be dirty.
+type IgnoreRules []*regexp.Regexp
+
+// NewIgnoreRules compiles the specified glob patterns, a pattern supports '*', '?', '[...]' and '**' matching
+// any number of directories, a pattern without a '/' matches the base name at any depth, and a pattern ending with
+// a '/' matches everything under a directory, it returns an error on an invalid pattern.
+func NewIgnoreRules(patterns ...string) (IgnoreRules, error) {
+	var rules IgnoreRules
+
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); len(p) == 0 || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		r, err := regexp.Compile(globToRegexp(p))
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern '%v'", p)
+		}
+
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+func globToRegexp(pattern string) string {
+	var b strings.Builder
+
+	if strings.HasSuffix(pattern, "/") {
+		pattern = pattern + "**"
+	}
+
+	if !strings.Contains(strings.TrimSuffix(pattern, "/**"), "/") {
+		b.WriteString("(?:.*/)?")
+	}
+
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if strings.HasPrefix(pattern[i:], "**/") {
+				b.WriteString("(?:.*/)?")
+				i += 2
+			} else if strings.HasPrefix(pattern[i:], "**") {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			if end := strings.IndexByte(pattern[i:], ']'); end > 0 {
+				class := pattern[i+1 : i+end]
+				if strings.HasPrefix(class, "!") || strings.HasPrefix(class, "^") {
+					class = "^/" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += end
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return "^" + b.String() + "$"
+}
+
+// Match returns true if the specified slash separated path matches any of these rules.
+func (rules IgnoreRules) Match(p string) bool {
+	p = path.Clean(filepath.ToSlash(p))
+
+	for _, r := range rules {
+		if r.MatchString(p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readIgnoreFile reads the ignore patterns from the '.regoignore' file in the specified directory, one per line,
+// empty lines and lines starting with '#' are skipped, a missing file results in no patterns.
+func readIgnoreFile(dir string) ([]string, error) {
+	var patterns []string
+
+	f, err := os.Open(filepath.Join(dir, ignoreFile))
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) > 0 && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const porcelain = "1 .M N... 100644 100644 100644 3e2ceb914cf9be46bf235432781840f4145363fd 3e2ceb914cf9be46bf235432781840f4145363fd README\x00" +
+	"1 A. N... 000000 100644 100644 0000000000000000000000000000000000000000 e69de29bb2d1d6434b8b29ae775ad8c2e48c5391 docs/new file.md\x00" +
+	"2 R. N... 100644 100644 100644 e69de29bb2d1d6434b8b29ae775ad8c2e48c5391 e69de29bb2d1d6434b8b29ae775ad8c2e48c5391 R100 renamed.go\x00empty.go\x00" +
+	"1 .M SC.U 160000 160000 160000 4b825dc642cb6eb9a060e54bf8d69288fbee4904 4b825dc642cb6eb9a060e54bf8d69288fbee4904 vendor/lib\x00" +
+	"u UU N... 100644 100644 100644 100644 e69de29bb2d1d6434b8b29ae775ad8c2e48c5391 e69de29bb2d1d6434b8b29ae775ad8c2e48c5391 e69de29bb2d1d6434b8b29ae775ad8c2e48c5391 conflict.go\x00" +
+	"? api.pb.go\x00" +
+	"! ignored.log\x00"
+
+func TestParseStatus_Success(t *testing.T) {
+	status, err := ParseStatus(porcelain)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Status{
+		{Path: "README", Staged: '.', Unstaged: 'M'},
+		{Path: "docs/new file.md", Staged: 'A', Unstaged: '.'},
+		{Path: "renamed.go", OrigPath: "empty.go", Staged: 'R', Unstaged: '.'},
+		{Path: "vendor/lib", Staged: '.', Unstaged: 'M', Submodule: true, SubmoduleCommit: true, SubmoduleUntracked: true},
+		{Path: "conflict.go", Staged: 'U', Unstaged: 'U', Conflicted: true},
+		{Path: "api.pb.go", Untracked: true},
+	}, status)
+
+	status, err = ParseStatus("")
+	assert.Nil(t, err)
+	assert.Empty(t, status)
+}
+
+func TestParseStatus_Failure(t *testing.T) {
+	for _, out := range []string{
+		"1 .M N... README\x00",
+		"2 R. N... 100644 100644 100644 e69de29 e69de29 R100 renamed.go",
+		"u UU N... conflict.go\x00",
+		"x unknown\x00",
+	} {
+		_, err := ParseStatus(out)
+		assert.NotNil(t, err, out)
+	}
+}
+
+func TestStatus_Report(t *testing.T) {
+	status, _ := ParseStatus(porcelain)
+
+	assert.Equal(t, strings.Join([]string{
+		" conflicted:",
+		"  conflict.go",
+		" submodule:",
+		"  vendor/lib",
+		" renamed:",
+		"  empty.go -> renamed.go",
+		" staged:",
+		"  docs/new file.md",
+		"  empty.go -> renamed.go",
+		" unstaged:",
+		"  README",
+		"  vendor/lib",
+		" untracked:",
+		"  api.pb.go",
+	}, NewLine()), status.Report())
+}
+
+func TestStatus_Filter(t *testing.T) {
+	status, _ := ParseStatus(porcelain)
+	rules, err := NewIgnoreRules("*.pb.go", "docs/", "renamed.go", "# comment", "")
+
+	assert.Nil(t, err)
+	assert.Len(t, rules, 3)
+
+	filtered := status.Filter(rules)
+
+	assert.Equal(t, []string{"README", "renamed.go", "vendor/lib", "conflict.go"},
+		[]string{filtered[0].Path, filtered[1].Path, filtered[2].Path, filtered[3].Path})
+	assert.Len(t, filtered, 4)
+}
+
+func TestIgnoreRules_Match(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.pb.go", "api.pb.go", true},
+		{"*.pb.go", "internal/api/api.pb.go", true},
+		{"*.pb.go", "api.go", false},
+		{"docs/", "docs/index.md", true},
+		{"docs/", "sub/docs/index.md", true},
+		{"/docs/", "sub/docs/index.md", false},
+		{"gen/*.go", "gen/a.go", true},
+		{"gen/*.go", "gen/sub/a.go", false},
+		{"gen/*.go", "x/gen/a.go", false},
+		{"gen/**/*.go", "gen/sub/deep/a.go", true},
+		{"gen/**/*.go", "gen/a.go", true},
+		{"**/zz_*.go", "a/b/zz_generated.go", true},
+		{"v?.txt", "v1.txt", true},
+		{"v[0-9].txt", "va.txt", false},
+		{"v[!0-9].txt", "va.txt", true},
+		{"v[!0-9].txt", "v1.txt", false},
+		{"v[!a].txt", "v/.txt", false},
+		{"a+b.txt", "a+b.txt", true},
+		{"a+b.txt", "aab.txt", false},
+	} {
+		rules, err := NewIgnoreRules(c.pattern)
+		assert.Nil(t, err)
+		assert.Equal(t, c.match, rules.Match(c.path), "%v ~ %v", c.pattern, c.path)
+	}
+
+	_, err := NewIgnoreRules("[z-a]")
+	assert.NotNil(t, err)
+}
+
+func TestReadIgnoreFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_rego_status_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	patterns, err := readIgnoreFile(dir)
+	assert.Nil(t, err)
+	assert.Empty(t, patterns)
+
+	if err = ioutil.WriteFile(filepath.Join(dir, ignoreFile), []byte("# generated\ndocs/\n\n  *.pb.go  \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	patterns, err = readIgnoreFile(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"docs/", "*.pb.go"}, patterns)
+}
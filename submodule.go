@@ -0,0 +1,93 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The states of a submodule as reported by 'git submodule status'.
+const (
+	SubmoduleInSync        = ' '
+	SubmoduleUninitialized = '-'
+	SubmoduleOutOfSync     = '+'
+	SubmoduleConflicted    = 'U'
+)
+
+// Submodule is a git submodule checked out in the working tree.
+type Submodule struct {
+	// Path is the submodule path relative to the repository root.
+	Path string `json:"path"`
+	// Commit is the commit hash checked out in the submodule, or the recorded one if it is not initialized.
+	Commit string `json:"commit"`
+	// State is one of: ' ' if the checked out commit is the recorded one, '-' if the submodule is not initialized,
+	// '+' if the checked out commit differs from the recorded one, or 'U' if it has merge conflicts.
+	State byte `json:"-"`
+}
+
+// Problem returns a description of why this submodule is not in sync with its recorded commit,
+// or an empty string if it is.
+func (s Submodule) Problem() string {
+	switch s.State {
+	case SubmoduleInSync:
+		return ""
+	case SubmoduleUninitialized:
+		return fmt.Sprintf("submodule '%v' is not initialized", s.Path)
+	case SubmoduleOutOfSync:
+		return fmt.Sprintf("submodule '%v' checked out commit '%v' is not the recorded one", s.Path, s.Commit)
+	case SubmoduleConflicted:
+		return fmt.Sprintf("submodule '%v' has merge conflicts", s.Path)
+	}
+	return fmt.Sprintf("submodule '%v' has an unknown state '%c'", s.Path, s.State)
+}
+
+// ParseSubmoduleStatus parses the output of 'git submodule status', it returns an error on malformed output.
+func ParseSubmoduleStatus(out string) ([]Submodule, error) {
+	var submodules []Submodule
+
+	for _, line := range strings.Split(out, "\n") {
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		s := Submodule{State: SubmoduleInSync}
+
+		switch line[0] {
+		case SubmoduleInSync, SubmoduleUninitialized, SubmoduleOutOfSync, SubmoduleConflicted:
+			s.State, line = line[0], line[1:]
+		}
+
+		// the leading space of an in sync submodule might have been trimmed off the output.
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 2)
+
+		if len(fields) != 2 || len(fields[0]) == 0 {
+			return nil, fmt.Errorf("malformed submodule status '%v'", line)
+		}
+
+		s.Commit, s.Path = fields[0], fields[1]
+
+		// the path is followed by the commit description in parentheses if the submodule is initialized.
+		if i := strings.LastIndex(s.Path, " ("); i > 0 && strings.HasSuffix(s.Path, ")") {
+			s.Path = s.Path[:i]
+		}
+
+		submodules = append(submodules, s)
+	}
+
+	return submodules, nil
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubmoduleStatus(t *testing.T) {
+	submodules, err := ParseSubmoduleStatus(
+		"a1b2c3 lib/common (v1.0-2-ga1b2c3)\n" +
+			"-d4e5f6 vendor/third party\n" +
+			"+0a1b2c docs (heads/master)\n" +
+			"U3d4e5f proto\n")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Submodule{
+		{Path: "lib/common", Commit: "a1b2c3", State: SubmoduleInSync},
+		{Path: "vendor/third party", Commit: "d4e5f6", State: SubmoduleUninitialized},
+		{Path: "docs", Commit: "0a1b2c", State: SubmoduleOutOfSync},
+		{Path: "proto", Commit: "3d4e5f", State: SubmoduleConflicted},
+	}, submodules)
+}
+
+func TestParseSubmoduleStatus_Empty(t *testing.T) {
+	submodules, err := ParseSubmoduleStatus("")
+	assert.Nil(t, err)
+	assert.Empty(t, submodules)
+}
+
+func TestParseSubmoduleStatus_Malformed(t *testing.T) {
+	_, err := ParseSubmoduleStatus("+a1b2c3")
+	assert.NotNil(t, err)
+}
+
+func TestSubmodule_Problem(t *testing.T) {
+	assert.Empty(t, Submodule{Path: "lib", State: SubmoduleInSync}.Problem())
+	assert.Equal(t, "submodule 'lib' is not initialized", Submodule{Path: "lib", State: SubmoduleUninitialized}.Problem())
+	assert.Equal(t, "submodule 'lib' checked out commit 'a1b2c3' is not the recorded one",
+		Submodule{Path: "lib", Commit: "a1b2c3", State: SubmoduleOutOfSync}.Problem())
+	assert.Equal(t, "submodule 'lib' has merge conflicts", Submodule{Path: "lib", State: SubmoduleConflicted}.Problem())
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var goVersionPattern = regexp.MustCompile(`^(?:go)?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:(beta|rc)(\d+))?$`)
+
+// Toolchain selects the Go command used in every step of a build.
+type Toolchain struct {
+	// Go is the path of the go binary, the 'go' found in the 'PATH' is used if empty.
+	Go string
+	// Version is the Go version (e.g. 'go1.22.3') selected through the 'GOTOOLCHAIN' environment variable,
+	// if empty and a go binary is specified, 'GOTOOLCHAIN=local' is used to prevent switching to another toolchain.
+	Version string
+}
+
+// ParseToolchain returns the toolchain specified by either a Go version (e.g. 'go1.22.3')
+// or the path of a go binary, it returns an error if the version is not the name of a Go release.
+func ParseToolchain(spec string) (Toolchain, error) {
+	spec = strings.TrimSpace(spec)
+
+	if goVersionPattern.MatchString(spec) && !strings.ContainsRune(spec, filepath.Separator) {
+		if !strings.HasPrefix(spec, "go") {
+			spec = "go" + spec
+		}
+
+		// since Go 1.21 the language versions (e.g. 'go1.22') are not released, their first release is 'go1.22.0'.
+		if v, _ := parseGoVersion(spec); v.kind == languageVersion && (v.major > 1 || v.minor >= 21) {
+			return Toolchain{}, fmt.Errorf("invalid toolchain '%v', a Go release is expected (e.g. '%v.0')", spec, spec)
+		}
+
+		return Toolchain{Version: spec}, nil
+	}
+
+	return Toolchain{Go: spec}, nil
+}
+
+// String returns the specification of this toolchain as accepted by ParseToolchain,
+// or 'go' if it is the default one.
+func (t Toolchain) String() string {
+	if len(t.Version) > 0 {
+		return t.Version
+	} else if len(t.Go) > 0 {
+		return t.Go
+	}
+	return "go"
+}
+
+func (t Toolchain) command() string {
+	if len(t.Go) > 0 {
+		return t.Go
+	}
+	return "go"
+}
+
+// Env returns the environment variables that pin this toolchain.
+func (t Toolchain) Env() []string {
+	if len(t.Version) > 0 {
+		return []string{fmt.Sprintf("GOTOOLCHAIN=%v", t.Version)}
+	} else if len(t.Go) > 0 {
+		return []string{"GOTOOLCHAIN=local"}
+	}
+	return nil
+}
+
+type goVersion struct {
+	// devel is true for the development toolchains built from source, which are newer than any release.
+	devel               bool
+	major, minor, patch int
+	// kind orders the language versions (e.g. '1.21') before the pre-releases and the releases of the same numbers.
+	kind, pre int
+}
+
+const (
+	languageVersion = iota
+	betaVersion
+	rcVersion
+	releaseVersion
+)
+
+func parseGoVersion(version string) (goVersion, error) {
+	var v goVersion
+
+	// the development toolchains report a version like 'devel go1.23-e8ee1dc4f9 Tue Apr 2 19:02:25 2024 +0000'.
+	if strings.HasPrefix(strings.TrimSpace(version), "devel") {
+		v.devel = true
+		return v, nil
+	}
+
+	// the experiments enabled in the toolchain follow the version, e.g. 'go1.22.1 X:loopvar'.
+	m := goVersionPattern.FindStringSubmatch(strings.SplitN(strings.TrimSpace(version), " ", 2)[0])
+
+	if m == nil {
+		return v, fmt.Errorf("invalid Go version '%v'", version)
+	}
+
+	v.major, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	v.patch, _ = strconv.Atoi(m[3])
+	v.pre, _ = strconv.Atoi(m[5])
+
+	switch {
+	case m[4] == "beta":
+		v.kind = betaVersion
+	case m[4] == "rc":
+		v.kind = rcVersion
+	case len(m[3]) > 0:
+		v.kind = releaseVersion
+	default:
+		v.kind = languageVersion
+	}
+
+	return v, nil
+}
+
+// compareGoVersions compares two Go versions (e.g. 'go1.21', '1.21rc2' and 'go1.21.3'), it returns 0 if a == b,
+// -1 if a < b and +1 if a > b, a development version is greater than any other one, it returns an error if any of both
+// is not a valid Go version.
+func compareGoVersions(a, b string) (int, error) {
+	var va, vb goVersion
+	var err error
+
+	if va, err = parseGoVersion(a); err != nil {
+		return 0, err
+	} else if vb, err = parseGoVersion(b); err != nil {
+		return 0, err
+	}
+
+	if va.devel || vb.devel {
+		if va.devel == vb.devel {
+			return 0, nil
+		} else if va.devel {
+			return 1, nil
+		}
+		return -1, nil
+	}
+
+	for _, d := range [][2]int{
+		{va.major, vb.major}, {va.minor, vb.minor}, {va.patch, vb.patch}, {va.kind, vb.kind}, {va.pre, vb.pre},
+	} {
+		if d[0] < d[1] {
+			return -1, nil
+		} else if d[0] > d[1] {
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// goDirectives holds the versions required by the 'go' and 'toolchain' directives of a 'go.mod' file.
+type goDirectives struct {
+	Go        string
+	Toolchain string
+}
+
+// readGoDirectives reads the 'go' and 'toolchain' directives of the specified 'go.mod' or 'go.work' file,
+// a missing file results in empty directives.
+func readGoDirectives(file string) (goDirectives, error) {
+	var d goDirectives
+
+	f, err := os.Open(file)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return d, nil
+		}
+		return d, err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	block := false
+
+	for scanner.Scan() {
+		fields := strings.Fields(strings.SplitN(scanner.Text(), "//", 2)[0])
+
+		if len(fields) > 0 && fields[len(fields)-1] == "(" {
+			block = true
+			continue
+		} else if block {
+			block = len(fields) == 0 || fields[0] != ")"
+			continue
+		} else if len(fields) != 2 {
+			continue
+		} else if fields[0] == "go" {
+			d.Go = fields[1]
+		} else if fields[0] == "toolchain" && fields[1] != "default" {
+			d.Toolchain = fields[1]
+		}
+	}
+
+	return d, scanner.Err()
+}
+
+// check returns an error if the specified Go version does not satisfy these directives.
+func (d goDirectives) check(version string) error {
+	for _, directive := range []struct{ name, version string }{{"go", d.Go}, {"toolchain", d.Toolchain}} {
+		if len(directive.version) == 0 {
+			continue
+		} else if c, err := compareGoVersions(version, directive.version); err != nil {
+			return err
+		} else if c < 0 {
+			return fmt.Errorf("requires '%v %v' but the toolchain version is '%v'", directive.name, directive.version, version)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2017 Ahmed Zaher
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseToolchain(t *testing.T) {
+	for spec, expected := range map[string]Toolchain{
+		"go1.22.3":             {Version: "go1.22.3"},
+		" 1.21rc2 ":            {Version: "go1.21rc2"},
+		"1.22.0":               {Version: "go1.22.0"},
+		"go1.20":               {Version: "go1.20"},
+		"/usr/local/go/bin/go": {Go: "/usr/local/go/bin/go"},
+		"go":                   {Go: "go"},
+	} {
+		toolchain, err := ParseToolchain(spec)
+		assert.Nil(t, err, spec)
+		assert.Equal(t, expected, toolchain, spec)
+	}
+
+	for _, spec := range []string{"1.22", "go1.21", "go2"} {
+		_, err := ParseToolchain(spec)
+		assert.NotNil(t, err, spec)
+	}
+}
+
+func TestToolchain_Env(t *testing.T) {
+	assert.Equal(t, []string{"GOTOOLCHAIN=go1.22.3"}, Toolchain{Version: "go1.22.3"}.Env())
+	assert.Equal(t, []string{"GOTOOLCHAIN=local"}, Toolchain{Go: "/usr/local/go/bin/go"}.Env())
+	assert.Empty(t, Toolchain{}.Env())
+}
+
+func TestToolchain_String(t *testing.T) {
+	assert.Equal(t, "go1.22.3", Toolchain{Version: "go1.22.3"}.String())
+	assert.Equal(t, "/usr/local/go/bin/go", Toolchain{Go: "/usr/local/go/bin/go"}.String())
+	assert.Equal(t, "go", Toolchain{}.String())
+}
+
+func TestCompareGoVersions(t *testing.T) {
+	for _, c := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"go1.21.0", "1.21", 1},
+		{"go1.21rc1", "1.21", 1},
+		{"go1.21rc1", "go1.21.0", -1},
+		{"go1.21beta1", "go1.21rc1", -1},
+		{"go1.21rc2", "go1.21rc1", 1},
+		{"go1.20.14", "1.21", -1},
+		{"go1.22.3", "go1.22.3", 0},
+		{"go1.22.1 X:loopvar", "go1.22.1", 0},
+		{"go1.9", "go1.10", -1},
+		{"go2", "go1.30", 1},
+	} {
+		result, err := compareGoVersions(c.a, c.b)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, result, "%v vs %v", c.a, c.b)
+	}
+
+	result, err := compareGoVersions("devel go1.23-e8ee1dc4f9 Tue Apr 2 19:02:25 2024 +0000", "go1.23.0")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result)
+
+	result, err = compareGoVersions("go1.22.3", "devel go1.23-e8ee1dc4f9")
+	assert.Nil(t, err)
+	assert.Equal(t, -1, result)
+
+	_, err = compareGoVersions("tip", "1.21")
+	assert.NotNil(t, err)
+}
+
+func TestReadGoDirectives(t *testing.T) {
+	dir, err := ioutil.TempDir("", "test_rego_toolchain_")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	d, err := readGoDirectives(filepath.Join(dir, "go.mod"))
+	assert.Nil(t, err)
+	assert.Equal(t, goDirectives{}, d)
+
+	mod := "module example.com/project // comment\n\ngo 1.21 // minimum\n\ntoolchain go1.22.3\n\nrequire (\n\tgo 1.0\n)\n"
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(mod), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = readGoDirectives(filepath.Join(dir, "go.mod"))
+	assert.Nil(t, err)
+	assert.Equal(t, goDirectives{Go: "1.21", Toolchain: "go1.22.3"}, d)
+}
+
+func TestGoDirectives_Check(t *testing.T) {
+	d := goDirectives{Go: "1.21", Toolchain: "go1.22.3"}
+
+	assert.Nil(t, d.check("go1.22.3"))
+	assert.Nil(t, d.check("go1.23.0"))
+	assert.NotNil(t, d.check("go1.22.0"))
+	assert.NotNil(t, goDirectives{Go: "1.21"}.check("go1.20.14"))
+	assert.Nil(t, goDirectives{}.check("go1.20.14"))
+}